@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+//Role decides which endpoints a key may call
+type Role string
+
+const (
+	//RoleSearch may only read regions and properties and shop availability
+	RoleSearch Role = "search"
+	//RoleBook may also book, and do everything a search key can
+	RoleBook Role = "book"
+	//RoleAdmin may also sync regions, and do everything a search or book key can
+	RoleAdmin Role = "admin"
+)
+
+//Allows tells whether a key with role may call an endpoint requiring required
+func (role Role) Allows(required Role) bool {
+	return role == RoleAdmin || role == required || role == RoleBook && required == RoleSearch
+}
+
+func (role Role) valid() bool {
+	return role == RoleSearch || role == RoleBook || role == RoleAdmin
+}
+
+//Key is an issued API key. Only its prefix and the hash of the whole key are stored
+type Key struct {
+	Id        int64
+	Name      string
+	Role      Role
+	Prefix    string
+	Hash      string
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+//keyPrefix starts every key so they are easy to recognise, for example by secret scanners
+const keyPrefix = "hst"
+
+//generateKey returns a new key "hst_<prefix>_<secret>" and its prefix
+func generateKey() (key string, prefix string, err error) {
+	random := make([]byte, 36)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", err
+	}
+	prefix = hex.EncodeToString(random[:4])
+	return keyPrefix + "_" + prefix + "_" + hex.EncodeToString(random[4:]), prefix, nil
+}
+
+//parseKey returns the prefix of key, or false when key is not shaped like an issued key
+func parseKey(key string) (string, bool) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != keyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+//hashKey is the hex sha256 of key. Keys are long and random, so a fast hash is enough
+func hashKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+//Signature signs a request for the "Signature keyid=...,signature=...,timestamp=..." authorization header with the
+//signing key handed out with the api key: the hex HMAC-SHA256 of method, request uri, unix timestamp and hex sha256
+//of the body, one per line. Neither key is ever sent
+func Signature(signingKey, method, requestURI, timestamp string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(method + "\n" + requestURI + "\n" + timestamp + "\n" + hex.EncodeToString(bodyHash[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+//signingKey derives the key requests are signed with from the hash of an api key and the server secret. The secret
+//is not stored with the hashes, so reading api_keys is not enough to sign requests
+func signingKey(secret []byte, hash string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(hash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"database/sql"
+	"time"
+)
+
+type keyRepositoryInt interface {
+	create(key Key) (Key, error)
+	byPrefix(prefix string) (Key, error)
+	list() ([]Key, error)
+	revoke(prefix string) (bool, error)
+	useSignature(keyId int64, signature string, expiresAt time.Time) (bool, error)
+	sweepSignatures() error
+}
+
+type keyRepository struct {
+	db *sql.DB
+}
+
+func NewKeyRepository(db *sql.DB) keyRepository {
+	return keyRepository{
+		db: db,
+	}
+}
+
+func (repository keyRepository) create(key Key) (Key, error) {
+	query := `insert into api_keys (name, role, prefix, hash) values ($1, $2, $3, $4) returning id, created_at`
+	err := repository.db.QueryRow(query, key.Name, key.Role, key.Prefix, key.Hash).Scan(&key.Id, &key.CreatedAt)
+	if err != nil {
+		return Key{}, err
+	}
+	return key, nil
+}
+
+//byPrefix returns the key with prefix that has not been revoked, or sql.ErrNoRows
+func (repository keyRepository) byPrefix(prefix string) (Key, error) {
+	var key Key
+	query := `select id, name, role, prefix, hash, created_at from api_keys where prefix = $1 and revoked_at is null`
+	err := repository.db.QueryRow(query, prefix).Scan(&key.Id, &key.Name, &key.Role, &key.Prefix, &key.Hash,
+		&key.CreatedAt)
+	if err != nil {
+		return Key{}, err
+	}
+	return key, nil
+}
+
+func (repository keyRepository) list() ([]Key, error) {
+	rows, err := repository.db.Query(`select id, name, role, prefix, created_at, revoked_at from api_keys order by id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []Key
+	for rows.Next() {
+		var key Key
+		if err := rows.Scan(&key.Id, &key.Name, &key.Role, &key.Prefix, &key.CreatedAt, &key.RevokedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+//revoke revokes the key with prefix, telling whether there was such a key still in use
+func (repository keyRepository) revoke(prefix string) (bool, error) {
+	result, err := repository.db.Exec(`update api_keys set revoked_at = now() where prefix = $1 and revoked_at is null`,
+		prefix)
+	if err != nil {
+		return false, err
+	}
+	revoked, err := result.RowsAffected()
+	return revoked > 0, err
+}
+
+//useSignature records a signature of the key with id keyId until expiresAt, telling whether it was not recorded yet
+func (repository keyRepository) useSignature(keyId int64, signature string, expiresAt time.Time) (bool, error) {
+	result, err := repository.db.Exec(`insert into api_key_signatures (signature, key_id, expires_at)
+		values ($1, $2, $3) on conflict (signature) do nothing`, signature, keyId, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	recorded, err := result.RowsAffected()
+	return recorded > 0, err
+}
+
+func (repository keyRepository) sweepSignatures() error {
+	_, err := repository.db.Exec(`delete from api_key_signatures where expires_at < now()`)
+	return err
+}
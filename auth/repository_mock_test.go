@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+type mockKeyRepository struct {
+	mock.Mock
+}
+
+func (m *mockKeyRepository) create(key Key) (Key, error) {
+	args := m.Called(key)
+	if args[1] != nil {
+		return args[0].(Key), args[1].(error)
+	}
+	return args[0].(Key), nil
+}
+
+func (m *mockKeyRepository) byPrefix(prefix string) (Key, error) {
+	args := m.Called(prefix)
+	if args[1] != nil {
+		return args[0].(Key), args[1].(error)
+	}
+	return args[0].(Key), nil
+}
+
+func (m *mockKeyRepository) list() ([]Key, error) {
+	args := m.Called()
+	if args[1] != nil {
+		return args[0].([]Key), args[1].(error)
+	}
+	return args[0].([]Key), nil
+}
+
+func (m *mockKeyRepository) revoke(prefix string) (bool, error) {
+	args := m.Called(prefix)
+	if args[1] != nil {
+		return args[0].(bool), args[1].(error)
+	}
+	return args[0].(bool), nil
+}
+
+func (m *mockKeyRepository) useSignature(keyId int64, signature string, expiresAt time.Time) (bool, error) {
+	args := m.Called(keyId, signature, expiresAt)
+	if args[1] != nil {
+		return args[0].(bool), args[1].(error)
+	}
+	return args[0].(bool), nil
+}
+
+func (m *mockKeyRepository) sweepSignatures() error {
+	args := m.Called()
+	if args[0] != nil {
+		return args[0].(error)
+	}
+	return nil
+}
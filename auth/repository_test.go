@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCreateKey(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewKeyRepository(db)
+	createdAt := time.Unix(1559215747, 0)
+
+	mock.ExpectQuery("insert into api_keys").WithArgs("ops", RoleAdmin, "0a1b2c3d", "hash").
+		WillReturnRows(mock.NewRows([]string{"id", "created_at"}).AddRow(3, createdAt))
+
+	key, err := repo.create(Key{Name: "ops", Role: RoleAdmin, Prefix: "0a1b2c3d", Hash: "hash"})
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, Key{Id: 3, Name: "ops", Role: RoleAdmin, Prefix: "0a1b2c3d", Hash: "hash", CreatedAt: createdAt}, key)
+}
+
+func TestKeyByPrefixShouldSkipRevokedKeys(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewKeyRepository(db)
+	createdAt := time.Unix(1559215747, 0)
+
+	mock.ExpectQuery("from api_keys where prefix = (.+) and revoked_at is null").WithArgs("0a1b2c3d").
+		WillReturnRows(mock.NewRows([]string{"id", "name", "role", "prefix", "hash", "created_at"}).
+			AddRow(3, "ops", "admin", "0a1b2c3d", "hash", createdAt))
+
+	key, err := repo.byPrefix("0a1b2c3d")
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, Key{Id: 3, Name: "ops", Role: RoleAdmin, Prefix: "0a1b2c3d", Hash: "hash", CreatedAt: createdAt}, key)
+}
+
+func TestRevokeKey(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewKeyRepository(db)
+
+	mock.ExpectExec("update api_keys set revoked_at").WithArgs("0a1b2c3d").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	revoked, err := repo.revoke("0a1b2c3d")
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.True(t, revoked)
+}
+
+func TestUseSignatureShouldTellWhetherItWasUsedBefore(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewKeyRepository(db)
+	expiresAt := time.Unix(1559216047, 0)
+
+	mock.ExpectExec(`insert into api_key_signatures .* on conflict \(signature\) do nothing`).
+		WithArgs("0a0b", int64(3), expiresAt).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("insert into api_key_signatures").WithArgs("0a0b", int64(3), expiresAt).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	first, firstErr := repo.useSignature(3, "0a0b", expiresAt)
+	again, againErr := repo.useSignature(3, "0a0b", expiresAt)
+
+	assert.Nil(t, firstErr)
+	assert.Nil(t, againErr)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.True(t, first)
+	assert.False(t, again)
+}
+
+func TestSweepSignatures(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewKeyRepository(db)
+
+	mock.ExpectExec("delete from api_key_signatures where expires_at < now()").
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	assert.Nil(t, repo.sweepSignatures())
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/subtle"
+	"database/sql"
+	"github.com/pkg/errors"
+	"hotels-service-template/hotel"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//MaxSkew is how far the timestamp of a signed request may be from the server clock
+const MaxSkew = 5 * time.Minute
+
+//MaxSignedBody is the largest body a signed request may have, as it is read whole to check its hash
+const MaxSignedBody = 1 << 20
+
+var (
+	ErrMissingCredentials = hotel.Unauthenticated("missing_credentials",
+		`send "Authorization: ApiKey <key>" or a signed "Authorization: Signature ..." header`)
+	ErrInvalidKey       = hotel.Unauthenticated("invalid_api_key", "the api key is not valid or has been revoked")
+	ErrInvalidSignature = hotel.Unauthenticated("invalid_signature", "the request signature is not valid")
+	ErrStaleSignature   = hotel.Unauthenticated("stale_signature",
+		"the signature timestamp is more than %s away from the server clock", MaxSkew)
+	ErrReplayedSignature = hotel.Unauthenticated("replayed_signature",
+		"the signature has already been used, sign every request with a new timestamp")
+	ErrSignaturesDisabled = hotel.Unauthenticated("signatures_disabled",
+		`signed requests are not enabled on this server, send "Authorization: ApiKey <key>"`)
+)
+
+type KeyServiceInt interface {
+	Issue(name string, role Role) (Key, string, error)
+	Revoke(prefix string) error
+	List() ([]Key, error)
+	Authenticate(r *http.Request) (Key, error)
+}
+
+type KeyService struct {
+	repository    keyRepositoryInt
+	signingSecret []byte
+	now           func() time.Time
+}
+
+//NewKeyService creates the service authenticating keys from repository. Signing keys are derived with
+//signingSecret; signed requests are refused when it is empty
+func NewKeyService(repository keyRepositoryInt, signingSecret string) *KeyService {
+	return &KeyService{
+		repository:    repository,
+		signingSecret: []byte(signingSecret),
+		now:           time.Now,
+	}
+}
+
+//Issue creates a key for name with role. The key is returned only here, it cannot be recovered later
+func (s *KeyService) Issue(name string, role Role) (Key, string, error) {
+	if strings.TrimSpace(name) == "" {
+		return Key{}, "", hotel.InvalidInput("invalid_key_name", "a key needs a name")
+	}
+	if !role.valid() {
+		return Key{}, "", hotel.InvalidInput("invalid_role", "role must be %s, %s or %s", RoleSearch, RoleBook,
+			RoleAdmin)
+	}
+	secret, prefix, err := generateKey()
+	if err != nil {
+		return Key{}, "", err
+	}
+	key, err := s.repository.create(Key{Name: name, Role: role, Prefix: prefix, Hash: hashKey(secret)})
+	if err != nil {
+		return Key{}, "", err
+	}
+	return key, secret, nil
+}
+
+//Revoke stops the key with prefix from authenticating
+func (s *KeyService) Revoke(prefix string) error {
+	revoked, err := s.repository.revoke(prefix)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return hotel.NotFound("api_key_not_found", "no api key in use has prefix %s", prefix)
+	}
+	return nil
+}
+
+func (s *KeyService) List() ([]Key, error) {
+	return s.repository.list()
+}
+
+//SigningKey is the key requests made with the api key secret are signed with, empty when signed requests are not
+//enabled. Like the secret, it cannot be recovered from what is stored
+func (s *KeyService) SigningKey(secret string) string {
+	if len(s.signingSecret) == 0 {
+		return ""
+	}
+	return signingKey(s.signingSecret, hashKey(secret))
+}
+
+//Sweep forgets the signatures too old to be accepted again anyway
+func (s *KeyService) Sweep() error {
+	return s.repository.sweepSignatures()
+}
+
+//Authenticate finds the key a request is made with, from either an "ApiKey <key>" or a
+//"Signature keyid=<prefix>,signature=<signature>,timestamp=<unix seconds>" authorization header
+func (s *KeyService) Authenticate(r *http.Request) (Key, error) {
+	header := r.Header.Get("Authorization")
+	switch {
+	case strings.HasPrefix(header, "ApiKey "):
+		secret := strings.TrimSpace(strings.TrimPrefix(header, "ApiKey "))
+		prefix, ok := parseKey(secret)
+		if !ok {
+			return Key{}, ErrInvalidKey
+		}
+		key, err := s.key(prefix)
+		if err != nil {
+			return Key{}, err
+		}
+		if subtle.ConstantTimeCompare([]byte(hashKey(secret)), []byte(key.Hash)) != 1 {
+			return Key{}, ErrInvalidKey
+		}
+		return key, nil
+	case strings.HasPrefix(header, "Signature "):
+		return s.verifySignature(r, strings.TrimPrefix(header, "Signature "))
+	}
+	return Key{}, ErrMissingCredentials
+}
+
+//verifySignature checks a signed request and that its signature has not been used before. The body is read to
+//check its hash and put back for the handlers
+func (s *KeyService) verifySignature(r *http.Request, params string) (Key, error) {
+	if len(s.signingSecret) == 0 {
+		return Key{}, ErrSignaturesDisabled
+	}
+	values := map[string]string{}
+	for _, pair := range strings.Split(params, ",") {
+		if kv := strings.SplitN(pair, "=", 2); len(kv) == 2 {
+			values[strings.TrimSpace(kv[0])] = kv[1]
+		}
+	}
+	timestamp, err := strconv.ParseInt(values["timestamp"], 10, 64)
+	if err != nil || values["keyid"] == "" || values["signature"] == "" {
+		return Key{}, ErrInvalidSignature
+	}
+	skew := s.now().Sub(time.Unix(timestamp, 0))
+	if skew > MaxSkew || skew < -MaxSkew {
+		return Key{}, ErrStaleSignature
+	}
+	body, err := readBody(r)
+	if err != nil {
+		return Key{}, err
+	}
+	key, err := s.key(values["keyid"])
+	if err != nil {
+		return Key{}, err
+	}
+	expected := Signature(signingKey(s.signingSecret, key.Hash), r.Method, r.URL.RequestURI(), values["timestamp"],
+		body)
+	if !hmac.Equal([]byte(expected), []byte(values["signature"])) {
+		return Key{}, ErrInvalidSignature
+	}
+	//past MaxSkew after its timestamp the signature is refused as stale, so it need not be kept longer
+	first, err := s.repository.useSignature(key.Id, values["signature"], time.Unix(timestamp, 0).Add(MaxSkew))
+	if err != nil {
+		return Key{}, errors.Wrap(err, "signature replay check failed")
+	}
+	if !first {
+		return Key{}, ErrReplayedSignature
+	}
+	return key, nil
+}
+
+//readBody reads the body of r, up to MaxSignedBody bytes, and puts it back so it can be read again
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, MaxSignedBody+1))
+	if err != nil {
+		return nil, hotel.InvalidInput("invalid_body", "the body could not be read")
+	}
+	if len(body) > MaxSignedBody {
+		return nil, hotel.InvalidInput("body_too_large", "signed requests may have a body of at most %d bytes",
+			MaxSignedBody)
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func (s *KeyService) key(prefix string) (Key, error) {
+	key, err := s.repository.byPrefix(prefix)
+	if err == sql.ErrNoRows {
+		return Key{}, ErrInvalidKey
+	}
+	if err != nil {
+		return Key{}, errors.Wrap(err, "api key lookup failed")
+	}
+	return key, nil
+}
+
+//Authorize checks that key may call an endpoint requiring role
+func Authorize(key Key, role Role) error {
+	if key.Role.Allows(role) {
+		return nil
+	}
+	return hotel.Forbidden("insufficient_role", "this endpoint needs a key with the %s role", role)
+}
+
+type keyContextKey struct{}
+
+//WithKey stores the key a request was authenticated with in ctx
+func WithKey(ctx context.Context, key Key) context.Context {
+	return context.WithValue(ctx, keyContextKey{}, key)
+}
+
+//KeyFrom returns the key stored by WithKey, or false for anonymous requests
+func KeyFrom(ctx context.Context) (Key, bool) {
+	key, ok := ctx.Value(keyContextKey{}).(Key)
+	return key, ok
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"hotels-service-template/hotel"
+	"io/ioutil"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testKey = "hst_0a1b2c3d_5e6f"
+
+const testSigningSecret = "0123456789abcdef0123456789abcdef"
+
+var serviceNow = time.Unix(1559215747, 0)
+
+func newService(repository *mockKeyRepository) *KeyService {
+	service := NewKeyService(repository, testSigningSecret)
+	service.now = func() time.Time { return serviceNow }
+	return service
+}
+
+func storedKey(role Role) Key {
+	return Key{Id: 3, Name: "search page", Role: role, Prefix: "0a1b2c3d", Hash: hashKey(testKey)}
+}
+
+func TestIssue(t *testing.T) {
+	repository := &mockKeyRepository{}
+	var created Key
+	repository.On("create", mock.AnythingOfType("auth.Key")).Run(func(args mock.Arguments) {
+		created = args[0].(Key)
+	}).Return(Key{Id: 3, Name: "ops", Role: RoleAdmin}, nil)
+
+	key, secret, err := newService(repository).Issue("ops", RoleAdmin)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Key{Id: 3, Name: "ops", Role: RoleAdmin}, key)
+	prefix, ok := parseKey(secret)
+	assert.True(t, ok)
+	assert.Equal(t, Key{Name: "ops", Role: RoleAdmin, Prefix: prefix, Hash: hashKey(secret)}, created)
+	assert.Len(t, secret, len("hst_")+8+len("_")+64)
+}
+
+func TestIssueShouldRejectUnknownRole(t *testing.T) {
+	repository := &mockKeyRepository{}
+
+	_, _, err := newService(repository).Issue("ops", Role("root"))
+
+	repository.AssertExpectations(t)
+	assert.Equal(t, "invalid_role", hotel.AsError(err).Code)
+}
+
+func TestRevokeShouldReportUnknownPrefix(t *testing.T) {
+	repository := &mockKeyRepository{}
+	repository.On("revoke", "0a1b2c3d").Return(false, nil)
+
+	err := newService(repository).Revoke("0a1b2c3d")
+
+	assert.Equal(t, hotel.KindNotFound, hotel.AsError(err).Kind)
+}
+
+func TestAuthenticate(t *testing.T) {
+	timestamp := strconv.FormatInt(serviceNow.Unix(), 10)
+	stale := strconv.FormatInt(serviceNow.Add(-MaxSkew-time.Second).Unix(), 10)
+	signature := func(key, timestamp, body string) string {
+		signingKey := newService(nil).SigningKey(key)
+		return "Signature keyid=0a1b2c3d,signature=" + Signature(signingKey, "POST", "/sync?x=1", timestamp,
+			[]byte(body)) + ",timestamp=" + timestamp
+	}
+
+	tt := []struct {
+		testDescription string
+		header          string
+		lookup          bool
+		lookupError     error
+		use             bool
+		firstUse        bool
+		expectedError   error
+	}{
+		{"ApiKey", "ApiKey " + testKey, true, nil, false, false, nil},
+		{"Signature", signature(testKey, timestamp, `{"x":1}`), true, nil, true, true, nil},
+		{"MissingHeader", "", false, nil, false, false, ErrMissingCredentials},
+		{"MalformedKey", "ApiKey secret", false, nil, false, false, ErrInvalidKey},
+		{"WrongSecret", "ApiKey hst_0a1b2c3d_0000", true, nil, false, false, ErrInvalidKey},
+		{"RevokedOrUnknownKey", "ApiKey " + testKey, true, sql.ErrNoRows, false, false, ErrInvalidKey},
+		{"WrongSignature", signature("hst_0a1b2c3d_0000", timestamp, `{"x":1}`), true, nil, false, false,
+			ErrInvalidSignature},
+		{"ChangedBody", signature(testKey, timestamp, `{"x":2}`), true, nil, false, false, ErrInvalidSignature},
+		{"ReplayedSignature", signature(testKey, timestamp, `{"x":1}`), true, nil, true, false, ErrReplayedSignature},
+		{"StaleSignature", signature(testKey, stale, `{"x":1}`), false, nil, false, false, ErrStaleSignature},
+		{"MalformedSignature", "Signature keyid=0a1b2c3d", false, nil, false, false, ErrInvalidSignature},
+	}
+	for _, tc := range tt {
+		t.Run(tc.testDescription, func(t *testing.T) {
+			repository := &mockKeyRepository{}
+			if tc.lookup {
+				repository.On("byPrefix", "0a1b2c3d").Return(storedKey(RoleSearch), tc.lookupError)
+			}
+			if tc.use {
+				repository.On("useSignature", int64(3), mock.AnythingOfType("string"), serviceNow.Add(MaxSkew)).
+					Return(tc.firstUse, nil)
+			}
+			r := httptest.NewRequest("POST", "/sync?x=1", strings.NewReader(`{"x":1}`))
+			r.Header.Set("Authorization", tc.header)
+
+			key, err := newService(repository).Authenticate(r)
+
+			repository.AssertExpectations(t)
+			assert.Equal(t, tc.expectedError, err)
+			if tc.expectedError == nil {
+				assert.Equal(t, storedKey(RoleSearch), key)
+				body, _ := ioutil.ReadAll(r.Body)
+				assert.Equal(t, `{"x":1}`, string(body))
+			}
+		})
+	}
+}
+
+func TestAuthenticateShouldRefuseSignaturesWithoutASigningSecret(t *testing.T) {
+	repository := &mockKeyRepository{}
+	service := NewKeyService(repository, "")
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	r := httptest.NewRequest("GET", "/sync", nil)
+	r.Header.Set("Authorization", "Signature keyid=0a1b2c3d,signature="+Signature(hashKey(testKey), "GET", "/sync",
+		timestamp, nil)+",timestamp="+timestamp)
+
+	_, err := service.Authenticate(r)
+
+	repository.AssertExpectations(t)
+	assert.Equal(t, ErrSignaturesDisabled, err)
+	assert.Equal(t, "", service.SigningKey(testKey))
+}
+
+func TestSigningKeyShouldNeedTheSigningSecret(t *testing.T) {
+	signingKey := newService(nil).SigningKey(testKey)
+
+	assert.Len(t, signingKey, 64)
+	assert.NotEqual(t, hashKey(testKey), signingKey)
+	assert.NotEqual(t, NewKeyService(nil, "another secret of at least 32 characters").SigningKey(testKey), signingKey)
+}
+
+func TestAuthenticateShouldRejectTooLargeSignedBodies(t *testing.T) {
+	repository := &mockKeyRepository{}
+	timestamp := strconv.FormatInt(serviceNow.Unix(), 10)
+	r := httptest.NewRequest("POST", "/sync", strings.NewReader(strings.Repeat("x", MaxSignedBody+1)))
+	r.Header.Set("Authorization", "Signature keyid=0a1b2c3d,signature=0a,timestamp="+timestamp)
+
+	_, err := newService(repository).Authenticate(r)
+
+	repository.AssertExpectations(t)
+	assert.Equal(t, "body_too_large", hotel.AsError(err).Code)
+}
+
+func TestAuthenticateShouldNotHideLookupErrors(t *testing.T) {
+	repository := &mockKeyRepository{}
+	repository.On("byPrefix", "0a1b2c3d").Return(Key{}, errors.New("connection refused"))
+	r := httptest.NewRequest("GET", "/search", nil)
+	r.Header.Set("Authorization", "ApiKey "+testKey)
+
+	_, err := newService(repository).Authenticate(r)
+
+	assert.Equal(t, hotel.KindInternal, hotel.AsError(err).Kind)
+}
+
+func TestAuthorize(t *testing.T) {
+	assert.NoError(t, Authorize(storedKey(RoleAdmin), RoleSearch))
+	assert.NoError(t, Authorize(storedKey(RoleAdmin), RoleAdmin))
+	assert.NoError(t, Authorize(storedKey(RoleSearch), RoleSearch))
+	assert.Equal(t, hotel.KindForbidden, hotel.AsError(Authorize(storedKey(RoleSearch), RoleAdmin)).Kind)
+	assert.NoError(t, Authorize(storedKey(RoleBook), RoleSearch))
+	assert.NoError(t, Authorize(storedKey(RoleAdmin), RoleBook))
+	assert.Equal(t, hotel.KindForbidden, hotel.AsError(Authorize(storedKey(RoleSearch), RoleBook)).Kind)
+	assert.Equal(t, hotel.KindForbidden, hotel.AsError(Authorize(storedKey(RoleBook), RoleAdmin)).Kind)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hotels-service-template/ean_simulator"
+	"net/http"
+	"os"
+)
+
+//ean-simulator serves a fake EAN Rapid API for local development. Point EAN_URL at http://localhost:8081/2.2 and
+//use the same API_KEY and SECRET_KEY as the service
+func main() {
+	addr := flag.String("addr", ":8081", "address to listen on")
+	regionsFile := flag.String("regions", "hotel/testdata/regions_stub.txt", "json file of regions by id to serve")
+	propertiesFile := flag.String("properties", "hotel/testdata/properties_stub.txt",
+		"json file of property content by id to serve")
+	pageSize := flag.Int("page-size", ean_simulator.DefaultPageSize, "regions or properties per page")
+	apiKey := flag.String("api-key", os.Getenv("API_KEY"), "api key expected in signatures")
+	secretKey := flag.String("secret-key", os.Getenv("SECRET_KEY"), "secret key expected in signatures")
+	faults := flag.String("faults", "", "faults for the first requests, e.g. 429,500,truncate,slow=2s")
+	flag.Parse()
+
+	regions, err := ean_simulator.LoadRegions(*regionsFile)
+	if err != nil {
+		fmt.Println("regions error", err)
+		os.Exit(1)
+	}
+	properties, err := ean_simulator.LoadProperties(*propertiesFile)
+	if err != nil {
+		fmt.Println("properties error", err)
+		os.Exit(1)
+	}
+	injected, err := ean_simulator.ParseFaults(*faults)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	simulator := ean_simulator.New(ean_simulator.Config{
+		ApiKey:     *apiKey,
+		SecretKey:  *secretKey,
+		PageSize:   *pageSize,
+		Regions:    regions,
+		Properties: properties,
+	})
+	simulator.Inject(injected...)
+
+	fmt.Printf("Serving %d regions and %d properties on %s, inject faults with POST %s\n", len(regions),
+		len(properties), *addr, ean_simulator.FaultsPath)
+	if err := http.ListenAndServe(*addr, simulator); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/pflag"
+	"hotels-service-template/auth"
+	"hotels-service-template/db/migrations"
+	"hotels-service-template/hotel"
+	"hotels-service-template/tracing"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+//syncRegions refreshes the regions, or the property content with --properties, once, as a job recorded like those
+//started over HTTP, so it can run from cron or a Kubernetes job instead of /update. Signals cancel the sync
+func syncRegions(args []string) int {
+	flags := pflag.NewFlagSet("sync", pflag.ContinueOnError)
+	properties := flags.Bool("properties", false, "sync the property content instead of the regions")
+	config, code, ok := configure(flags, args, true)
+	if !ok {
+		return code
+	}
+
+	logger := config.Log.logger(os.Stderr)
+	tracing.Default = config.Tracing.tracer(logger)
+	defer shutdownTracer(logger)
+	db := getDb(config.Database, logger)
+	client := hotel.NewClient(config.EAN.client(), logger)
+	kind, name := hotel.SyncRegions, "region"
+	var updater interface {
+		Update(ctx context.Context, progress func(hotel.SyncProgress)) (hotel.SyncSummary, error)
+	} = hotel.NewRegionService(hotel.NewRepository(db, logger), client, logger)
+	if *properties {
+		kind, name = hotel.SyncProperties, "property"
+		updater = hotel.NewPropertyService(hotel.NewPropertyRepository(db, logger), client, logger)
+	}
+	syncService := hotel.NewSyncService(kind, updater, hotel.NewSyncJobRepository(db, kind), logger)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	go func() {
+		<-stop
+		syncService.Stop()
+	}()
+
+	job, err := syncService.Run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, name+" sync failed:", err)
+		return 1
+	}
+	fmt.Printf("%s sync %d finished: %d added, %d changed, %d removed\n", name, job.Id, job.Summary.Added,
+		job.Summary.Changed, job.Summary.Removed)
+	return 0
+}
+
+//migrate applies, reverts or lists the schema migrations built into the binary
+func migrate(args []string) int {
+	flags := pflag.NewFlagSet("migrate", pflag.ContinueOnError)
+	dir := flags.String("dir", "", "directory holding the migration files, instead of those built in")
+	steps := flags.Int("steps", 1, "how many migrations down reverts")
+	config, code, ok := configure(flags, args, false)
+	if !ok {
+		return code
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: migrate up|down|status [flags]")
+		return 2
+	}
+
+	loaded, err := migrations.Embedded()
+	if *dir != "" {
+		loaded, err = migrations.Load(*dir)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "migrations error", err)
+		return 1
+	}
+	runner := migrations.NewRunner(getDb(config.Database, config.Log.logger(os.Stderr)), loaded)
+	switch flags.Arg(0) {
+	case "up":
+		applied, err := runner.Up()
+		for _, migration := range applied {
+			fmt.Printf("applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	case "down":
+		reverted, err := runner.Down(*steps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	case "status":
+		statuses, err := runner.Status(context.Background())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%d_%s\t%s\n", status.Version, status.Name, state)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "usage: migrate up|down|status [flags]")
+		return 2
+	}
+	return 0
+}
+
+//export writes the stored regions to stdout or a file
+func export(args []string) int {
+	flags := pflag.NewFlagSet("export", pflag.ContinueOnError)
+	format := flags.String("format", "jsonl",
+		"jsonl for one region per line, or json for an object of regions by id as EAN serves them")
+	output := flags.String("output", "-", "file to write to, - for stdout")
+	config, code, ok := configure(flags, args, false)
+	if !ok {
+		return code
+	}
+	if *format != "jsonl" && *format != "json" {
+		fmt.Fprintln(os.Stderr, "format must be jsonl or json")
+		return 2
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+	logger := config.Log.logger(os.Stderr)
+	regionService := hotel.NewRegionService(hotel.NewRepository(getDb(config.Database, logger), logger), nil, logger)
+	export := func(handle func(hotel.Region) error) error {
+		return regionService.Export(context.Background(), handle)
+	}
+	if err := writeRegions(w, *format, export); err != nil {
+		fmt.Fprintln(os.Stderr, "export failed:", err)
+		return 1
+	}
+	return 0
+}
+
+//keys issues, revokes or lists the api keys clients authenticate with
+func keys(args []string) int {
+	flags := pflag.NewFlagSet("keys", pflag.ContinueOnError)
+	name := flags.String("name", "", "who or what the issued key is for")
+	role := flags.String("role", string(auth.RoleSearch), "role of the issued key, search, book or admin")
+	config, code, ok := configure(flags, args, false)
+	if !ok {
+		return code
+	}
+	usage := "usage: keys issue --name NAME [--role search|book|admin] | keys revoke PREFIX | keys list"
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+
+	keyService := auth.NewKeyService(auth.NewKeyRepository(getDb(config.Database, config.Log.logger(os.Stderr))),
+		config.Auth.SigningSecret)
+	switch {
+	case flags.Arg(0) == "issue" && flags.NArg() == 1:
+		key, secret, err := keyService.Issue(*name, auth.Role(*role))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("issued %s key %s for %s, it will not be shown again:\n%s\n", key.Role, key.Prefix, key.Name, secret)
+		if signingKey := keyService.SigningKey(secret); signingKey != "" {
+			fmt.Printf("sign requests with:\n%s\n", signingKey)
+		}
+	case flags.Arg(0) == "revoke" && flags.NArg() == 2:
+		if err := keyService.Revoke(flags.Arg(1)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("revoked %s\n", flags.Arg(1))
+	case flags.Arg(0) == "list" && flags.NArg() == 1:
+		issued, err := keyService.List()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, key := range issued {
+			state := "active"
+			if key.RevokedAt != nil {
+				state = "revoked " + key.RevokedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%s\t%s\t%s\tissued %s\t%s\n", key.Prefix, key.Role, key.Name,
+				key.CreatedAt.Format("2006-01-02 15:04:05"), state)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	return 0
+}
+
+//writeRegions writes the regions handed over by export in format as they come, without holding them all
+func writeRegions(w io.Writer, format string, export func(handle func(hotel.Region) error) error) error {
+	buffered := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffered)
+	first := true
+	if format == "json" {
+		buffered.WriteString("{")
+	}
+	err := export(func(region hotel.Region) error {
+		if format == "jsonl" {
+			return encoder.Encode(region)
+		}
+		if !first {
+			buffered.WriteString(",")
+		}
+		first = false
+		id, _ := json.Marshal(region.Id)
+		buffered.Write(id)
+		buffered.WriteString(":")
+		return encoder.Encode(region)
+	})
+	if err != nil {
+		return err
+	}
+	if format == "json" {
+		buffered.WriteString("}\n")
+	}
+	return buffered.Flush()
+}
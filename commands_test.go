@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"hotels-service-template/hotel"
+	"testing"
+)
+
+func exportOf(regions ...hotel.Region) func(handle func(hotel.Region) error) error {
+	return func(handle func(hotel.Region) error) error {
+		for _, region := range regions {
+			if err := handle(region); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestWriteRegions(t *testing.T) {
+	regions := []hotel.Region{{Id: "2", Name: "Albania"}, {Id: "136", Name: "Nigeria"}}
+	tests := []struct {
+		format   string
+		regions  []hotel.Region
+		expected string
+	}{
+		{"jsonl", regions, `{"id":"2","type":"","name":"Albania","name_full":"","descriptor":"","ancestors":null,"Descendants":null,"property_ids":null,"property_ids_expanded":null}
+{"id":"136","type":"","name":"Nigeria","name_full":"","descriptor":"","ancestors":null,"Descendants":null,"property_ids":null,"property_ids_expanded":null}
+`},
+		{"json", regions, `{"2":{"id":"2","type":"","name":"Albania","name_full":"","descriptor":"","ancestors":null,"Descendants":null,"property_ids":null,"property_ids_expanded":null}
+,"136":{"id":"136","type":"","name":"Nigeria","name_full":"","descriptor":"","ancestors":null,"Descendants":null,"property_ids":null,"property_ids_expanded":null}
+}
+`},
+		{"json", nil, "{}\n"},
+		{"jsonl", nil, ""},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+
+		err := writeRegions(&out, test.format, exportOf(test.regions...))
+
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, out.String())
+	}
+}
+
+func TestWriteRegionsShouldReturnExportError(t *testing.T) {
+	var out bytes.Buffer
+
+	err := writeRegions(&out, "json", func(handle func(hotel.Region) error) error {
+		return errors.New("query error")
+	})
+
+	assert.EqualError(t, err, "query error")
+}
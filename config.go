@@ -1,9 +1,406 @@
 package main
 
-import "github.com/spf13/viper"
+import (
+	"fmt"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"hotels-service-template/hotel"
+	"hotels-service-template/logging"
+	"hotels-service-template/ratelimit"
+	"hotels-service-template/scheduler"
+	"hotels-service-template/tracing"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
 
-func init() {
-	viper.AutomaticEnv()
-	viper.SetDefault("API_KEY", "**add api key here**")
-	viper.SetDefault("SECRET_KEY", "**add secret key here**")
+const expediaClientUrl = "https://test.ean.com/2.2"
+
+//serviceName identifies the service in traces
+const serviceName = "hotels-service-template"
+
+//Config is everything the service reads at startup. Every key can come from the config file, from the environment
+//with dots replaced by underscores (DATABASE_DSN, SYNC_SCHEDULE, ...) or, for some, from flags, the later
+//overriding the earlier
+type Config struct {
+	Database  DatabaseConfig  `mapstructure:"database"`
+	HTTP      HTTPConfig      `mapstructure:"http"`
+	EAN       EANConfig       `mapstructure:"ean"`
+	Sync      SyncConfig      `mapstructure:"sync"`
+	Auth      AuthConfig      `mapstructure:"auth"`
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+	Log       LogConfig       `mapstructure:"log"`
+	Tracing   TracingConfig   `mapstructure:"tracing"`
+}
+
+type DatabaseConfig struct {
+	DSN             string        `mapstructure:"dsn"`
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	//MigrateOnStart applies pending migrations when serving, otherwise serve refuses to start on an outdated schema
+	MigrateOnStart bool `mapstructure:"migrate_on_start"`
+}
+
+type HTTPConfig struct {
+	Addr            string        `mapstructure:"addr"`
+	ReadTimeout     time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout    time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout     time.Duration `mapstructure:"idle_timeout"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	//DrainDelay is how long the service keeps serving while reporting not ready before it shuts down, giving load
+	//balancers time to notice and stop sending it requests
+	DrainDelay time.Duration `mapstructure:"drain_delay"`
+}
+
+type EANConfig struct {
+	URL       string   `mapstructure:"url"`
+	ApiKey    string   `mapstructure:"api_key"`
+	SecretKey string   `mapstructure:"secret_key"`
+	Language  string   `mapstructure:"language"`
+	Include   []string `mapstructure:"include"`
+}
+
+type SyncConfig struct {
+	//Schedule is empty by default, leaving region syncs to /update and /sync
+	Schedule string `mapstructure:"schedule"`
+	//PropertySchedule is empty by default, leaving property content syncs to /properties/sync
+	PropertySchedule string        `mapstructure:"property_schedule"`
+	Jitter           time.Duration `mapstructure:"jitter"`
+}
+
+type AuthConfig struct {
+	//AnonymousSearch lets search endpoints be called without an api key. Syncing always needs an admin key
+	AnonymousSearch bool `mapstructure:"anonymous_search"`
+	//SigningSecret derives the keys requests are signed with from the api keys. Keep it out of the database; empty
+	//disables signed requests
+	SigningSecret string `mapstructure:"signing_secret"`
+}
+
+type RateLimitConfig struct {
+	//Store keeps the limits in memory, per replica, or in postgres, shared by the replicas. Empty disables them
+	Store string `mapstructure:"store"`
+	//Default is the limit of routes not in Routes, such as "20/s:40" for 20 requests a second with bursts of 40
+	Default string `mapstructure:"default"`
+	//Routes are limits by route name, such as "search=10/s,update=2/h"
+	Routes string `mapstructure:"routes"`
+	//IP is the limit of each ip to the whole service, checked before the api key so failed authentications count
+	//too. Empty disables it
+	IP string `mapstructure:"ip"`
+	//TrustForwardedFor tells clients without api key apart by the ip a proxy adds to X-Forwarded-For, which is then
+	//also the Customer-Ip sent to EAN
+	TrustForwardedFor bool `mapstructure:"trust_forwarded_for"`
+}
+
+type LogConfig struct {
+	//Format is json or text
+	Format string `mapstructure:"format"`
+	//Level is debug, info, warn or error
+	Level string `mapstructure:"level"`
+}
+
+type TracingConfig struct {
+	//Exporter writes spans to stdout as json lines or sends them to an OpenTelemetry collector with otlp. Empty
+	//records no span, though the traceparent header of callers is still passed on
+	Exporter string `mapstructure:"exporter"`
+	//Endpoint is the OTLP over HTTP address of the collector, such as http://localhost:4318
+	Endpoint string `mapstructure:"endpoint"`
+	//SampleRatio is the share of the traces started here that are recorded, from 0 to 1. Traces started by a caller
+	//are recorded when the caller recorded its span
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+}
+
+//tracer creates the tracer exporting spans as configured. The config must have been validated
+func (config TracingConfig) tracer(logger *slog.Logger) *tracing.Tracer {
+	switch config.Exporter {
+	case "stdout":
+		return tracing.New(tracing.NewWriterExporter(os.Stdout), config.SampleRatio)
+	case "otlp":
+		return tracing.New(tracing.NewOTLPExporter(config.Endpoint, serviceName, logger), config.SampleRatio)
+	}
+	return tracing.New(nil, 0)
+}
+
+//logger creates the logger writing to w. The config must have been validated
+func (config LogConfig) logger(w io.Writer) *slog.Logger {
+	logger, _ := logging.New(w, config.Format, config.Level)
+	return logger
+}
+
+func (config EANConfig) client() hotel.ClientConfig {
+	return hotel.ClientConfig{
+		URL:       config.URL,
+		ApiKey:    config.ApiKey,
+		SecretKey: config.SecretKey,
+		Language:  config.Language,
+		Include:   config.Include,
+	}
+}
+
+var defaults = map[string]interface{}{
+	"database.dsn":               "dbname=hotels-poc user=postgres host=localhost port=5432 sslmode=disable",
+	"database.max_open_conns":    20,
+	"database.max_idle_conns":    5,
+	"database.conn_max_lifetime": "30m",
+	"database.migrate_on_start":  false,
+	"http.addr":                  ":8080",
+	"http.read_timeout":          "15s",
+	//generous so a sync run through /update can answer
+	"http.write_timeout":     "10m",
+	"http.idle_timeout":      "60s",
+	"http.shutdown_timeout":  "30s",
+	"http.drain_delay":       "5s",
+	"ean.url":                expediaClientUrl,
+	"ean.api_key":            "",
+	"ean.secret_key":         "",
+	"ean.language":           hotel.DefaultLanguage,
+	"ean.include":            hotel.DefaultInclude,
+	"sync.schedule":          "",
+	"sync.property_schedule": "",
+	"sync.jitter":            "5m",
+	"auth.anonymous_search":  false,
+	"auth.signing_secret":    "",
+	"rate_limit.store":       "memory",
+	"rate_limit.default":     "20/s:40",
+	"rate_limit.routes":      "update=6/h,sync=6/h,property_sync=6/h",
+	"rate_limit.ip":          "50/s:100",
+	//only safe behind a proxy that appends the client ip
+	"rate_limit.trust_forwarded_for": false,
+	"log.format":                     "json",
+	"log.level":                      "info",
+	"tracing.exporter":               "",
+	"tracing.endpoint":               "http://localhost:4318",
+	"tracing.sample_ratio":           1.0,
+}
+
+//envAliases keeps the environment variables used before the config was typed working
+var envAliases = map[string]string{
+	"ean.api_key":    "API_KEY",
+	"ean.secret_key": "SECRET_KEY",
+}
+
+var tracingExporters = map[string]bool{"": true, "stdout": true, "otlp": true}
+
+var rateLimitStores = map[string]bool{"": true, "memory": true, "postgres": true}
+
+var includeOptions = map[string]bool{"details": true, "property_ids": true, "property_ids_expanded": true}
+
+//configure loads the config of a command from args and the environment and validates it, including the EAN
+//credentials when needsEAN is set. ok is false when the command should exit with code straight away: after --help,
+//--print-config or with an invalid config
+func configure(flags *pflag.FlagSet, args []string, needsEAN bool) (config Config, code int, ok bool) {
+	config, printOnly, err := loadConfig(flags, args, os.Environ())
+	if err == pflag.ErrHelp {
+		return config, 0, false
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "config error", err)
+		return config, 2, false
+	}
+	if printOnly {
+		_ = printConfig(os.Stdout, config)
+	}
+	err = config.validate()
+	if err == nil && needsEAN {
+		err = config.EAN.validateCredentials()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return config, 2, false
+	}
+	return config, 0, !printOnly
+}
+
+//loadConfig adds the common config flags to flags, then reads the config from the file given with --config, the
+//environment and args. printOnly is set by --print-config. The config is not validated
+func loadConfig(flags *pflag.FlagSet, args []string, environ []string) (config Config, printOnly bool, err error) {
+	v := viper.New()
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+
+	configFile := flags.String("config", "", "config file (yaml, json or toml)")
+	flags.BoolVar(&printOnly, "print-config", false, "print the effective config with secrets redacted and exit")
+	flags.String("addr", "", "address to listen on (http.addr)")
+	flags.String("ean-url", "", "EAN Rapid base url (ean.url)")
+	flags.String("sync-schedule", "", "cron expression or @every interval to sync regions on (sync.schedule)")
+	if err := flags.Parse(args); err != nil {
+		return Config{}, false, err
+	}
+
+	env := map[string]string{}
+	for _, pair := range environ {
+		if kv := strings.SplitN(pair, "=", 2); len(kv) == 2 {
+			env[kv[0]] = kv[1]
+		}
+	}
+	for key := range defaults {
+		name := strings.ToUpper(strings.Replace(key, ".", "_", -1))
+		value, ok := env[name]
+		if !ok {
+			value, ok = env[envAliases[key]]
+		}
+		if ok {
+			v.Set(key, value)
+		}
+	}
+	//flags are set after the environment so they take precedence over it
+	for key, flag := range map[string]string{"http.addr": "addr", "ean.url": "ean-url",
+		"sync.schedule": "sync-schedule"} {
+		if flags.Changed(flag) {
+			v.Set(key, flags.Lookup(flag).Value.String())
+		}
+	}
+	if *configFile == "" {
+		*configFile = env["CONFIG_FILE"]
+	}
+	if *configFile != "" {
+		v.SetConfigFile(*configFile)
+		if err := v.ReadInConfig(); err != nil {
+			return Config{}, false, err
+		}
+	}
+
+	if err := v.Unmarshal(&config); err != nil {
+		return Config{}, false, err
+	}
+	return config, printOnly, nil
+}
+
+//validate reports every invalid setting at once
+func (config Config) validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(config.Database.DSN != "", "database.dsn is required")
+	check(config.Database.MaxOpenConns >= 0, "database.max_open_conns must not be negative")
+	check(config.Database.MaxIdleConns >= 0, "database.max_idle_conns must not be negative")
+	check(config.Database.MaxOpenConns == 0 || config.Database.MaxIdleConns <= config.Database.MaxOpenConns,
+		"database.max_idle_conns must not exceed database.max_open_conns")
+	check(config.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime must not be negative")
+
+	check(config.HTTP.Addr != "", "http.addr is required")
+	check(config.HTTP.ReadTimeout >= 0, "http.read_timeout must not be negative")
+	check(config.HTTP.WriteTimeout >= 0, "http.write_timeout must not be negative")
+	check(config.HTTP.IdleTimeout >= 0, "http.idle_timeout must not be negative")
+	check(config.HTTP.ShutdownTimeout > 0, "http.shutdown_timeout must be positive")
+	check(config.HTTP.DrainDelay >= 0, "http.drain_delay must not be negative")
+
+	eanUrl, err := url.Parse(config.EAN.URL)
+	check(err == nil && (eanUrl.Scheme == "http" || eanUrl.Scheme == "https") && eanUrl.Host != "",
+		"ean.url must be an absolute http or https url")
+	check(config.EAN.Language != "", "ean.language is required")
+	for _, include := range config.EAN.Include {
+		check(includeOptions[include], "ean.include %q is not one of details, property_ids, property_ids_expanded",
+			include)
+	}
+
+	if config.Sync.Schedule != "" {
+		_, err := scheduler.Parse(config.Sync.Schedule)
+		check(err == nil, "sync.schedule: %v", err)
+	}
+	if config.Sync.PropertySchedule != "" {
+		_, err := scheduler.Parse(config.Sync.PropertySchedule)
+		check(err == nil, "sync.property_schedule: %v", err)
+	}
+	check(config.Sync.Jitter >= 0, "sync.jitter must not be negative")
+
+	check(config.Auth.SigningSecret == "" || len(config.Auth.SigningSecret) >= 32,
+		"auth.signing_secret must be at least 32 characters")
+
+	check(rateLimitStores[config.RateLimit.Store], "rate_limit.store must be memory, postgres or empty")
+	_, err = ratelimit.ParseLimit(config.RateLimit.Default)
+	check(err == nil, "rate_limit.default: %v", err)
+	_, err = ratelimit.ParseLimits(config.RateLimit.Routes)
+	check(err == nil, "rate_limit.routes: %v", err)
+	if config.RateLimit.IP != "" {
+		_, err = ratelimit.ParseLimit(config.RateLimit.IP)
+		check(err == nil, "rate_limit.ip: %v", err)
+	}
+
+	_, err = logging.New(io.Discard, config.Log.Format, config.Log.Level)
+	check(err == nil, "log: %v", err)
+
+	check(tracingExporters[config.Tracing.Exporter], "tracing.exporter must be stdout, otlp or empty")
+	if config.Tracing.Exporter == "otlp" {
+		endpoint, err := url.Parse(config.Tracing.Endpoint)
+		check(err == nil && (endpoint.Scheme == "http" || endpoint.Scheme == "https") && endpoint.Host != "",
+			"tracing.endpoint must be an absolute http or https url")
+	}
+	check(config.Tracing.SampleRatio >= 0 && config.Tracing.SampleRatio <= 1,
+		"tracing.sample_ratio must be between 0 and 1")
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func (config EANConfig) validateCredentials() error {
+	var missing []string
+	if config.ApiKey == "" {
+		missing = append(missing, "ean.api_key")
+	}
+	if config.SecretKey == "" {
+		missing = append(missing, "ean.secret_key")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("invalid config: %s required", strings.Join(missing, " and "))
+	}
+	return nil
+}
+
+const redacted = "REDACTED"
+
+var dsnPassword = regexp.MustCompile(`password=('(\\'|[^'])*'|\S+)`)
+
+//redacted returns a copy of the config safe to print
+func (config Config) redacted() Config {
+	if dsn, err := url.Parse(config.Database.DSN); err == nil && dsn.User != nil {
+		if _, ok := dsn.User.Password(); ok {
+			dsn.User = url.UserPassword(dsn.User.Username(), redacted)
+		}
+		config.Database.DSN = dsn.String()
+	} else {
+		config.Database.DSN = dsnPassword.ReplaceAllString(config.Database.DSN, "password="+redacted)
+	}
+	if config.EAN.ApiKey != "" {
+		config.EAN.ApiKey = redacted
+	}
+	if config.EAN.SecretKey != "" {
+		config.EAN.SecretKey = redacted
+	}
+	if config.Auth.SigningSecret != "" {
+		config.Auth.SigningSecret = redacted
+	}
+	return config
+}
+
+//printConfig writes the config with secrets redacted, one "key: value" line per setting
+func printConfig(w io.Writer, config Config) error {
+	sections := reflect.ValueOf(config.redacted())
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Field(i)
+		prefix := sections.Type().Field(i).Tag.Get("mapstructure")
+		for j := 0; j < section.NumField(); j++ {
+			key := prefix + "." + section.Type().Field(j).Tag.Get("mapstructure")
+			value := section.Field(j).Interface()
+			if include, ok := value.([]string); ok {
+				value = strings.Join(include, ",")
+			}
+			if _, err := fmt.Fprintf(w, "%s: %v\n", key, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,365 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed schema/*.sql
+var schema embed.FS
+
+//lockKey identifies the Postgres advisory lock held while migrations are applied or reverted
+const lockKey = 7295402
+
+//baselineVersion is the migration creating baselineTable, which databases set up before migrations were recorded
+//already have. Such a database is taken over by recording it as applied instead of running it again
+const (
+	baselineVersion = 20190424122058
+	baselineTable   = "regions"
+)
+
+//Migration is one schema change, read from a VERSION_NAME.up.sql and VERSION_NAME.down.sql pair
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+//Checksum identifies the up statements, so a migration edited after it was applied can be detected
+func (migration Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(migration.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+//Status tells whether a migration was applied and when
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+//Embedded returns the migrations built into the binary from db/migrations/schema
+func Embedded() ([]Migration, error) {
+	return LoadFS(schema, "schema")
+}
+
+//Load reads the migrations in dir, ordered by version
+func Load(dir string) ([]Migration, error) {
+	return LoadFS(os.DirFS(dir), ".")
+}
+
+//LoadFS reads the migrations in dir of fsys, ordered by version
+func LoadFS(fsys fs.FS, dir string) ([]Migration, error) {
+	files, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, file := range files {
+		match := fileName.FindStringSubmatch(file.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		b, err := fs.ReadFile(fsys, path.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(b)
+		} else {
+			migration.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+type Runner struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewRunner(db *sql.DB, migrations []Migration) *Runner {
+	return &Runner{
+		db:         db,
+		migrations: migrations,
+	}
+}
+
+const createVersionTable = `create table if not exists schema_versions (
+	version bigint primary key,
+	name text not null,
+	checksum text not null,
+	applied_at timestamptz not null default now()
+)`
+
+type applied struct {
+	appliedAt time.Time
+	checksum  string
+}
+
+//Status lists every migration with when it was applied, if it was. It only reads the database
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	done, err := r.applied(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(r.migrations))
+	for _, migration := range r.migrations {
+		status := Status{Migration: migration}
+		if applied, ok := done[migration.Version]; ok {
+			status.AppliedAt = &applied.appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+//Check verifies the database schema is the one the migrations describe: every migration is applied and unchanged
+//since. Migrations newer than all of this build's are tolerated, as a newer release may have migrated the database
+//during a rolling deploy. It only reads the database, so it can be run by every readiness probe
+func (r *Runner) Check(ctx context.Context) error {
+	done, err := r.applied(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	if err := r.verify(done, true); err != nil {
+		return err
+	}
+	var pending []string
+	for _, migration := range r.migrations {
+		if _, ok := done[migration.Version]; !ok {
+			pending = append(pending, fmt.Sprintf("%d_%s", migration.Version, migration.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("schema is behind, pending migrations: %s", strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+//Up applies the pending migrations in version order, each in its own transaction, and returns those it applied.
+//It holds an advisory lock meanwhile, so replicas starting together apply each migration once. A database created
+//before migrations were recorded is taken over first, see baseline
+func (r *Runner) Up() ([]Migration, error) {
+	var done []Migration
+	err := r.locked(func(ctx context.Context, conn *sql.Conn, applied map[int64]applied) error {
+		for _, migration := range r.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			err := inTx(ctx, conn, migration.Up,
+				`insert into schema_versions (version, name, checksum) values ($1, $2, $3)`,
+				migration.Version, migration.Name, migration.Checksum())
+			if err != nil {
+				return fmt.Errorf("migration %d_%s up: %v", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+//Down reverts the last steps applied migrations, newest first, and returns those it reverted
+func (r *Runner) Down(steps int) ([]Migration, error) {
+	var done []Migration
+	err := r.locked(func(ctx context.Context, conn *sql.Conn, applied map[int64]applied) error {
+		for i := len(r.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := r.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+			}
+			err := inTx(ctx, conn, migration.Down, `delete from schema_versions where version = $1`,
+				migration.Version)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s down: %v", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+//locked runs migrate on a connection holding the migration lock, with the verified applied migrations. The lock
+//is waited for, as a concurrent runner is expected to finish
+func (r *Runner) locked(migrate func(ctx context.Context, conn *sql.Conn, applied map[int64]applied) error) error {
+	ctx := context.Background()
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `select pg_advisory_lock($1)`, lockKey); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `select pg_advisory_unlock($1)`, lockKey)
+
+	if _, err := conn.ExecContext(ctx, createVersionTable); err != nil {
+		return err
+	}
+	if err := r.baseline(ctx, conn); err != nil {
+		return err
+	}
+	applied, err := r.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if err := r.verify(applied, false); err != nil {
+		return err
+	}
+	return migrate(ctx, conn, applied)
+}
+
+//baseline records the baseline migration as applied when the database has its table but no migration recorded,
+//as databases created before this runner do
+func (r *Runner) baseline(ctx context.Context, conn *sql.Conn) error {
+	var migration *Migration
+	for i := range r.migrations {
+		if r.migrations[i].Version == baselineVersion {
+			migration = &r.migrations[i]
+		}
+	}
+	if migration == nil {
+		return nil
+	}
+	var recorded bool
+	if err := conn.QueryRowContext(ctx, `select exists (select 1 from schema_versions)`).Scan(&recorded); err != nil {
+		return err
+	}
+	if recorded {
+		return nil
+	}
+	var exists bool
+	if err := conn.QueryRowContext(ctx, `select to_regclass($1) is not null`, baselineTable).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	_, err := conn.ExecContext(ctx, `insert into schema_versions (version, name, checksum) values ($1, $2, $3)`,
+		migration.Version, migration.Name, migration.Checksum())
+	if err != nil {
+		return fmt.Errorf("migration %d_%s baseline: %v", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+//querier reads the applied migrations, from the pool or from the connection holding the migration lock
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+//applied reads the applied migrations by version. A database without the versions table has none applied yet
+func (r *Runner) applied(ctx context.Context, q querier) (map[int64]applied, error) {
+	done := map[int64]applied{}
+	var exists bool
+	if err := q.QueryRowContext(ctx, `select to_regclass('schema_versions') is not null`).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return done, nil
+	}
+	rows, err := q.QueryContext(ctx, `select version, applied_at, checksum from schema_versions`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int64
+		var migration applied
+		if err := rows.Scan(&version, &migration.appliedAt, &migration.checksum); err != nil {
+			return nil, err
+		}
+		done[version] = migration
+	}
+	return done, rows.Err()
+}
+
+//verify fails when an applied migration was edited since or is unknown to this binary, which happens when a newer
+//release migrated the database. With newer, migrations more recent than all of this binary's are let through
+func (r *Runner) verify(done map[int64]applied, newer bool) error {
+	known := map[int64]bool{}
+	var latest int64
+	for _, migration := range r.migrations {
+		if migration.Version > latest {
+			latest = migration.Version
+		}
+		known[migration.Version] = true
+		applied, ok := done[migration.Version]
+		if ok && applied.checksum != migration.Checksum() {
+			return fmt.Errorf("migration %d_%s was changed after it was applied", migration.Version, migration.Name)
+		}
+	}
+	var unknown []int64
+	for version := range done {
+		if !known[version] && !(newer && version > latest) {
+			unknown = append(unknown, version)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Slice(unknown, func(i, j int) bool {
+			return unknown[i] < unknown[j]
+		})
+		return fmt.Errorf("database has migrations this build does not know: %v", unknown)
+	}
+	return nil
+}
+
+//inTx runs the statements of a migration and records it in one transaction, so a failed migration leaves no trace
+func inTx(ctx context.Context, conn *sql.Conn, statements string, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
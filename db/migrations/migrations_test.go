@@ -0,0 +1,289 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var appliedAt = time.Date(2019, time.May, 30, 11, 29, 7, 0, time.UTC)
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "create_regions", Up: "create table regions ()", Down: "drop table regions"},
+	{Version: 2, Name: "add_name", Up: "alter table regions add name text", Down: "alter table regions drop name"},
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"2_add_name.up.sql":         "alter table regions add name text",
+		"2_add_name.down.sql":       "alter table regions drop name",
+		"1_create_regions.up.sql":   "create table regions ()",
+		"1_create_regions.down.sql": "drop table regions",
+		"README.md":                 "not a migration",
+	}
+	for name, content := range files {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+
+	migrations, err := Load(dir)
+
+	assert.NoError(t, err)
+	assert.Equal(t, testMigrations, migrations)
+}
+
+func TestLoadShouldRejectMigrationWithoutUp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "1_create_regions.down.sql"), []byte("drop"), 0600))
+
+	_, err = Load(dir)
+
+	assert.EqualError(t, err, "migration 1_create_regions has no up file")
+}
+
+func TestEmbedded(t *testing.T) {
+	migrations, err := Embedded()
+	assert.NoError(t, err)
+	onDisk, err := Load("schema")
+	assert.NoError(t, err)
+
+	assert.Equal(t, onDisk, migrations)
+	assert.True(t, len(migrations) > 1)
+	assert.Equal(t, int64(20190424122058), migrations[0].Version)
+	for _, migration := range migrations {
+		assert.NotEmpty(t, migration.Down, "%d_%s has no down file", migration.Version, migration.Name)
+	}
+}
+
+//expectApplied expects the versions table to be read, returning versions as applied with their checksums
+func expectApplied(mock sqlmock.Sqlmock, versions ...int64) {
+	mock.ExpectQuery("select to_regclass\\('schema_versions'\\) is not null").
+		WillReturnRows(mock.NewRows([]string{"exists"}).AddRow(true))
+	rows := mock.NewRows([]string{"version", "applied_at", "checksum"})
+	for _, version := range versions {
+		rows.AddRow(version, appliedAt, testMigrations[version-1].Checksum())
+	}
+	mock.ExpectQuery("select version, applied_at, checksum from schema_versions").
+		WillReturnRows(rows)
+}
+
+//expectLock expects the migration lock to be taken and the versions table to be created if need be
+func expectLock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("select pg_advisory_lock").WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("create table if not exists schema_versions").WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("select pg_advisory_unlock").WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestUpShouldApplyPendingMigrations(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	runner := NewRunner(db, testMigrations)
+
+	expectLock(mock)
+	expectApplied(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec("alter table regions add name text").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("insert into schema_versions").WithArgs(int64(2), "add_name", testMigrations[1].Checksum()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	applied, err := runner.Up()
+
+	assert.NoError(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, testMigrations[1:], applied)
+}
+
+func TestUpShouldRollbackFailedMigration(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	runner := NewRunner(db, testMigrations)
+
+	expectLock(mock)
+	expectApplied(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec("create table regions").WillReturnError(errors.New("relation exists"))
+	mock.ExpectRollback()
+	expectUnlock(mock)
+
+	applied, err := runner.Up()
+
+	assert.EqualError(t, err, "migration 1_create_regions up: relation exists")
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Empty(t, applied)
+}
+
+func TestDownShouldRevertNewestMigrations(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	runner := NewRunner(db, testMigrations)
+
+	expectLock(mock)
+	expectApplied(mock, 1, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec("alter table regions drop name").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("delete from schema_versions").WithArgs(int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	reverted, err := runner.Down(1)
+
+	assert.NoError(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, testMigrations[1:], reverted)
+}
+
+func TestStatus(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	runner := NewRunner(db, testMigrations)
+
+	expectApplied(mock, 1)
+
+	statuses, err := runner.Status(context.Background())
+
+	assert.NoError(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []Status{
+		{Migration: testMigrations[0], AppliedAt: &appliedAt},
+		{Migration: testMigrations[1]},
+	}, statuses)
+}
+
+func TestUpShouldRefuseChangedMigrations(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	changed := []Migration{{Version: 1, Name: "create_regions", Up: "create table regions (id text)"}}
+	runner := NewRunner(db, changed)
+
+	expectLock(mock)
+	expectApplied(mock, 1)
+	expectUnlock(mock)
+
+	applied, err := runner.Up()
+
+	assert.EqualError(t, err, "migration 1_create_regions was changed after it was applied")
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Empty(t, applied)
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		testDescription string
+		migrations      []Migration
+		applied         []int64
+		expectedError   string
+	}{
+		{"up to date", testMigrations, []int64{1, 2}, ""},
+		{"pending migrations", testMigrations, []int64{1}, "schema is behind, pending migrations: 2_add_name"},
+		{"newer database", testMigrations[:1], []int64{1, 2}, ""},
+		{"unknown older migration", testMigrations[1:], []int64{1, 2},
+			"database has migrations this build does not know: [1]"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.testDescription, func(t *testing.T) {
+			db, mock, _ := sqlmock.New()
+			runner := NewRunner(db, tc.migrations)
+			expectApplied(mock, tc.applied...)
+
+			err := runner.Check(context.Background())
+
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+			assert.Nil(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCheckShouldNotCreateTheVersionsTable(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	runner := NewRunner(db, testMigrations)
+
+	mock.ExpectQuery("select to_regclass").WillReturnRows(mock.NewRows([]string{"exists"}).AddRow(false))
+
+	err := runner.Check(context.Background())
+
+	assert.EqualError(t, err, "schema is behind, pending migrations: 1_create_regions, 2_add_name")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestUpShouldRefuseUnknownMigrations(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	runner := NewRunner(db, testMigrations[:1])
+
+	expectLock(mock)
+	expectApplied(mock, 1, 2)
+	expectUnlock(mock)
+
+	applied, err := runner.Up()
+
+	assert.EqualError(t, err, "database has migrations this build does not know: [2]")
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Empty(t, applied)
+}
+
+func TestUpShouldTakeOverADatabaseCreatedBeforeMigrations(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	baseline := Migration{Version: baselineVersion, Name: "create_regions", Up: "create table regions ()"}
+	runner := NewRunner(db, []Migration{baseline})
+
+	expectLock(mock)
+	mock.ExpectQuery(`select exists \(select 1 from schema_versions\)`).
+		WillReturnRows(mock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`select to_regclass\(\$1\) is not null`).WithArgs("regions").
+		WillReturnRows(mock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec("insert into schema_versions").WithArgs(int64(baselineVersion), "create_regions",
+		baseline.Checksum()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`select to_regclass\('schema_versions'\) is not null`).
+		WillReturnRows(mock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("select version, applied_at, checksum from schema_versions").
+		WillReturnRows(mock.NewRows([]string{"version", "applied_at", "checksum"}).
+			AddRow(baselineVersion, appliedAt, baseline.Checksum()))
+	expectUnlock(mock)
+
+	applied, err := runner.Up()
+
+	assert.NoError(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Empty(t, applied, "create table regions is not run again")
+}
+
+func TestUpShouldApplyTheBaselineToAnEmptyDatabase(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	baseline := Migration{Version: baselineVersion, Name: "create_regions", Up: "create table regions ()"}
+	runner := NewRunner(db, []Migration{baseline})
+
+	expectLock(mock)
+	mock.ExpectQuery(`select exists \(select 1 from schema_versions\)`).
+		WillReturnRows(mock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`select to_regclass\(\$1\) is not null`).WithArgs("regions").
+		WillReturnRows(mock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`select to_regclass\('schema_versions'\) is not null`).
+		WillReturnRows(mock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("select version, applied_at, checksum from schema_versions").
+		WillReturnRows(mock.NewRows([]string{"version", "applied_at", "checksum"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(`create table regions \(\)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("insert into schema_versions").WithArgs(int64(baselineVersion), "create_regions",
+		baseline.Checksum()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	applied, err := runner.Up()
+
+	assert.NoError(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []Migration{baseline}, applied)
+}
@@ -0,0 +1,6 @@
+drop index if exists regions_name_full_trgm_idx;
+drop index if exists regions_name_trgm_idx;
+drop index if exists regions_name_full_prefix_idx;
+drop index if exists regions_name_prefix_idx;
+
+alter table regions drop column name_full;
@@ -0,0 +1,10 @@
+create extension if not exists pg_trgm;
+
+alter table regions add column name_full text;
+
+update regions set name_full = data ->> 'name_full';
+
+create index regions_name_prefix_idx on regions (lower(name) text_pattern_ops);
+create index regions_name_full_prefix_idx on regions (lower(name_full) text_pattern_ops);
+create index regions_name_trgm_idx on regions using gin (lower(name) gin_trgm_ops);
+create index regions_name_full_trgm_idx on regions using gin (lower(name_full) gin_trgm_ops);
@@ -0,0 +1,3 @@
+drop table region_properties;
+drop table region_descendants;
+drop table region_ancestors;
@@ -0,0 +1,57 @@
+create table region_ancestors (
+  region_id bigint not null references regions (id) on delete cascade,
+  ancestor_id bigint not null,
+  ancestor_type text not null,
+  position int not null,
+  primary key (region_id, ancestor_id)
+);
+
+create index region_ancestors_ancestor_id_idx on region_ancestors (ancestor_id);
+
+create table region_descendants (
+  region_id bigint not null references regions (id) on delete cascade,
+  descendant_id bigint not null,
+  descendant_type text not null,
+  primary key (region_id, descendant_id)
+);
+
+create index region_descendants_type_idx on region_descendants (region_id, descendant_type);
+create index region_descendants_descendant_id_idx on region_descendants (descendant_id);
+
+create table region_properties (
+  region_id bigint not null references regions (id) on delete cascade,
+  property_id text not null,
+  expanded boolean not null,
+  primary key (region_id, property_id)
+);
+
+create index region_properties_property_id_idx on region_properties (property_id);
+
+insert into region_ancestors (region_id, ancestor_id, ancestor_type, position)
+select r.id, (a.value ->> 'Id')::bigint, a.value ->> 'Type', a.position - 1
+from regions r,
+  jsonb_array_elements(case when jsonb_typeof(r.data -> 'ancestors') = 'array' then r.data -> 'ancestors'
+    else '[]' end) with ordinality a(value, position)
+on conflict do nothing;
+
+insert into region_descendants (region_id, descendant_id, descendant_type)
+select r.id, d.id::bigint, t.key
+from regions r,
+  jsonb_each(case when jsonb_typeof(r.data -> 'Descendants') = 'object' then r.data -> 'Descendants'
+    else '{}' end) t,
+  jsonb_array_elements_text(case when jsonb_typeof(t.value) = 'array' then t.value else '[]' end) d(id)
+on conflict do nothing;
+
+insert into region_properties (region_id, property_id, expanded)
+select r.id, p.id, false
+from regions r,
+  jsonb_array_elements_text(case when jsonb_typeof(r.data -> 'property_ids') = 'array' then r.data -> 'property_ids'
+    else '[]' end) p(id)
+on conflict do nothing;
+
+insert into region_properties (region_id, property_id, expanded)
+select r.id, p.id, true
+from regions r,
+  jsonb_array_elements_text(case when jsonb_typeof(r.data -> 'property_ids_expanded') = 'array'
+    then r.data -> 'property_ids_expanded' else '[]' end) p(id)
+on conflict do nothing;
@@ -0,0 +1,7 @@
+drop index if exists regions_live_idx;
+
+delete from regions where deleted_at is not null;
+
+alter table regions drop column deleted_at;
+alter table regions drop column updated_at;
+alter table regions drop column content_hash;
@@ -0,0 +1,5 @@
+alter table regions add column content_hash text;
+alter table regions add column updated_at timestamptz not null default now();
+alter table regions add column deleted_at timestamptz;
+
+create index regions_live_idx on regions (id) where deleted_at is null;
@@ -0,0 +1 @@
+alter table regions drop column last_seen_at;
@@ -0,0 +1,3 @@
+alter table regions add column last_seen_at timestamptz;
+
+update regions set last_seen_at = updated_at where deleted_at is null;
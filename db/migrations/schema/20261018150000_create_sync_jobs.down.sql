@@ -0,0 +1 @@
+drop table sync_jobs;
@@ -0,0 +1,15 @@
+create table sync_jobs (
+  id bigserial primary key,
+  state text not null,
+  pages_fetched int not null default 0,
+  regions_processed int not null default 0,
+  added int not null default 0,
+  changed int not null default 0,
+  removed int not null default 0,
+  error text,
+  started_at timestamptz not null default now(),
+  finished_at timestamptz
+);
+
+-- at most one job can be running at a time
+create unique index sync_jobs_running_idx on sync_jobs (state) where state = 'running';
@@ -0,0 +1,10 @@
+create table api_keys (
+  id bigserial primary key,
+  name text not null,
+  role text not null,
+  -- prefix is the public part of a key, used to find it; the key itself is only stored as a sha256 hash
+  prefix text not null unique,
+  hash text not null,
+  created_at timestamptz not null default now(),
+  revoked_at timestamptz
+);
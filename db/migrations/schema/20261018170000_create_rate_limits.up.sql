@@ -0,0 +1,7 @@
+-- token buckets shared by the replicas when rate limits are kept in postgres
+create table rate_limits (
+  key text primary key,
+  tokens double precision not null,
+  allowed boolean not null,
+  updated_at timestamptz not null default now()
+);
@@ -0,0 +1,2 @@
+drop table property_amenities;
+drop table properties;
@@ -0,0 +1,25 @@
+create table properties (
+  id text primary key,
+  name text not null,
+  category_id text,
+  star_rating numeric(2, 1),
+  latitude double precision,
+  longitude double precision,
+  country_code text,
+  data jsonb not null,
+  content_hash text not null,
+  last_seen_at timestamptz not null,
+  updated_at timestamptz not null default now(),
+  deleted_at timestamptz
+);
+
+create index properties_live_idx on properties (id) where deleted_at is null;
+
+create table property_amenities (
+  property_id text not null references properties (id) on delete cascade,
+  amenity_id text not null,
+  name text not null,
+  primary key (property_id, amenity_id)
+);
+
+create index property_amenities_amenity_id_idx on property_amenities (amenity_id);
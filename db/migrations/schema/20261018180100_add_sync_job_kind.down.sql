@@ -0,0 +1,6 @@
+delete from sync_jobs where kind <> 'regions';
+drop index sync_jobs_running_idx;
+create unique index sync_jobs_running_idx on sync_jobs (state) where state = 'running';
+
+alter table sync_jobs drop column properties_processed;
+alter table sync_jobs drop column kind;
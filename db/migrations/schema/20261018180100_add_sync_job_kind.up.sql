@@ -0,0 +1,6 @@
+alter table sync_jobs add column kind text not null default 'regions';
+alter table sync_jobs add column properties_processed int not null default 0;
+
+-- at most one job of each kind can be running at a time
+drop index sync_jobs_running_idx;
+create unique index sync_jobs_running_idx on sync_jobs (kind) where state = 'running';
@@ -0,0 +1,2 @@
+drop table itinerary_transitions;
+drop table itineraries;
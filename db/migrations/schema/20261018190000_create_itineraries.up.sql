@@ -0,0 +1,31 @@
+create table itineraries (
+  id bigserial primary key,
+  -- an idempotency key is unique to the api key booking with it, a repeated key returns the itinerary it created
+  api_key_id bigint not null references api_keys (id),
+  idempotency_key text not null,
+  request_hash text not null,
+  state text not null check (state in ('held', 'confirmed', 'cancelled', 'failed')),
+  property_id text not null,
+  room_id text not null,
+  rate_id text not null,
+  room_count int not null,
+  total_value text not null,
+  total_currency text not null,
+  ean_itinerary_id text,
+  -- ean_token lets the itinerary be retrieved and cancelled on EAN; customer details are never stored
+  ean_token text,
+  failure text,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now(),
+  unique (api_key_id, idempotency_key)
+);
+
+create table itinerary_transitions (
+  itinerary_id bigint not null references itineraries (id) on delete cascade,
+  from_state text,
+  to_state text not null,
+  reason text,
+  at timestamptz not null default now()
+);
+
+create index itinerary_transitions_itinerary_id_idx on itinerary_transitions (itinerary_id, at);
@@ -0,0 +1,7 @@
+-- signatures of the signed requests accepted in the last minutes, so a captured request cannot be replayed
+create table api_key_signatures (
+  signature text primary key,
+  key_id bigint not null references api_keys (id) on delete cascade,
+  expires_at timestamptz not null
+);
+create index api_key_signatures_expires_at on api_key_signatures (expires_at);
@@ -0,0 +1,51 @@
+package ean_simulator
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Fault changes how the simulator answers one request
+type Fault struct {
+	//Status, when set, is returned instead of the page
+	Status int `json:"status,omitempty"`
+	//RetryAfter is sent in seconds with Status
+	RetryAfter int `json:"retry_after,omitempty"`
+	//Truncate cuts the page body in half
+	Truncate bool `json:"truncate,omitempty"`
+	//Delay holds the response back, given in nanoseconds in json
+	Delay time.Duration `json:"delay,omitempty"`
+}
+
+//ParseFaults reads a comma separated list of faults such as "429,500,truncate,slow=2s"
+func ParseFaults(spec string) ([]Fault, error) {
+	var faults []Fault
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "":
+		case part == "truncate":
+			faults = append(faults, Fault{Truncate: true})
+		case strings.HasPrefix(part, "slow="):
+			delay, err := time.ParseDuration(strings.TrimPrefix(part, "slow="))
+			if err != nil {
+				return nil, fmt.Errorf("invalid fault %q: %v", part, err)
+			}
+			faults = append(faults, Fault{Delay: delay})
+		default:
+			status, err := strconv.Atoi(part)
+			if err != nil || http.StatusText(status) == "" {
+				return nil, fmt.Errorf("invalid fault %q", part)
+			}
+			fault := Fault{Status: status}
+			if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+				fault.RetryAfter = 1
+			}
+			faults = append(faults, fault)
+		}
+	}
+	return faults, nil
+}
@@ -0,0 +1,30 @@
+package ean_simulator
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseFaults(t *testing.T) {
+	faults, err := ParseFaults("429, 500,truncate,slow=2s")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Fault{
+		{Status: 429, RetryAfter: 1},
+		{Status: 500},
+		{Truncate: true},
+		{Delay: 2 * time.Second},
+	}, faults)
+}
+
+func TestParseFaultsShouldRejectUnknownFaults(t *testing.T) {
+	_, err := ParseFaults("500,boom")
+	assert.EqualError(t, err, `invalid fault "boom"`)
+
+	_, err = ParseFaults("slow=soon")
+	assert.EqualError(t, err, `invalid fault "slow=soon": time: invalid duration "soon"`)
+
+	_, err = ParseFaults("999")
+	assert.EqualError(t, err, `invalid fault "999"`)
+}
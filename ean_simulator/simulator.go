@@ -0,0 +1,351 @@
+package ean_simulator
+
+import (
+	"compress/gzip"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultPageSize = 100
+	//DefaultMaxSkew is how far the timestamp of a signature may be from the simulator clock, as on Rapid
+	DefaultMaxSkew = 5 * time.Minute
+	//FaultsPath accepts a json array of faults to inject into the next requests
+	FaultsPath = "/_simulator/faults"
+)
+
+type Config struct {
+	ApiKey    string
+	SecretKey string
+	PageSize  int
+	MaxSkew   time.Duration
+	//Regions by id, as served by Rapid
+	Regions map[string]json.RawMessage
+	//Properties by id, as served by Rapid on properties/content
+	Properties map[string]json.RawMessage
+}
+
+//Simulator is a fake EAN Rapid API serving regions and property content with Link header pagination, gzip and
+//signature checks, and pricing availability of the properties it has content for. Faults can be injected into it
+type Simulator struct {
+	config      Config
+	ids         []string
+	propertyIds []string
+	now         func() time.Time
+	lock        sync.Mutex
+	faults      []Fault
+	requests    int
+}
+
+func New(config Config) *Simulator {
+	if config.PageSize <= 0 {
+		config.PageSize = DefaultPageSize
+	}
+	if config.MaxSkew <= 0 {
+		config.MaxSkew = DefaultMaxSkew
+	}
+	return &Simulator{config: config, ids: sortedIds(config.Regions), propertyIds: sortedIds(config.Properties),
+		now: time.Now}
+}
+
+//sortedIds lists the ids of items in numeric order. Pages must be stable across requests, so items are served in
+//id order
+func sortedIds(items map[string]json.RawMessage) []string {
+	ids := make([]string, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if len(ids[i]) != len(ids[j]) {
+			return len(ids[i]) < len(ids[j])
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+//LoadRegions reads a file holding a json object of regions by id, such as hotel/testdata/regions_stub.txt
+func LoadRegions(path string) (map[string]json.RawMessage, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	regions := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &regions); err != nil {
+		return nil, err
+	}
+	return regions, nil
+}
+
+//LoadProperties reads a file holding a json object of property content by id, such as
+//hotel/testdata/properties_stub.txt
+func LoadProperties(path string) (map[string]json.RawMessage, error) {
+	return LoadRegions(path)
+}
+
+//Inject queues faults, each applied to one of the next region or property requests in order
+func (s *Simulator) Inject(faults ...Fault) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.faults = append(s.faults, faults...)
+}
+
+//Requests returns how many region and property requests were received, including rejected ones
+func (s *Simulator) Requests() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.requests
+}
+
+func (s *Simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == FaultsPath && r.Method == http.MethodPost:
+		s.injectFaults(w, r)
+	case strings.HasSuffix(r.URL.Path, "/regions") && r.Method == http.MethodGet:
+		s.list(w, r, s.ids, s.config.Regions)
+	case strings.HasSuffix(r.URL.Path, "/properties/content") && r.Method == http.MethodGet:
+		s.list(w, r, s.propertyIds, s.config.Properties)
+	case strings.HasSuffix(r.URL.Path, "/properties/availability") && r.Method == http.MethodGet:
+		s.availability(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "resource_not_found", "The requested resource does not exist.")
+	}
+}
+
+func (s *Simulator) injectFaults(w http.ResponseWriter, r *http.Request) {
+	var faults []Fault
+	if err := json.NewDecoder(r.Body).Decode(&faults); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_input", err.Error())
+		return
+	}
+	s.Inject(faults...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//admit applies the next fault to a request and authenticates it. It returns the fault, whose Truncate is left to
+//the caller, and false when the request was already answered
+func (s *Simulator) admit(w http.ResponseWriter, r *http.Request) (Fault, bool) {
+	fault := s.nextFault()
+	if fault.Delay > 0 {
+		select {
+		case <-time.After(fault.Delay):
+		case <-r.Context().Done():
+			return fault, false
+		}
+	}
+	if fault.Status != 0 {
+		if fault.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(fault.RetryAfter))
+		}
+		writeError(w, fault.Status, "simulated_fault", "Fault injected by the simulator.")
+		return fault, false
+	}
+	if err := s.authenticate(r.Header.Get("Authorization")); err != nil {
+		writeError(w, http.StatusUnauthorized, "request_unauthenticated", err.Error())
+		return fault, false
+	}
+	return fault, true
+}
+
+//list serves a page of items, whose ids are in serving order, applying the next fault first
+func (s *Simulator) list(w http.ResponseWriter, r *http.Request, ids []string, items map[string]json.RawMessage) {
+	fault, ok := s.admit(w, r)
+	if !ok {
+		return
+	}
+	if r.URL.Query().Get("language") == "" {
+		writeError(w, http.StatusBadRequest, "invalid_input", "language is required.")
+		return
+	}
+
+	offset := 0
+	if token := r.URL.Query().Get("token"); token != "" {
+		var err error
+		offset, err = strconv.Atoi(token)
+		if err != nil || offset < 0 || offset > len(ids) {
+			writeError(w, http.StatusBadRequest, "invalid_input", "token is invalid.")
+			return
+		}
+	}
+	end := offset + s.config.PageSize
+	if end > len(ids) {
+		end = len(ids)
+	}
+	page := make(map[string]json.RawMessage, end-offset)
+	for _, id := range ids[offset:end] {
+		page[id] = items[id]
+	}
+	if end < len(ids) {
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"; expires="%s"`, nextLink(r, end),
+			s.now().Add(time.Hour).UTC().Format(time.RFC3339)))
+	}
+	write(w, r, page, fault)
+}
+
+//availability prices a stay in the requested properties that the simulator has content for: one room with one
+//rate each, at a nightly price per room derived from the property id
+func (s *Simulator) availability(w http.ResponseWriter, r *http.Request) {
+	fault, ok := s.admit(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	for _, required := range []string{"checkin", "checkout", "occupancy", "currency", "country_code", "language",
+		"property_id"} {
+		if query.Get(required) == "" {
+			writeError(w, http.StatusBadRequest, "invalid_input", required+" is required.")
+			return
+		}
+	}
+	checkIn, err := time.Parse("2006-01-02", query.Get("checkin"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_input", "checkin is invalid.")
+		return
+	}
+	checkOut, err := time.Parse("2006-01-02", query.Get("checkout"))
+	if err != nil || !checkOut.After(checkIn) {
+		writeError(w, http.StatusBadRequest, "invalid_input", "checkout is invalid.")
+		return
+	}
+	nights := int(checkOut.Sub(checkIn).Hours() / 24)
+
+	properties := []map[string]interface{}{}
+	for _, id := range query["property_id"] {
+		if _, ok := s.config.Properties[id]; !ok {
+			continue
+		}
+		number, _ := strconv.Atoi(id)
+		total := map[string]interface{}{"value": fmt.Sprintf("%d.00", nights*(80+number%120)),
+			"currency": query.Get("currency")}
+		//as on Rapid, an occupancy is priced for one room, once however many rooms of it are asked for
+		pricing := map[string]interface{}{}
+		for _, occupancy := range query["occupancy"] {
+			pricing[occupancy] = map[string]interface{}{"totals": map[string]interface{}{
+				"inclusive": map[string]interface{}{"billable_currency": total, "request_currency": total}}}
+		}
+		roomId, rateId := id+"01", id+"0101"
+		properties = append(properties, map[string]interface{}{
+			"property_id": id,
+			"status":      "available",
+			"rooms": []interface{}{map[string]interface{}{
+				"id":        roomId,
+				"room_name": "Standard Room",
+				"rates": []interface{}{map[string]interface{}{
+					"id":                rateId,
+					"status":            "available",
+					"available_rooms":   5,
+					"refundable":        true,
+					"occupancy_pricing": pricing,
+					"bed_groups": map[string]interface{}{"37321": map[string]interface{}{
+						"id":          "37321",
+						"description": "1 King Bed",
+						"links": map[string]interface{}{"price_check": map[string]string{"method": "GET",
+							"href": fmt.Sprintf("/2.4/properties/%s/rooms/%s/rates/%s?token=%s", id, roomId, rateId,
+								PriceCheckToken(id, roomId, rateId))}},
+					}},
+				}},
+			}},
+		})
+	}
+	write(w, r, properties, fault)
+}
+
+//PriceCheckToken is the token the simulator gives the only bed group of a rate
+func PriceCheckToken(propertyId, roomId, rateId string) string {
+	return "sim-" + propertyId + "-" + roomId + "-" + rateId
+}
+
+//write answers with value as json, gzipped when the request accepts it and cut in half when fault truncates
+func write(w http.ResponseWriter, r *http.Request, value interface{}, fault Fault) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unknown_internal_error", err.Error())
+		return
+	}
+	if fault.Truncate {
+		body = body[:len(body)/2]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	var out io.Writer = w
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		gzipWriter := gzip.NewWriter(w)
+		defer gzipWriter.Close()
+		out = gzipWriter
+	}
+	_, _ = out.Write(body)
+}
+
+func (s *Simulator) nextFault() Fault {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.requests++
+	if len(s.faults) == 0 {
+		return Fault{}
+	}
+	fault := s.faults[0]
+	s.faults = s.faults[1:]
+	return fault
+}
+
+//authenticate checks an "EAN apikey=...,signature=...,timestamp=..." header the way Rapid does: the signature is
+//the hex sha512 of api key, secret key and timestamp, and the timestamp must be recent
+func (s *Simulator) authenticate(header string) error {
+	if !strings.HasPrefix(header, "EAN ") {
+		return fmt.Errorf("authorization header is missing or malformed")
+	}
+	values := map[string]string{}
+	for _, pair := range strings.Split(strings.TrimPrefix(header, "EAN "), ",") {
+		if kv := strings.SplitN(pair, "=", 2); len(kv) == 2 {
+			values[strings.TrimSpace(kv[0])] = kv[1]
+		}
+	}
+	if values["apikey"] != s.config.ApiKey {
+		return fmt.Errorf("api key is not valid")
+	}
+	timestamp, err := strconv.ParseInt(values["timestamp"], 10, 64)
+	if err != nil {
+		return fmt.Errorf("timestamp is not valid")
+	}
+	skew := s.now().Sub(time.Unix(timestamp, 0))
+	if skew > s.config.MaxSkew || skew < -s.config.MaxSkew {
+		return fmt.Errorf("timestamp is outside the allowed window")
+	}
+	if values["signature"] != Signature(s.config.ApiKey, s.config.SecretKey, values["timestamp"]) {
+		return fmt.Errorf("signature is not valid")
+	}
+	return nil
+}
+
+func Signature(apiKey, secretKey, timestamp string) string {
+	hash := sha512.Sum512([]byte(apiKey + secretKey + timestamp))
+	return hex.EncodeToString(hash[:])
+}
+
+//nextLink is the url of the request with its token moved to offset
+func nextLink(r *http.Request, offset int) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	query := r.URL.Query()
+	query.Set("token", strconv.Itoa(offset))
+	return fmt.Sprintf("%s://%s%s?%s", scheme, r.Host, r.URL.Path, query.Encode())
+}
+
+func writeError(w http.ResponseWriter, status int, errorType string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"type": errorType, "message": message})
+}
@@ -0,0 +1,215 @@
+package ean_simulator
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var simulatorNow = time.Unix(1559215747, 0)
+
+func newSimulator(pageSize int) *Simulator {
+	regions := map[string]json.RawMessage{}
+	for _, id := range []string{"1", "2", "10", "136"} {
+		regions[id] = json.RawMessage(fmt.Sprintf(`{"id":"%s"}`, id))
+	}
+	properties := map[string]json.RawMessage{"12": json.RawMessage(`{"property_id":"12"}`)}
+	s := New(Config{ApiKey: "abc", SecretKey: "secret", PageSize: pageSize, Regions: regions,
+		Properties: properties})
+	s.now = func() time.Time {
+		return simulatorNow
+	}
+	return s
+}
+
+func signedRequest(target string, timestamp time.Time) *http.Request {
+	r := httptest.NewRequest("GET", target, nil)
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	r.Header.Set("Authorization", fmt.Sprintf("EAN apikey=abc,signature=%s,timestamp=%s",
+		Signature("abc", "secret", ts), ts))
+	return r
+}
+
+func TestRegionsShouldPaginateWithLinkHeader(t *testing.T) {
+	s := newSimulator(3)
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, signedRequest("http://ean.test/2.2/regions?language=en-US", simulatorNow))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"1":{"id":"1"},"2":{"id":"2"},"10":{"id":"10"}}`, rr.Body.String())
+	assert.Equal(t, `<http://ean.test/2.2/regions?language=en-US&token=3>; rel="next"; expires="2019-05-30T12:29:07Z"`,
+		rr.Header().Get("Link"))
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, signedRequest("http://ean.test/2.2/regions?language=en-US&token=3", simulatorNow))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"136":{"id":"136"}}`, rr.Body.String())
+	assert.Empty(t, rr.Header().Get("Link"))
+	assert.Equal(t, 2, s.Requests())
+}
+
+func TestPropertiesShouldBeServedAsContent(t *testing.T) {
+	s := newSimulator(3)
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, signedRequest("http://ean.test/2.2/properties/content?language=en-US", simulatorNow))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"12":{"property_id":"12"}}`, rr.Body.String())
+	assert.Empty(t, rr.Header().Get("Link"))
+}
+
+func TestRegionsShouldGzipWhenAccepted(t *testing.T) {
+	s := newSimulator(10)
+	r := signedRequest("/regions?language=en-US", simulatorNow)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, r)
+
+	assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	reader, err := gzip.NewReader(rr.Body)
+	assert.NoError(t, err)
+	var page map[string]json.RawMessage
+	assert.NoError(t, json.NewDecoder(reader).Decode(&page))
+	assert.Len(t, page, 4)
+}
+
+func TestRegionsShouldRejectBadRequests(t *testing.T) {
+	tests := []struct {
+		testDescription string
+		request         *http.Request
+		expectedStatus  int
+		expectedBody    string
+	}{
+		{"missing signature", httptest.NewRequest("GET", "/regions?language=en-US", nil), http.StatusUnauthorized,
+			`{"type":"request_unauthenticated","message":"authorization header is missing or malformed"}`},
+		{"stale timestamp", signedRequest("/regions?language=en-US", simulatorNow.Add(-10*time.Minute)),
+			http.StatusUnauthorized,
+			`{"type":"request_unauthenticated","message":"timestamp is outside the allowed window"}`},
+		{"missing language", signedRequest("/regions", simulatorNow), http.StatusBadRequest,
+			`{"type":"invalid_input","message":"language is required."}`},
+		{"bad token", signedRequest("/regions?language=en-US&token=99", simulatorNow), http.StatusBadRequest,
+			`{"type":"invalid_input","message":"token is invalid."}`},
+		{"unknown path", signedRequest("/properties", simulatorNow), http.StatusNotFound,
+			`{"type":"resource_not_found","message":"The requested resource does not exist."}`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.testDescription, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			newSimulator(10).ServeHTTP(rr, tc.request)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestRegionsShouldRejectWrongSignature(t *testing.T) {
+	r := httptest.NewRequest("GET", "/regions?language=en-US", nil)
+	ts := strconv.FormatInt(simulatorNow.Unix(), 10)
+	r.Header.Set("Authorization", "EAN apikey=abc,signature="+Signature("abc", "wrong", ts)+",timestamp="+ts)
+
+	rr := httptest.NewRecorder()
+	newSimulator(10).ServeHTTP(rr, r)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), "signature is not valid")
+}
+
+func TestInjectedFaultsShouldApplyToOneRequestEach(t *testing.T) {
+	s := newSimulator(10)
+	s.Inject(Fault{Status: http.StatusTooManyRequests, RetryAfter: 3}, Fault{Truncate: true})
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, signedRequest("/regions?language=en-US", simulatorNow))
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "3", rr.Header().Get("Retry-After"))
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, signedRequest("/regions?language=en-US", simulatorNow))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var page map[string]json.RawMessage
+	assert.Error(t, json.Unmarshal(rr.Body.Bytes(), &page), "a truncated page should not decode")
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, signedRequest("/regions?language=en-US", simulatorNow))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+}
+
+func TestFaultsEndpointShouldInjectFaults(t *testing.T) {
+	s := newSimulator(10)
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("POST", FaultsPath, strings.NewReader(`[{"status":500}]`)))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, signedRequest("/regions?language=en-US", simulatorNow))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
+
+func TestSlowFaultShouldDelayResponse(t *testing.T) {
+	s := newSimulator(10)
+	s.Inject(Fault{Delay: 20 * time.Millisecond})
+
+	started := time.Now()
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, signedRequest("/regions?language=en-US", simulatorNow))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, time.Since(started) >= 20*time.Millisecond)
+}
+
+func TestAvailabilityShouldPriceKnownProperties(t *testing.T) {
+	s := newSimulator(10)
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, signedRequest("/properties/availability?checkin=2019-06-01&checkout=2019-06-03&occupancy=2"+
+		"&occupancy=1-5&currency=EUR&country_code=FR&language=en-US&property_id=12&property_id=99", simulatorNow))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	total := `{"value":"184.00","currency":"EUR"}`
+	pricing := `{"totals":{"inclusive":{"billable_currency":` + total + `,"request_currency":` + total + `}}}`
+	assert.JSONEq(t, `[{"property_id":"12","status":"available","rooms":[{"id":"1201","room_name":"Standard Room",
+		"rates":[{"id":"120101","status":"available","available_rooms":5,"refundable":true,
+		"occupancy_pricing":{"2":`+pricing+`,"1-5":`+pricing+`},
+		"bed_groups":{"37321":{"id":"37321","description":"1 King Bed","links":{"price_check":{"method":"GET",
+		"href":"/2.4/properties/12/rooms/1201/rates/120101?token=sim-12-1201-120101"}}}}}]}]}]`, rr.Body.String())
+}
+
+func TestAvailabilityShouldPriceRepeatedOccupanciesOnce(t *testing.T) {
+	rr := httptest.NewRecorder()
+	newSimulator(10).ServeHTTP(rr, signedRequest("/properties/availability?checkin=2019-06-01&checkout=2019-06-03"+
+		"&occupancy=2&occupancy=2&currency=EUR&country_code=FR&language=en-US&property_id=12", simulatorNow))
+	var properties []struct {
+		Rooms []struct {
+			Rates []struct {
+				OccupancyPricing map[string]json.RawMessage `json:"occupancy_pricing"`
+			} `json:"rates"`
+		} `json:"rooms"`
+	}
+
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &properties))
+	assert.Len(t, properties[0].Rooms[0].Rates[0].OccupancyPricing, 1)
+	assert.Contains(t, string(properties[0].Rooms[0].Rates[0].OccupancyPricing["2"]), `"value":"184.00"`)
+}
+
+func TestAvailabilityShouldRejectIncompleteStays(t *testing.T) {
+	rr := httptest.NewRecorder()
+	newSimulator(10).ServeHTTP(rr, signedRequest("/properties/availability?checkin=2019-06-01&checkout=2019-06-01"+
+		"&occupancy=2&currency=EUR&country_code=FR&language=en-US&property_id=12", simulatorNow))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"type":"invalid_input","message":"checkout is invalid."}`, rr.Body.String())
+}
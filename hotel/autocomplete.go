@@ -0,0 +1,157 @@
+package hotel
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+//RegionSuggestion is the trimmed down region returned while the user is still typing
+type RegionSuggestion struct {
+	Id         string `json:"id"`
+	Name       string `json:"name"`
+	NameFull   string `json:"name_full"`
+	Type       string `json:"type"`
+	Descriptor string `json:"descriptor"`
+}
+
+//match ranks, lower is better
+const (
+	nameMatch = iota
+	fullNameMatch
+	wordMatch
+)
+
+type indexKey struct {
+	key        string
+	rank       int
+	suggestion int
+}
+
+//shortPrefix is the longest prefix, in characters, whose best suggestions are ranked when the index is built. The
+//first keystrokes match a large share of the keys, too many to scan and rank on every lookup
+const shortPrefix = 3
+
+//autocompleteIndex answers prefix lookups from a sorted list of normalized keys, so a lookup is a binary search
+//followed by a scan over the matching keys only. Short prefixes are answered from suggestions ranked beforehand. It
+//is immutable once built and safe for concurrent reads
+type autocompleteIndex struct {
+	suggestions []RegionSuggestion
+	keys        []indexKey
+	//top holds the best MaxSearchLimit suggestions of every prefix up to shortPrefix long, best first
+	top map[string][]int
+}
+
+func newAutocompleteIndex(suggestions []RegionSuggestion) *autocompleteIndex {
+	index := &autocompleteIndex{suggestions: suggestions}
+	for i, suggestion := range suggestions {
+		name := normalize(suggestion.Name)
+		fullName := normalize(suggestion.NameFull)
+		index.add(name, nameMatch, i)
+		if fullName != name {
+			index.add(fullName, fullNameMatch, i)
+		}
+		words := strings.FieldsFunc(fullName, isSeparator)
+		for j := 1; j < len(words); j++ {
+			index.add(words[j], wordMatch, i)
+		}
+	}
+	sort.Slice(index.keys, func(i, j int) bool {
+		return index.keys[i].key < index.keys[j].key
+	})
+	index.top = map[string][]int{}
+	for _, key := range index.keys {
+		runes := []rune(key.key)
+		for length := 1; length <= shortPrefix && length <= len(runes); length++ {
+			if prefix := string(runes[:length]); index.top[prefix] == nil {
+				index.top[prefix] = index.rank(prefix, MaxSearchLimit)
+			}
+		}
+	}
+	return index
+}
+
+func (index *autocompleteIndex) add(key string, rank int, suggestion int) {
+	if key != "" {
+		index.keys = append(index.keys, indexKey{key: key, rank: rank, suggestion: suggestion})
+	}
+}
+
+//lookup returns up to limit suggestions whose name, full name or a word of the full name starts with prefix.
+//Name matches come first, then shorter names, so "par" suggests Paris before Paradise Island
+func (index *autocompleteIndex) lookup(prefix string, limit int) []RegionSuggestion {
+	prefix = normalize(prefix)
+	if prefix == "" || limit <= 0 {
+		return []RegionSuggestion{}
+	}
+	var matches []int
+	if len([]rune(prefix)) <= shortPrefix {
+		//a short prefix missing from top matches no key
+		matches = index.top[prefix]
+		if len(matches) > limit {
+			matches = matches[:limit]
+		}
+	} else {
+		matches = index.rank(prefix, limit)
+	}
+
+	suggestions := make([]RegionSuggestion, 0, len(matches))
+	for _, match := range matches {
+		suggestions = append(suggestions, index.suggestions[match])
+	}
+	return suggestions
+}
+
+//rank returns the best limit suggestions matching prefix, already normalized, best first
+func (index *autocompleteIndex) rank(prefix string, limit int) []int {
+	start := sort.Search(len(index.keys), func(i int) bool {
+		return index.keys[i].key >= prefix
+	})
+
+	bestRank := map[int]int{}
+	for _, key := range index.keys[start:] {
+		if !strings.HasPrefix(key.key, prefix) {
+			break
+		}
+		if rank, ok := bestRank[key.suggestion]; !ok || key.rank < rank {
+			bestRank[key.suggestion] = key.rank
+		}
+	}
+
+	matches := make([]int, 0, len(bestRank))
+	for suggestion := range bestRank {
+		matches = append(matches, suggestion)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if bestRank[a] != bestRank[b] {
+			return bestRank[a] < bestRank[b]
+		}
+		if len(index.suggestions[a].Name) != len(index.suggestions[b].Name) {
+			return len(index.suggestions[a].Name) < len(index.suggestions[b].Name)
+		}
+		return index.suggestions[a].Id < index.suggestions[b].Id
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+//normalize lower cases and strips accents, so "ile" finds Île-de-France
+func normalize(value string) string {
+	stripAccents := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	normalized, _, err := transform.String(stripAccents, value)
+	if err != nil {
+		normalized = value
+	}
+	return strings.ToLower(strings.TrimSpace(normalized))
+}
+
+func isSeparator(c rune) bool {
+	return c == ',' || c == '(' || c == ')' || unicode.IsSpace(c)
+}
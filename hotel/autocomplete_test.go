@@ -0,0 +1,55 @@
+package hotel
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAutocompleteIndexLookup(t *testing.T) {
+	paris := RegionSuggestion{Id: "2734", Name: "Paris", NameFull: "Paris, Ile-de-France, France", Type: "city"}
+	paradise := RegionSuggestion{Id: "180", Name: "Paradise Island", NameFull: "Paradise Island, Bahamas", Type: "city"}
+	france := RegionSuggestion{Id: "73", Name: "France", NameFull: "France", Type: "country"}
+	ileDeFrance := RegionSuggestion{Id: "11", Name: "Île-de-France", NameFull: "Île-de-France, France", Type: "province_state"}
+	index := newAutocompleteIndex([]RegionSuggestion{paradise, paris, france, ileDeFrance})
+
+	tt := []struct {
+		testDescription string
+		prefix          string
+		limit           int
+		expected        []RegionSuggestion
+	}{
+		{"ShouldPreferShorterNames", "par", 10, []RegionSuggestion{paris, paradise}},
+		{"ShouldIgnoreCase", "PARIS", 10, []RegionSuggestion{paris}},
+		{"ShouldApplyLimit", "par", 1, []RegionSuggestion{paris}},
+		{"ShouldIgnoreAccents", "ile", 10, []RegionSuggestion{ileDeFrance, paris}},
+		{"ShouldRankNamesBeforeWordsOfFullName", "fra", 10, []RegionSuggestion{france, paris, ileDeFrance}},
+		{"ShouldMatchFullName", "paris, ile", 10, []RegionSuggestion{paris}},
+		{"ShouldAnswerShortPrefixes", "p", 10, []RegionSuggestion{paris, paradise}},
+		{"ShouldApplyLimitToShortPrefixes", "fr", 2, []RegionSuggestion{france, paris}},
+		{"ShouldReturnEmptyForNoMatchOfShortPrefix", "xy", 10, []RegionSuggestion{}},
+		{"ShouldReturnEmptyForNoMatch", "xyz", 10, []RegionSuggestion{}},
+		{"ShouldReturnEmptyForBlankPrefix", "  ", 10, []RegionSuggestion{}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testDescription, func(t *testing.T) {
+			assert.Equal(t, tc.expected, index.lookup(tc.prefix, tc.limit))
+		})
+	}
+}
+
+func TestAutocompleteIndexShouldRankShortPrefixesBeforehand(t *testing.T) {
+	var suggestions []RegionSuggestion
+	for i := 0; i < 500; i++ {
+		name := fmt.Sprintf("Pa%03d", i)
+		suggestions = append(suggestions, RegionSuggestion{Id: fmt.Sprint(i), Name: name, NameFull: name + ", France"})
+	}
+	index := newAutocompleteIndex(suggestions)
+
+	assert.Len(t, index.top["pa"], MaxSearchLimit)
+	assert.Equal(t, index.rank("pa", MaxSearchLimit), index.top["pa"])
+	assert.Len(t, index.lookup("pa", MaxSearchLimit), MaxSearchLimit)
+	assert.Equal(t, "0", index.lookup("P", 1)[0].Id)
+	assert.Equal(t, []RegionSuggestion{suggestions[123]}, index.lookup("pa123", 10))
+}
@@ -0,0 +1,212 @@
+package hotel
+
+import (
+	"math/big"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+//DateLayout is how check-in and check-out dates are written, on EAN as in our API
+const DateLayout = "2006-01-02"
+
+const (
+	//MaxNights is the longest stay EAN shops for
+	MaxNights = 28
+	//MaxRooms is the most rooms, one occupancy each, EAN shops for at once
+	MaxRooms = 8
+	//maxAdvance is how far ahead EAN takes check-in dates
+	maxAdvance = 500 * 24 * time.Hour
+)
+
+//Stay is what is shopped for: the dates, one occupancy per room such as "2" for two adults or "2-9,4" for two
+//adults with children aged 9 and 4, the currency to price in and the two letter country the guests live in
+type Stay struct {
+	CheckIn            time.Time
+	CheckOut           time.Time
+	Occupancy          []string
+	Currency           string
+	CountryOfResidence string
+}
+
+//PropertyAvailability is what a property has available for a stay, its cheapest rate first
+type PropertyAvailability struct {
+	PropertyId string `json:"property_id"`
+	Rates      []Rate `json:"rates"`
+}
+
+//Rate is a price of a room for the whole stay. Total includes taxes and fees and covers every room asked for
+type Rate struct {
+	RoomId         string     `json:"room_id"`
+	RoomName       string     `json:"room_name"`
+	RateId         string     `json:"rate_id"`
+	Refundable     bool       `json:"refundable"`
+	AvailableRooms int        `json:"available_rooms"`
+	Total          Amount     `json:"total"`
+	BedGroups      []BedGroup `json:"bed_groups"`
+}
+
+//BedGroup is a bed configuration a rate can be booked with. Token identifies the rate and bed group when
+//checking its price before booking
+type BedGroup struct {
+	Id          string `json:"id"`
+	Description string `json:"description"`
+	Token       string `json:"token"`
+}
+
+//Amount is money as EAN gives it, a decimal string in a currency
+type Amount struct {
+	Value    string `json:"value"`
+	Currency string `json:"currency"`
+}
+
+//validate checks a stay makes sense before EAN is asked about it, today being the first possible check-in
+func (stay Stay) validate(today time.Time) error {
+	var invalid []InvalidParam
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	switch {
+	case stay.CheckIn.Before(today):
+		invalid = append(invalid, InvalidParam{Name: "checkin", Reason: "must not be in the past"})
+	case stay.CheckIn.Sub(today) > maxAdvance:
+		invalid = append(invalid, InvalidParam{Name: "checkin", Reason: "must be within 500 days"})
+	}
+	nights := int(stay.CheckOut.Sub(stay.CheckIn).Hours() / 24)
+	switch {
+	case nights < 1:
+		invalid = append(invalid, InvalidParam{Name: "checkout", Reason: "must be after checkin"})
+	case nights > MaxNights:
+		invalid = append(invalid, InvalidParam{Name: "checkout", Reason: "must be at most 28 nights after checkin"})
+	}
+	if len(stay.Occupancy) > MaxRooms {
+		invalid = append(invalid, InvalidParam{Name: "occupancy", Reason: "may be given for at most 8 rooms"})
+	}
+	if len(invalid) > 0 {
+		return InvalidParams(invalid)
+	}
+	return nil
+}
+
+//rapidAvailability is the availability of a property as EAN Rapid serves it on properties/availability
+type rapidAvailability struct {
+	PropertyId string `json:"property_id"`
+	Rooms      []struct {
+		Id       string `json:"id"`
+		RoomName string `json:"room_name"`
+		Rates    []struct {
+			Id               string `json:"id"`
+			Status           string `json:"status"`
+			AvailableRooms   int    `json:"available_rooms"`
+			Refundable       bool   `json:"refundable"`
+			OccupancyPricing map[string]struct {
+				Totals struct {
+					Inclusive struct {
+						RequestCurrency Amount `json:"request_currency"`
+					} `json:"inclusive"`
+				} `json:"totals"`
+			} `json:"occupancy_pricing"`
+			BedGroups map[string]struct {
+				Id          string `json:"id"`
+				Description string `json:"description"`
+				Links       struct {
+					PriceCheck struct {
+						Href string `json:"href"`
+					} `json:"price_check"`
+				} `json:"links"`
+			} `json:"bed_groups"`
+		} `json:"rates"`
+	} `json:"rooms"`
+}
+
+//availability flattens a Rapid availability for the rooms of occupancies, keeping only the available rates,
+//cheapest first. Rapid prices one room of each occupancy, once however many rooms of it were asked for, so the
+//total of a rate is the sum of their prices, each counted for as many rooms as have that occupancy
+func (rapid rapidAvailability) availability(occupancies []string) PropertyAvailability {
+	rooms := map[string]int{}
+	for _, occupancy := range occupancies {
+		rooms[occupancy]++
+	}
+	availability := PropertyAvailability{PropertyId: rapid.PropertyId, Rates: []Rate{}}
+	for _, room := range rapid.Rooms {
+		for _, rapidRate := range room.Rates {
+			if rapidRate.Status != "" && rapidRate.Status != "available" {
+				continue
+			}
+			rate := Rate{RoomId: room.Id, RoomName: room.RoomName, RateId: rapidRate.Id,
+				Refundable: rapidRate.Refundable, AvailableRooms: rapidRate.AvailableRooms, BedGroups: []BedGroup{}}
+			var totals []Amount
+			for occupancy, pricing := range rapidRate.OccupancyPricing {
+				count := rooms[occupancy]
+				if count == 0 {
+					count = 1
+				}
+				for i := 0; i < count; i++ {
+					totals = append(totals, pricing.Totals.Inclusive.RequestCurrency)
+				}
+			}
+			rate.Total = sum(totals)
+			for id, group := range rapidRate.BedGroups {
+				if group.Id == "" {
+					group.Id = id
+				}
+				rate.BedGroups = append(rate.BedGroups, BedGroup{Id: group.Id, Description: group.Description,
+					Token: linkToken(group.Links.PriceCheck.Href)})
+			}
+			sort.Slice(rate.BedGroups, func(i, j int) bool {
+				return rate.BedGroups[i].Id < rate.BedGroups[j].Id
+			})
+			availability.Rates = append(availability.Rates, rate)
+		}
+	}
+	sort.SliceStable(availability.Rates, func(i, j int) bool {
+		return availability.Rates[i].Total.less(availability.Rates[j].Total)
+	})
+	return availability
+}
+
+//sum adds amounts of the same currency, keeping the most decimals any of them has
+func sum(amounts []Amount) Amount {
+	if len(amounts) == 0 {
+		return Amount{}
+	}
+	total := new(big.Rat)
+	decimals := 0
+	for _, amount := range amounts {
+		value, ok := new(big.Rat).SetString(amount.Value)
+		if !ok {
+			return Amount{}
+		}
+		total.Add(total, value)
+		if point := strings.IndexByte(amount.Value, '.'); point >= 0 && len(amount.Value)-point-1 > decimals {
+			decimals = len(amount.Value) - point - 1
+		}
+	}
+	return Amount{Value: total.FloatString(decimals), Currency: amounts[0].Currency}
+}
+
+//less orders amounts by value. Amounts whose value does not parse, such as the empty amount, come last
+func (amount Amount) less(other Amount) bool {
+	value, ok := new(big.Rat).SetString(amount.Value)
+	if !ok {
+		return false
+	}
+	otherValue, ok := new(big.Rat).SetString(other.Value)
+	return !ok || value.Cmp(otherValue) < 0
+}
+
+//cheapest is the total of the first rate of an availability, which is the cheapest
+func (availability PropertyAvailability) cheapest() Amount {
+	if len(availability.Rates) == 0 {
+		return Amount{}
+	}
+	return availability.Rates[0].Total
+}
+
+//linkToken returns the token parameter of an EAN link, what identifies a rate to price check and book
+func linkToken(href string) string {
+	link, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	return link.Query().Get("token")
+}
@@ -0,0 +1,126 @@
+package hotel
+
+import (
+	"context"
+	"hotels-service-template/tracing"
+	"log/slog"
+	"sort"
+	"sync"
+)
+
+//DefaultAvailabilityConcurrency is how many availability requests one search keeps in flight on EAN
+const DefaultAvailabilityConcurrency = 4
+
+type AvailabilityServiceInt interface {
+	Availability(ctx context.Context, regionId string, stay Stay) ([]PropertyAvailability, error)
+}
+
+type availabilityService struct {
+	regions     regionRepositoryInt
+	client      clientInt
+	batchSize   int
+	concurrency int
+	logger      *slog.Logger
+}
+
+func NewAvailabilityService(regions regionRepositoryInt, client clientInt, logger *slog.Logger) *availabilityService {
+	return &availabilityService{
+		regions:     regions,
+		client:      client,
+		batchSize:   MaxAvailabilityIds,
+		concurrency: DefaultAvailabilityConcurrency,
+		logger:      logger,
+	}
+}
+
+//Availability shops a stay in every property of a region, expanded ones included, cheapest property first. The
+//properties are asked of EAN in batches, a few batches at a time, and the first batch to fail fails the search
+func (s *availabilityService) Availability(ctx context.Context, regionId string, stay Stay) (
+	availabilities []PropertyAvailability, err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "availabilityService.Availability")
+	defer span.Finish(&err)
+	if err = stay.validate(now().UTC()); err != nil {
+		return nil, err
+	}
+	region, err := s.regions.getById(ctx, regionId)
+	if err != nil {
+		return nil, notFound(err, "region_not_found", "region %s does not exist", regionId)
+	}
+	batches := splitIds(regionPropertyIds(region), s.batchSize)
+	span.SetAttribute("batches", len(batches))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var once sync.Once
+	fail := func(batchErr error) {
+		once.Do(func() {
+			err = batchErr
+			cancel()
+		})
+	}
+	results := make([][]PropertyAvailability, len(batches))
+	slots := make(chan struct{}, s.concurrency)
+	var wg sync.WaitGroup
+	for i, ids := range batches {
+		wg.Add(1)
+		go func(i int, ids []string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			if ctx.Err() != nil {
+				return
+			}
+			available, batchErr := s.client.availability(ctx, stay, ids)
+			if batchErr != nil {
+				fail(batchErr)
+				return
+			}
+			results[i] = available
+		}(i, ids)
+	}
+	wg.Wait()
+	//batches that never started leave err unset when the caller gave up
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	availabilities = []PropertyAvailability{}
+	for _, available := range results {
+		availabilities = append(availabilities, available...)
+	}
+	sort.SliceStable(availabilities, func(i, j int) bool {
+		return availabilities[i].cheapest().less(availabilities[j].cheapest())
+	})
+	s.logger.DebugContext(ctx, "availability shopped", "region", regionId, "batches", len(batches), "available",
+		len(availabilities))
+	return availabilities, nil
+}
+
+//regionPropertyIds lists the properties of a region and then its expanded properties, each once
+func regionPropertyIds(region Region) []string {
+	seen := map[string]bool{}
+	var ids []string
+	for _, id := range append(append([]string{}, region.PropertyIds...), region.PropertyIdsExpanded...) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+//splitIds splits ids into slices of at most size ids
+func splitIds(ids []string, size int) [][]string {
+	var batches [][]string
+	for len(ids) > size {
+		batches = append(batches, ids[:size])
+		ids = ids[size:]
+	}
+	if len(ids) > 0 {
+		batches = append(batches, ids)
+	}
+	return batches
+}
@@ -0,0 +1,126 @@
+package hotel
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"hotels-service-template/logging"
+	"testing"
+	"time"
+)
+
+type AvailabilityServiceTestSuite struct {
+	suite.Suite
+	regions *MockRegionRepository
+	client  *mockClient
+	service *availabilityService
+}
+
+func (s *AvailabilityServiceTestSuite) SetupTest() {
+	now = func() time.Time {
+		return time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC)
+	}
+	s.regions = &MockRegionRepository{}
+	s.client = &mockClient{}
+	s.service = NewAvailabilityService(s.regions, s.client, logging.Discard())
+	s.service.batchSize = 2
+	s.service.concurrency = 2
+}
+
+func TestAvailabilityServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(AvailabilityServiceTestSuite))
+}
+
+func available(propertyId string, total string) PropertyAvailability {
+	return PropertyAvailability{PropertyId: propertyId, Rates: []Rate{{Total: Amount{Value: total, Currency: "EUR"}}}}
+}
+
+func (s *AvailabilityServiceTestSuite) TestAvailabilityShouldShopEveryPropertyInBatchesCheapestFirst() {
+	stay := testStay()
+	s.regions.On("getById", "6023").Return(Region{Id: "6023", PropertyIds: []string{"1", "2", "3"},
+		PropertyIdsExpanded: []string{"3", "4", "5"}}, nil)
+	s.client.On("availability", stay, []string{"1", "2"}).Return([]PropertyAvailability{available("1", "300.00"),
+		available("2", "99.90")}, nil)
+	s.client.On("availability", stay, []string{"3", "4"}).Return([]PropertyAvailability{available("4", "150")}, nil)
+	s.client.On("availability", stay, []string{"5"}).Return([]PropertyAvailability{}, nil)
+
+	availabilities, err := s.service.Availability(context.Background(), "6023", stay)
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []PropertyAvailability{available("2", "99.90"), available("4", "150"),
+		available("1", "300.00")}, availabilities)
+	s.client.AssertNumberOfCalls(s.T(), "availability", 3)
+}
+
+func (s *AvailabilityServiceTestSuite) TestAvailabilityShouldFailWithTheFirstFailingBatch() {
+	stay := testStay()
+	s.regions.On("getById", "6023").Return(Region{Id: "6023", PropertyIds: []string{"1", "2", "3"}}, nil)
+	s.client.On("availability", stay, []string{"1", "2"}).Return(nil, errors.New("ean unavailable"))
+	s.client.On("availability", stay, []string{"3"}).Return([]PropertyAvailability{available("3", "10.00")}, nil)
+
+	availabilities, err := s.service.Availability(context.Background(), "6023", stay)
+
+	assert.EqualError(s.T(), err, "ean unavailable")
+	assert.Nil(s.T(), availabilities)
+}
+
+func (s *AvailabilityServiceTestSuite) TestAvailabilityShouldStopWhenTheCallerGivesUp() {
+	stay := testStay()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.service.concurrency = 1
+	s.regions.On("getById", "6023").Return(Region{Id: "6023", PropertyIds: []string{"1", "2", "3", "4", "5"}}, nil)
+	s.client.On("availability", stay, mock.Anything).Run(func(mock.Arguments) {
+		cancel()
+	}).Return([]PropertyAvailability{available("1", "10.00")}, nil)
+
+	availabilities, err := s.service.Availability(ctx, "6023", stay)
+
+	assert.Equal(s.T(), context.Canceled, err)
+	assert.Nil(s.T(), availabilities)
+	s.client.AssertNumberOfCalls(s.T(), "availability", 1)
+}
+
+func (s *AvailabilityServiceTestSuite) TestAvailabilityOfRegionWithoutProperties() {
+	s.regions.On("getById", "6023").Return(Region{Id: "6023"}, nil)
+
+	availabilities, err := s.service.Availability(context.Background(), "6023", testStay())
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []PropertyAvailability{}, availabilities)
+	s.client.AssertNotCalled(s.T(), "availability", mock.Anything, mock.Anything)
+}
+
+func (s *AvailabilityServiceTestSuite) TestAvailabilityOfUnknownRegion() {
+	s.regions.On("getById", "1").Return(Region{}, sql.ErrNoRows)
+
+	_, err := s.service.Availability(context.Background(), "1", testStay())
+
+	assert.Equal(s.T(), NotFound("region_not_found", "region 1 does not exist"), err)
+}
+
+func (s *AvailabilityServiceTestSuite) TestAvailabilityShouldRejectImpossibleStays() {
+	day := func(month time.Month, day int) time.Time {
+		return time.Date(2026, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	stay := testStay()
+	stay.CheckIn, stay.CheckOut = day(10, 17), day(10, 17)
+	stay.Occupancy = []string{"1", "1", "1", "1", "1", "1", "1", "1", "1"}
+
+	_, err := s.service.Availability(context.Background(), "6023", stay)
+
+	assert.Equal(s.T(), InvalidParams([]InvalidParam{{Name: "checkin", Reason: "must not be in the past"},
+		{Name: "checkout", Reason: "must be after checkin"},
+		{Name: "occupancy", Reason: "may be given for at most 8 rooms"}}), err)
+
+	stay = testStay()
+	stay.CheckIn, stay.CheckOut = day(10, 18), day(11, 16)
+	_, err = s.service.Availability(context.Background(), "6023", stay)
+
+	assert.Equal(s.T(), InvalidParams([]InvalidParam{{Name: "checkout",
+		Reason: "must be at most 28 nights after checkin"}}), err)
+	s.regions.AssertNotCalled(s.T(), "getById", mock.Anything)
+}
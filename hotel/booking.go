@@ -0,0 +1,365 @@
+package hotel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type ItineraryState string
+
+const (
+	//ItineraryHeld is an itinerary being booked on EAN. One stays held when EAN gave no clear answer, so it may or
+	//may not be booked
+	ItineraryHeld      ItineraryState = "held"
+	ItineraryConfirmed ItineraryState = "confirmed"
+	ItineraryCancelled ItineraryState = "cancelled"
+	//ItineraryFailed is an itinerary EAN did not book, Failure says why
+	ItineraryFailed ItineraryState = "failed"
+)
+
+//itineraryTransitions lists the states each state may move to. Cancelled and failed itineraries are final
+var itineraryTransitions = map[ItineraryState][]ItineraryState{
+	ItineraryHeld:      {ItineraryConfirmed, ItineraryFailed},
+	ItineraryConfirmed: {ItineraryCancelled},
+}
+
+func (state ItineraryState) canBecome(next ItineraryState) bool {
+	for _, allowed := range itineraryTransitions[state] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+//Itinerary is a booking as stored, together with the rooms EAN holds for it when it was retrieved from EAN
+type Itinerary struct {
+	Id             int64           `json:"id"`
+	State          ItineraryState  `json:"state"`
+	PropertyId     string          `json:"property_id"`
+	RoomId         string          `json:"room_id"`
+	RateId         string          `json:"rate_id"`
+	RoomCount      int             `json:"room_count"`
+	Total          Amount          `json:"total"`
+	EANItineraryId string          `json:"ean_itinerary_id,omitempty"`
+	Failure        string          `json:"failure,omitempty"`
+	Rooms          []ItineraryRoom `json:"rooms,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	requestHash    string
+	eanToken       string
+}
+
+//ItineraryRoom is a room of an itinerary as EAN has it, its status being booked or canceled
+type ItineraryRoom struct {
+	Id             string `json:"id"`
+	ConfirmationId string `json:"confirmation_id"`
+	Status         string `json:"status"`
+}
+
+//BookingRequest books one rate for a stay that was shopped with Availability, one room per guest. Token is the
+//token of the bed group to book and Total the price the customer agreed to. It holds customer details, which are
+//redacted when it is printed or logged
+type BookingRequest struct {
+	PropertyId string  `json:"property_id"`
+	RoomId     string  `json:"room_id"`
+	RateId     string  `json:"rate_id"`
+	Token      string  `json:"token"`
+	Total      Amount  `json:"total"`
+	Email      string  `json:"email"`
+	Phone      Phone   `json:"phone"`
+	Rooms      []Guest `json:"rooms"`
+	Payment    Payment `json:"payment"`
+}
+
+type Phone struct {
+	CountryCode string `json:"country_code"`
+	AreaCode    string `json:"area_code,omitempty"`
+	Number      string `json:"number"`
+}
+
+//Guest is who a room is booked for
+type Guest struct {
+	GivenName      string `json:"given_name"`
+	FamilyName     string `json:"family_name"`
+	Smoking        bool   `json:"smoking"`
+	SpecialRequest string `json:"special_request,omitempty"`
+}
+
+//Payment is the card the customer pays with. It is only ever passed on to EAN
+type Payment struct {
+	Number          string         `json:"number"`
+	SecurityCode    string         `json:"security_code"`
+	ExpirationMonth string         `json:"expiration_month"`
+	ExpirationYear  string         `json:"expiration_year"`
+	BillingContact  BillingContact `json:"billing_contact"`
+}
+
+type BillingContact struct {
+	GivenName  string  `json:"given_name"`
+	FamilyName string  `json:"family_name"`
+	Address    Address `json:"address"`
+}
+
+const redacted = "[redacted]"
+
+//String leaves out the customer details, so a request printed by mistake does not leak them
+func (request BookingRequest) String() string {
+	return fmt.Sprintf("booking of rate %s of room %s of property %s for %d rooms", request.RateId, request.RoomId,
+		request.PropertyId, len(request.Rooms))
+}
+
+func (request BookingRequest) GoString() string {
+	return request.String()
+}
+
+//LogValue logs what is booked, without who books it
+func (request BookingRequest) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("property_id", request.PropertyId), slog.String("room_id", request.RoomId),
+		slog.String("rate_id", request.RateId), slog.Int("rooms", len(request.Rooms)))
+}
+
+func (phone Phone) String() string     { return redacted }
+func (guest Guest) String() string     { return redacted }
+func (payment Payment) String() string { return redacted }
+
+func (phone Phone) LogValue() slog.Value     { return slog.StringValue(redacted) }
+func (guest Guest) LogValue() slog.Value     { return slog.StringValue(redacted) }
+func (payment Payment) LogValue() slog.Value { return slog.StringValue(redacted) }
+
+const (
+	maxNameLength    = 60
+	maxRequestLength = 255
+	maxEmailLength   = 254
+	maxIdLength      = 64
+)
+
+var (
+	namePattern       = regexp.MustCompile(`^[\pL\pM][\pL\pM' .-]*$`)
+	emailPattern      = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	digitsPattern     = regexp.MustCompile(`^[0-9]+$`)
+	amountPattern     = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+	currencyPattern   = regexp.MustCompile(`^[A-Z]{3}$`)
+	countryPattern    = regexp.MustCompile(`^[A-Z]{2}$`)
+	eanIdPattern      = regexp.MustCompile(`^[0-9A-Za-z-]+$`)
+	expirationPattern = regexp.MustCompile(`^(0[1-9]|1[0-2])$`)
+)
+
+//bookingCheck collects what is wrong with the fields of a booking request. Reasons never quote the values, which
+//may be customer details
+type bookingCheck []InvalidParam
+
+func (check *bookingCheck) invalid(name string, reason string) {
+	*check = append(*check, InvalidParam{Name: name, Reason: reason})
+}
+
+//text checks a required text of at most maxLength characters matching pattern, described by help
+func (check *bookingCheck) text(name string, value string, maxLength int, pattern *regexp.Regexp, help string) {
+	switch {
+	case strings.TrimSpace(value) == "":
+		check.invalid(name, "is required")
+	case utf8.RuneCountInString(value) > maxLength:
+		check.invalid(name, fmt.Sprintf("must be at most %d characters", maxLength))
+	case pattern != nil && !pattern.MatchString(value):
+		check.invalid(name, "must be "+help)
+	}
+}
+
+//validate checks a booking request before anything is stored or sent to EAN. today is used to reject expired cards
+func (request BookingRequest) validate(today time.Time) error {
+	var check bookingCheck
+	check.text("property_id", request.PropertyId, maxIdLength, digitsPattern, "digits")
+	check.text("room_id", request.RoomId, maxIdLength, eanIdPattern, "an EAN room id")
+	check.text("rate_id", request.RateId, maxIdLength, eanIdPattern, "an EAN rate id")
+	check.text("token", request.Token, 4096, nil, "")
+	check.text("total.value", request.Total.Value, 20, amountPattern, "a decimal amount")
+	check.text("total.currency", request.Total.Currency, 3, currencyPattern, "a three letter currency code")
+	check.text("email", request.Email, maxEmailLength, emailPattern, "an email address")
+	check.text("phone.country_code", request.Phone.CountryCode, 3, digitsPattern, "digits")
+	if request.Phone.AreaCode != "" {
+		check.text("phone.area_code", request.Phone.AreaCode, 6, digitsPattern, "digits")
+	}
+	check.text("phone.number", request.Phone.Number, 15, digitsPattern, "digits")
+
+	if len(request.Rooms) == 0 || len(request.Rooms) > MaxRooms {
+		check.invalid("rooms", fmt.Sprintf("must list from 1 to %d guests, one per room", MaxRooms))
+	}
+	for i, guest := range request.Rooms {
+		prefix := fmt.Sprintf("rooms[%d].", i)
+		check.text(prefix+"given_name", guest.GivenName, maxNameLength, namePattern, "a name")
+		check.text(prefix+"family_name", guest.FamilyName, maxNameLength, namePattern, "a name")
+		if utf8.RuneCountInString(guest.SpecialRequest) > maxRequestLength {
+			check.invalid(prefix+"special_request", fmt.Sprintf("must be at most %d characters", maxRequestLength))
+		}
+	}
+
+	payment := request.Payment
+	check.text("payment.number", payment.Number, 19, digitsPattern, "digits")
+	if digitsPattern.MatchString(payment.Number) && (len(payment.Number) < 12 || !luhn(payment.Number)) {
+		check.invalid("payment.number", "must be a valid card number")
+	}
+	check.text("payment.security_code", payment.SecurityCode, 4, digitsPattern, "digits")
+	check.text("payment.expiration_month", payment.ExpirationMonth, 2, expirationPattern, "a month from 01 to 12")
+	check.text("payment.expiration_year", payment.ExpirationYear, 4, digitsPattern, "a four digit year")
+	if expirationPattern.MatchString(payment.ExpirationMonth) && len(payment.ExpirationYear) == 4 {
+		year, _ := strconv.Atoi(payment.ExpirationYear)
+		month, _ := strconv.Atoi(payment.ExpirationMonth)
+		if year < today.Year() || year == today.Year() && time.Month(month) < today.Month() {
+			check.invalid("payment.expiration_year", "the card has expired")
+		}
+	}
+	contact := payment.BillingContact
+	check.text("payment.billing_contact.given_name", contact.GivenName, maxNameLength, namePattern, "a name")
+	check.text("payment.billing_contact.family_name", contact.FamilyName, maxNameLength, namePattern, "a name")
+	check.text("payment.billing_contact.address.line_1", contact.Address.Line1, maxRequestLength, nil, "")
+	check.text("payment.billing_contact.address.city", contact.Address.City, maxRequestLength, nil, "")
+	check.text("payment.billing_contact.address.country_code", contact.Address.CountryCode, 2, countryPattern,
+		"a two letter country code")
+
+	if len(check) > 0 {
+		return InvalidParams(check)
+	}
+	return nil
+}
+
+//luhn tells whether a card number has a valid check digit
+func luhn(number string) bool {
+	sum := 0
+	for i := range number {
+		digit := int(number[len(number)-1-i] - '0')
+		if i%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
+
+//hash fingerprints what a request books so a repeated idempotency key can be told apart from a reused one. The
+//payment is left out so card details are not hashed into the database
+func (request BookingRequest) hash() string {
+	data, _ := json.Marshal(struct {
+		PropertyId, RoomId, RateId, Token string
+		Total                             Amount
+		Email                             string
+		Rooms                             []Guest
+	}{request.PropertyId, request.RoomId, request.RateId, request.Token, request.Total, request.Email,
+		request.Rooms})
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+//priceCheck is the answer of EAN to a price check: whether the rate is still available at which total, and the
+//token to book it with
+type priceCheck struct {
+	Status    string
+	Total     Amount
+	BookToken string
+}
+
+const (
+	priceMatched = "matched"
+	priceChanged = "price_changed"
+	priceSoldOut = "sold_out"
+)
+
+//rapidPriceCheck is a price check as EAN Rapid serves it
+type rapidPriceCheck struct {
+	Status           string `json:"status"`
+	OccupancyPricing map[string]struct {
+		Totals struct {
+			Inclusive struct {
+				RequestCurrency Amount `json:"request_currency"`
+			} `json:"inclusive"`
+		} `json:"totals"`
+	} `json:"occupancy_pricing"`
+	Links struct {
+		Book struct {
+			Href string `json:"href"`
+		} `json:"book"`
+	} `json:"links"`
+}
+
+func (rapid rapidPriceCheck) priceCheck() priceCheck {
+	var totals []Amount
+	for _, pricing := range rapid.OccupancyPricing {
+		totals = append(totals, pricing.Totals.Inclusive.RequestCurrency)
+	}
+	return priceCheck{Status: rapid.Status, Total: sum(totals), BookToken: linkToken(rapid.Links.Book.Href)}
+}
+
+//rapidBooking is the body EAN Rapid takes to create an itinerary
+type rapidBooking struct {
+	AffiliateReferenceId string         `json:"affiliate_reference_id"`
+	Hold                 bool           `json:"hold"`
+	Email                string         `json:"email"`
+	Phone                Phone          `json:"phone"`
+	Rooms                []Guest        `json:"rooms"`
+	Payments             []rapidPayment `json:"payments"`
+}
+
+type rapidPayment struct {
+	Type string `json:"type"`
+	Payment
+}
+
+//rapid is the EAN body booking request, referenced on EAN by the id of its itinerary
+func (request BookingRequest) rapid(itineraryId int64) rapidBooking {
+	return rapidBooking{
+		AffiliateReferenceId: fmt.Sprintf("hst-%d", itineraryId),
+		Email:                request.Email,
+		Phone:                request.Phone,
+		Rooms:                request.Rooms,
+		Payments:             []rapidPayment{{Type: "customer_card", Payment: request.Payment}},
+	}
+}
+
+//rapidItinerary is an itinerary as EAN Rapid serves it when it is retrieved
+type rapidItinerary struct {
+	ItineraryId string `json:"itinerary_id"`
+	Links       struct {
+		Retrieve struct {
+			Href string `json:"href"`
+		} `json:"retrieve"`
+	} `json:"links"`
+	Rooms []struct {
+		Id             string `json:"id"`
+		Status         string `json:"status"`
+		ConfirmationId struct {
+			Expedia string `json:"expedia"`
+		} `json:"confirmation_id"`
+		Links struct {
+			Cancel struct {
+				Href string `json:"href"`
+			} `json:"cancel"`
+		} `json:"links"`
+	} `json:"rooms"`
+}
+
+func (rapid rapidItinerary) rooms() []ItineraryRoom {
+	rooms := make([]ItineraryRoom, 0, len(rapid.Rooms))
+	for _, room := range rapid.Rooms {
+		rooms = append(rooms, ItineraryRoom{Id: room.Id, ConfirmationId: room.ConfirmationId.Expedia,
+			Status: room.Status})
+	}
+	return rooms
+}
+
+//sameAmount tells whether two amounts are the same money, however many decimals they are written with
+func sameAmount(amount Amount, other Amount) bool {
+	value, ok := new(big.Rat).SetString(amount.Value)
+	otherValue, otherOk := new(big.Rat).SetString(other.Value)
+	return ok && otherOk && amount.Currency == other.Currency && value.Cmp(otherValue) == 0
+}
@@ -0,0 +1,168 @@
+package hotel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hotels-service-template/tracing"
+	"net/http"
+	"net/url"
+)
+
+const itinerariesEndpoint = "itineraries"
+
+//bookingClientInt books on EAN. Unlike listings and shopping, booking requests are sent once: retrying one that
+//EAN may have acted on could book or cancel twice
+type bookingClientInt interface {
+	priceCheck(ctx context.Context, request BookingRequest) (priceCheck, error)
+	book(ctx context.Context, bookToken string, booking rapidBooking) (itineraryId string, token string, err error)
+	retrieveItinerary(ctx context.Context, itineraryId string, token string) (rapidItinerary, error)
+	cancelItinerary(ctx context.Context, itineraryId string, token string) error
+	findItinerary(ctx context.Context, affiliateReferenceId string, email string) (rapidItinerary, bool, error)
+}
+
+//priceCheck asks EAN whether the rate of a booking request is still available and at which price. EAN answers a
+//changed price with 409 and a sold out rate with 410, both carrying a price check
+func (client client) priceCheck(ctx context.Context, request BookingRequest) (check priceCheck, err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "EAN price check")
+	defer span.Finish(&err)
+	target := fmt.Sprintf("%s/properties/%s/rooms/%s/rates/%s", client.URL, url.PathEscape(request.PropertyId),
+		url.PathEscape(request.RoomId), url.PathEscape(request.RateId))
+	var rapid rapidPriceCheck
+	err = client.call(ctx, http.MethodGet, target, url.Values{"token": {request.Token}}, nil, &rapid, http.StatusOK,
+		http.StatusConflict, http.StatusGone)
+	if err != nil {
+		return priceCheck{}, err
+	}
+	check = rapid.priceCheck()
+	span.SetAttribute("ean.status", check.Status)
+	return check, nil
+}
+
+//book creates an itinerary on EAN with the token of a price check. It returns the id of the itinerary and the
+//token to retrieve and cancel it with
+func (client client) book(ctx context.Context, bookToken string, booking rapidBooking) (itineraryId string,
+	token string, err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "EAN book")
+	defer span.Finish(&err)
+	body, err := json.Marshal(booking)
+	if err != nil {
+		return "", "", err
+	}
+	var created struct {
+		ItineraryId string `json:"itinerary_id"`
+		Links       struct {
+			Retrieve struct {
+				Href string `json:"href"`
+			} `json:"retrieve"`
+		} `json:"links"`
+	}
+	err = client.call(ctx, http.MethodPost, fmt.Sprintf("%s/%s", client.URL, itinerariesEndpoint),
+		url.Values{"token": {bookToken}}, body, &created, http.StatusCreated)
+	if err != nil {
+		return "", "", err
+	}
+	return created.ItineraryId, linkToken(created.Links.Retrieve.Href), nil
+}
+
+func (client client) retrieveItinerary(ctx context.Context, itineraryId string, token string) (
+	itinerary rapidItinerary, err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "EAN retrieve itinerary")
+	defer span.Finish(&err)
+	err = client.call(ctx, http.MethodGet, fmt.Sprintf("%s/%s/%s", client.URL, itinerariesEndpoint,
+		url.PathEscape(itineraryId)), url.Values{"token": {token}}, nil, &itinerary, http.StatusOK)
+	return itinerary, err
+}
+
+//findItinerary looks an itinerary up by the reference it was booked with, which EAN only answers together with the
+//email of the booking. It returns false when EAN has no such itinerary
+func (client client) findItinerary(ctx context.Context, affiliateReferenceId string, email string) (
+	itinerary rapidItinerary, found bool, err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "EAN find itinerary")
+	defer span.Finish(&err)
+	var itineraries []rapidItinerary
+	err = client.call(ctx, http.MethodGet, fmt.Sprintf("%s/%s", client.URL, itinerariesEndpoint),
+		url.Values{"affiliate_reference_id": {affiliateReferenceId}, "email": {email}}, nil, &itineraries,
+		http.StatusOK)
+	if apiErr, ok := err.(*APIError); ok && apiErr.StatusCode == http.StatusNotFound {
+		return rapidItinerary{}, false, nil
+	}
+	if err != nil || len(itineraries) == 0 {
+		return rapidItinerary{}, false, err
+	}
+	return itineraries[0], true, nil
+}
+
+//cancelItinerary cancels every room of an itinerary that is not cancelled yet, with the cancel links EAN gives
+//when the itinerary is retrieved
+func (client client) cancelItinerary(ctx context.Context, itineraryId string, token string) (err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "EAN cancel itinerary")
+	defer span.Finish(&err)
+	itinerary, err := client.retrieveItinerary(ctx, itineraryId, token)
+	if err != nil {
+		return err
+	}
+	for _, room := range itinerary.Rooms {
+		if room.Status == "canceled" || room.Links.Cancel.Href == "" {
+			continue
+		}
+		target, err := client.link(room.Links.Cancel.Href)
+		if err != nil {
+			return err
+		}
+		err = client.call(ctx, http.MethodDelete, target, nil, nil, nil, http.StatusAccepted, http.StatusNoContent)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//call sends one request with method to target and decodes the answer into out unless it is nil. A status other
+//than those expected is returned as an *APIError carrying the type of EAN error only
+func (client client) call(ctx context.Context, method string, target string, query url.Values, body []byte,
+	out interface{}, expected ...int) error {
+	request, err := client.newRequest(method, target, query, body)
+	if err != nil {
+		return err
+	}
+	resp, err := client.send(ctx, request, 1)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if !containsStatus(expected, resp.StatusCode) {
+		return newBookingAPIError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	reader, err := uncompressed(resp)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return json.NewDecoder(reader).Decode(out)
+}
+
+//link resolves a link EAN gives, such as /2.4/itineraries/1?token=abc, against the configured url
+func (client client) link(href string) (string, error) {
+	base, err := url.Parse(client.URL)
+	if err != nil {
+		return "", err
+	}
+	reference, err := url.Parse(href)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(reference).String(), nil
+}
+
+func containsStatus(statuses []int, status int) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,216 @@
+package hotel
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"hotels-service-template/logging"
+	"net/http"
+	"testing"
+)
+
+func bookingClient(h http.Handler) (*client, func()) {
+	httpCli, stop := MockHTTPClient(h)
+	client := NewClient(testConfig("http://test.com"), logging.Discard())
+	client.Client = httpCli
+	return client, stop
+}
+
+func TestPriceCheckShouldAskForTheRateWithItsToken(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/properties/12345/rooms/1234501/rates/123450101", r.URL.Path)
+		assert.Equal(t, "sim-token", r.URL.Query().Get("token"))
+		_, _ = w.Write([]byte(`{"status": "matched", "occupancy_pricing": {
+			"2": {"totals": {"inclusive": {"request_currency": {"value": "740.00", "currency": "EUR"}}}}},
+			"links": {"book": {"method": "POST", "href": "/2.4/itineraries?token=book-me"}}}`))
+	})
+	client, stop := bookingClient(h)
+	defer stop()
+
+	check, err := client.priceCheck(context.Background(), testBooking())
+
+	assert.NoError(t, err)
+	assert.Equal(t, priceCheck{Status: priceMatched, Total: Amount{Value: "740.00", Currency: "EUR"},
+		BookToken: "book-me"}, check)
+}
+
+func TestPriceCheckShouldReadAChangedPrice(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"status": "price_changed", "occupancy_pricing": {
+			"2": {"totals": {"inclusive": {"request_currency": {"value": "780.00", "currency": "EUR"}}}}}}`))
+	})
+	client, stop := bookingClient(h)
+	defer stop()
+
+	check, err := client.priceCheck(context.Background(), testBooking())
+
+	assert.NoError(t, err)
+	assert.Equal(t, priceCheck{Status: priceChanged, Total: Amount{Value: "780.00", Currency: "EUR"}}, check)
+}
+
+func TestBookShouldPostTheBookingOnce(t *testing.T) {
+	requests := 0
+	var booking map[string]interface{}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/itineraries", r.URL.Path)
+		assert.Equal(t, "book-me", r.URL.Query().Get("token"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		_ = json.NewDecoder(r.Body).Decode(&booking)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"itinerary_id": "8955599932111", "links": {"retrieve": {"method": "GET",
+			"href": "/2.4/itineraries/8955599932111?token=retrieve-me"}}}`))
+	})
+	client, stop := bookingClient(h)
+	defer stop()
+
+	itineraryId, token, err := client.book(context.Background(), "book-me", testBooking().rapid(42))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "8955599932111", itineraryId)
+	assert.Equal(t, "retrieve-me", token)
+	assert.Equal(t, "hst-42", booking["affiliate_reference_id"])
+	assert.Equal(t, 1, requests)
+}
+
+func TestBookShouldNotRetryAServerError(t *testing.T) {
+	requests := 0
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	client, stop := bookingClient(h)
+	defer stop()
+
+	_, _, err := client.book(context.Background(), "book-me", testBooking().rapid(42))
+
+	assert.IsType(t, &APIError{}, err)
+	assert.True(t, err.(*APIError).Temporary())
+	assert.Equal(t, 1, requests, "EAN may have booked")
+}
+
+func TestRetrieveItinerary(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/itineraries/8955599932111", r.URL.Path)
+		assert.Equal(t, "retrieve-me", r.URL.Query().Get("token"))
+		_, _ = w.Write([]byte(`{"itinerary_id": "8955599932111", "rooms": [{"id": "926784314",
+			"status": "booked", "confirmation_id": {"expedia": "1234567890"}}]}`))
+	})
+	client, stop := bookingClient(h)
+	defer stop()
+
+	itinerary, err := client.retrieveItinerary(context.Background(), "8955599932111", "retrieve-me")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []ItineraryRoom{{Id: "926784314", ConfirmationId: "1234567890", Status: "booked"}},
+		itinerary.rooms())
+}
+
+func TestCancelItineraryShouldCancelEveryBookedRoom(t *testing.T) {
+	var cancelled []string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"itinerary_id": "1", "rooms": [
+				{"id": "a", "status": "booked", "links": {"cancel": {"href": "/itineraries/1/rooms/a?token=ca"}}},
+				{"id": "b", "status": "canceled", "links": {"cancel": {"href": "/itineraries/1/rooms/b?token=cb"}}},
+				{"id": "c", "status": "booked", "links": {"cancel": {"href": "/itineraries/1/rooms/c?token=cc"}}}]}`))
+			return
+		}
+		assert.Equal(t, http.MethodDelete, r.Method)
+		cancelled = append(cancelled, r.URL.Path+"?"+r.URL.RawQuery)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	client, stop := bookingClient(h)
+	defer stop()
+
+	err := client.cancelItinerary(context.Background(), "1", "retrieve-me")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/itineraries/1/rooms/a?token=ca", "/itineraries/1/rooms/c?token=cc"}, cancelled)
+}
+
+func TestCancelItineraryShouldStopAtARejectedCancellation(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"itinerary_id": "1", "rooms": [
+				{"id": "a", "status": "booked", "links": {"cancel": {"href": "/itineraries/1/rooms/a?token=ca"}}}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"type":"room.already_cancelled"}`))
+	})
+	client, stop := bookingClient(h)
+	defer stop()
+
+	err := client.cancelItinerary(context.Background(), "1", "retrieve-me")
+
+	assert.EqualError(t, err, "ean returned 400 Bad Request: room.already_cancelled")
+}
+
+func TestBookShouldKeepOnlyTheTypeOfARejection(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"type": "invalid_input", "message": "card 4111111111111111 of Jane Doe is invalid"}`))
+	})
+	client, stop := bookingClient(h)
+	defer stop()
+
+	_, _, err := client.book(context.Background(), "book-me", testBooking().rapid(42))
+
+	assert.Equal(t, &APIError{StatusCode: http.StatusBadRequest, Type: "invalid_input"}, err)
+	assert.EqualError(t, err, "ean returned 400 Bad Request: invalid_input")
+}
+
+func TestBookShouldSendTheCustomerIp(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "203.0.113.9", r.Header.Get("Customer-Ip"))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"itinerary_id": "8955599932111"}`))
+	})
+	client, stop := bookingClient(h)
+	defer stop()
+
+	_, _, err := client.book(WithCustomerIp(context.Background(), "203.0.113.9"), "book-me",
+		testBooking().rapid(42))
+
+	assert.NoError(t, err)
+}
+
+func TestFindItineraryShouldNotReportTheEmailOfAFailedRequest(t *testing.T) {
+	client := NewClient(testConfig("http://127.0.0.1:1"), logging.Discard())
+
+	_, _, err := client.findItinerary(context.Background(), "hst-1", "jane.doe@example.com")
+
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "jane.doe")
+	assert.Contains(t, err.Error(), "http://127.0.0.1:1/itineraries")
+}
+
+func TestFindItineraryByReference(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/itineraries", r.URL.Path)
+		assert.Equal(t, "jane.doe@example.com", r.URL.Query().Get("email"))
+		if r.URL.Query().Get("affiliate_reference_id") != "hst-1" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"type":"resource_not_found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"itinerary_id": "8955599932111", "links": {"retrieve": {"method": "GET",
+			"href": "/2.4/itineraries/8955599932111?token=retrieve-me"}}}]`))
+	})
+	client, stop := bookingClient(h)
+	defer stop()
+
+	itinerary, found, err := client.findItinerary(context.Background(), "hst-1", "jane.doe@example.com")
+	_, missing, missingErr := client.findItinerary(context.Background(), "hst-2", "jane.doe@example.com")
+
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "8955599932111", itinerary.ItineraryId)
+	assert.Equal(t, "retrieve-me", linkToken(itinerary.Links.Retrieve.Href))
+	assert.NoError(t, missingErr)
+	assert.False(t, missing)
+}
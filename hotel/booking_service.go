@@ -0,0 +1,200 @@
+package hotel
+
+import (
+	"context"
+	"hotels-service-template/tracing"
+	"log/slog"
+	"time"
+)
+
+const (
+	//recordAttempts is how often a change EAN already made is written before giving up on it
+	recordAttempts = 3
+	//heldTimeout is how long a held itinerary EAN has no trace of is waited for before it fails
+	heldTimeout = 15 * time.Minute
+)
+
+type BookingServiceInt interface {
+	Book(ctx context.Context, keyId int64, idempotencyKey string, request BookingRequest) (Itinerary, bool, error)
+	Itinerary(ctx context.Context, keyId int64, id int64) (Itinerary, error)
+	Cancel(ctx context.Context, keyId int64, id int64) (Itinerary, error)
+}
+
+type bookingService struct {
+	itineraries itineraryRepositoryInt
+	client      bookingClientInt
+	recordDelay time.Duration
+	logger      *slog.Logger
+}
+
+func NewBookingService(itineraries itineraryRepositoryInt, client bookingClientInt,
+	logger *slog.Logger) *bookingService {
+	return &bookingService{
+		itineraries: itineraries,
+		client:      client,
+		recordDelay: 200 * time.Millisecond,
+		logger:      logger,
+	}
+}
+
+//Book price checks the rate of request and books it on EAN if it is still sold at the total the customer agreed
+//to. A request is booked once per idempotency key: repeating it returns the itinerary it booked and false, while
+//reusing the key for another request is a conflict. Repeating a request whose itinerary is still held reconciles
+//it with EAN
+func (s *bookingService) Book(ctx context.Context, keyId int64, idempotencyKey string, request BookingRequest) (
+	itinerary Itinerary, created bool, err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "bookingService.Book")
+	defer span.Finish(&err)
+	if err = request.validate(now().UTC()); err != nil {
+		return Itinerary{}, false, err
+	}
+	itinerary, created, err = s.itineraries.create(ctx, keyId, idempotencyKey, request)
+	if err != nil {
+		return Itinerary{}, false, err
+	}
+	span.SetAttribute("itinerary.id", itinerary.Id)
+	if !created {
+		if itinerary.requestHash != request.hash() {
+			return Itinerary{}, false, Conflict("idempotency_key_reused",
+				"the idempotency key was used for another booking")
+		}
+		if itinerary.State == ItineraryHeld {
+			itinerary, err = s.reconcile(ctx, keyId, itinerary, request)
+		}
+		return itinerary, false, err
+	}
+	s.logger.InfoContext(ctx, "booking", "itinerary", itinerary.Id, "request", request)
+
+	check, err := s.client.priceCheck(ctx, request)
+	if err != nil {
+		//nothing was booked yet, the itinerary can fail whatever went wrong
+		s.fail(ctx, itinerary, "price check failed")
+		return Itinerary{}, false, UpstreamUnavailable(err)
+	}
+	switch {
+	case check.Status == priceSoldOut:
+		s.fail(ctx, itinerary, "rate sold out")
+		return Itinerary{}, false, Conflict("rate_sold_out", "the rate is sold out")
+	case check.Status != priceMatched || !sameAmount(check.Total, request.Total):
+		s.fail(ctx, itinerary, "price changed")
+		return Itinerary{}, false, Conflict("price_changed", "the total is now %s %s", check.Total.Value,
+			check.Total.Currency)
+	}
+
+	eanItineraryId, token, err := s.client.book(ctx, check.BookToken, request.rapid(itinerary.Id))
+	if apiErr, ok := err.(*APIError); ok && !apiErr.Temporary() {
+		s.fail(ctx, itinerary, "rejected by EAN")
+		return Itinerary{}, false, UpstreamUnavailable(err)
+	}
+	if err != nil {
+		//EAN may or may not have booked, so the itinerary stays held rather than fail while booked
+		s.logger.WarnContext(ctx, "itinerary left held", "itinerary", itinerary.Id, "error", err)
+		return Itinerary{}, false, UpstreamUnavailable(err)
+	}
+	confirmed, err := s.record(ctx, itinerary, ItineraryConfirmed,
+		itineraryChange{eanItineraryId: eanItineraryId, eanToken: token})
+	if err != nil {
+		//the customer is booked: repeating the request finds the itinerary on EAN again
+		s.logger.ErrorContext(ctx, "itinerary booked on EAN but not recorded", "itinerary", itinerary.Id,
+			"ean_itinerary_id", eanItineraryId, "error", err)
+		return Itinerary{}, false, err
+	}
+	return confirmed, true, nil
+}
+
+//record writes a change EAN already made, retrying a failed write. The request may be gone by then, so the write
+//does not stop with it: losing it would leave EAN and the itineraries apart
+func (s *bookingService) record(ctx context.Context, itinerary Itinerary, to ItineraryState,
+	change itineraryChange) (Itinerary, error) {
+	ctx = context.WithoutCancel(ctx)
+	for attempt := 1; ; attempt++ {
+		recorded, err := s.itineraries.transition(ctx, itinerary, to, change)
+		if _, conflict := err.(*Error); err == nil || conflict || attempt == recordAttempts {
+			return recorded, err
+		}
+		time.Sleep(s.recordDelay * time.Duration(attempt))
+	}
+}
+
+//reconcile looks a held itinerary up on EAN by the reference it was booked with and records what EAN has. It
+//stays held while EAN has no trace of it, as the booking may still be on its way, and fails after heldTimeout
+func (s *bookingService) reconcile(ctx context.Context, keyId int64, itinerary Itinerary, request BookingRequest) (
+	Itinerary, error) {
+	rapid, found, err := s.client.findItinerary(ctx, request.rapid(itinerary.Id).AffiliateReferenceId, request.Email)
+	if err != nil {
+		return Itinerary{}, UpstreamUnavailable(err)
+	}
+	change, to := itineraryChange{failure: "not booked on EAN"}, ItineraryFailed
+	switch {
+	case found:
+		change, to = itineraryChange{eanItineraryId: rapid.ItineraryId,
+			eanToken: linkToken(rapid.Links.Retrieve.Href)}, ItineraryConfirmed
+	case now().Sub(itinerary.CreatedAt) < heldTimeout:
+		return itinerary, nil
+	}
+	reconciled, err := s.record(ctx, itinerary, to, change)
+	if _, conflict := err.(*Error); conflict {
+		//the booking itself recorded it meanwhile
+		return s.itineraries.get(ctx, keyId, itinerary.Id)
+	}
+	return reconciled, err
+}
+
+//fail moves an itinerary EAN did not book to failed. Failing to record it is only logged: the booking failed
+//either way
+func (s *bookingService) fail(ctx context.Context, itinerary Itinerary, failure string) {
+	_, err := s.itineraries.transition(ctx, itinerary, ItineraryFailed, itineraryChange{failure: failure})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to record failed itinerary", "itinerary", itinerary.Id, "error", err)
+	}
+}
+
+//Itinerary returns an itinerary booked with the api key keyId. Those booked on EAN come with their rooms as EAN
+//has them, or without when EAN cannot be reached
+func (s *bookingService) Itinerary(ctx context.Context, keyId int64, id int64) (itinerary Itinerary, err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "bookingService.Itinerary")
+	defer span.Finish(&err)
+	itinerary, err = s.itineraries.get(ctx, keyId, id)
+	if err != nil {
+		return Itinerary{}, notFound(err, "itinerary_not_found", "itinerary %d does not exist", id)
+	}
+	if itinerary.EANItineraryId == "" {
+		return itinerary, nil
+	}
+	rapid, err := s.client.retrieveItinerary(ctx, itinerary.EANItineraryId, itinerary.eanToken)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to retrieve itinerary from EAN", "itinerary", id, "error", err)
+		return itinerary, nil
+	}
+	itinerary.Rooms = rapid.rooms()
+	return itinerary, nil
+}
+
+//Cancel cancels a confirmed itinerary on EAN. Cancelling a cancelled itinerary returns it as it is
+func (s *bookingService) Cancel(ctx context.Context, keyId int64, id int64) (itinerary Itinerary, err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "bookingService.Cancel")
+	defer span.Finish(&err)
+	itinerary, err = s.itineraries.get(ctx, keyId, id)
+	if err != nil {
+		return Itinerary{}, notFound(err, "itinerary_not_found", "itinerary %d does not exist", id)
+	}
+	switch itinerary.State {
+	case ItineraryCancelled:
+		return itinerary, nil
+	case ItineraryHeld:
+		return Itinerary{}, Conflict("booking_in_progress", "itinerary %d is still being booked", id)
+	case ItineraryFailed:
+		return Itinerary{}, Conflict("itinerary_not_cancellable", "itinerary %d was not booked", id)
+	}
+	//cancelling again after the write below failed only cancels what is left, so a repeated Cancel catches up
+	if err = s.client.cancelItinerary(ctx, itinerary.EANItineraryId, itinerary.eanToken); err != nil {
+		return Itinerary{}, UpstreamUnavailable(err)
+	}
+	cancelled, err := s.record(ctx, itinerary, ItineraryCancelled, itineraryChange{})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "itinerary cancelled on EAN but not recorded", "itinerary", itinerary.Id,
+			"ean_itinerary_id", itinerary.EANItineraryId, "error", err)
+		return Itinerary{}, err
+	}
+	return cancelled, nil
+}
@@ -0,0 +1,325 @@
+package hotel
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"hotels-service-template/logging"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type BookingServiceTestSuite struct {
+	suite.Suite
+	itineraries *MockItineraryRepository
+	client      *mockBookingClient
+	service     *bookingService
+}
+
+func (s *BookingServiceTestSuite) SetupTest() {
+	now = func() time.Time {
+		return bookingDay
+	}
+	s.itineraries = &MockItineraryRepository{}
+	s.client = &mockBookingClient{}
+	s.service = NewBookingService(s.itineraries, s.client, logging.Discard())
+	s.service.recordDelay = 0
+}
+
+func TestBookingServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(BookingServiceTestSuite))
+}
+
+func heldItinerary() Itinerary {
+	return Itinerary{Id: 1, State: ItineraryHeld, PropertyId: "12345", RoomId: "1234501", RateId: "123450101",
+		RoomCount: 2, Total: Amount{Value: "740.00", Currency: "EUR"}, requestHash: testBooking().hash()}
+}
+
+func confirmedItinerary() Itinerary {
+	itinerary := heldItinerary()
+	itinerary.State = ItineraryConfirmed
+	itinerary.EANItineraryId = "8955599932111"
+	itinerary.eanToken = "retrieve-me"
+	return itinerary
+}
+
+func matched(total string) priceCheck {
+	return priceCheck{Status: priceMatched, Total: Amount{Value: total, Currency: "EUR"}, BookToken: "book-me"}
+}
+
+func (s *BookingServiceTestSuite) TestBookShouldPriceCheckBookAndConfirm() {
+	request := testBooking()
+	s.itineraries.On("create", int64(7), "key-1", request).Return(heldItinerary(), true, nil)
+	s.client.On("priceCheck", request).Return(matched("740"), nil)
+	s.client.On("book", "book-me", request.rapid(1)).Return("8955599932111", "retrieve-me", nil)
+	s.itineraries.On("transition", heldItinerary(), ItineraryConfirmed,
+		itineraryChange{eanItineraryId: "8955599932111", eanToken: "retrieve-me"}).Return(confirmedItinerary(), nil)
+
+	itinerary, created, err := s.service.Book(context.Background(), 7, "key-1", request)
+
+	assert.NoError(s.T(), err)
+	assert.True(s.T(), created)
+	assert.Equal(s.T(), confirmedItinerary(), itinerary)
+}
+
+func (s *BookingServiceTestSuite) TestBookShouldValidateBeforeStoringAnything() {
+	request := testBooking()
+	request.Payment.Number = "1234"
+
+	_, _, err := s.service.Book(context.Background(), 7, "key-1", request)
+
+	assert.Equal(s.T(), KindInvalidInput, AsError(err).Kind)
+	s.itineraries.AssertNotCalled(s.T(), "create", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *BookingServiceTestSuite) TestBookShouldReplayTheItineraryOfTheSameRequest() {
+	request := testBooking()
+	s.itineraries.On("create", int64(7), "key-1", request).Return(confirmedItinerary(), false, nil)
+
+	itinerary, created, err := s.service.Book(context.Background(), 7, "key-1", request)
+
+	assert.NoError(s.T(), err)
+	assert.False(s.T(), created)
+	assert.Equal(s.T(), confirmedItinerary(), itinerary)
+	s.client.AssertNotCalled(s.T(), "priceCheck", mock.Anything)
+}
+
+func (s *BookingServiceTestSuite) TestBookShouldRejectAKeyReusedForAnotherRequest() {
+	request := testBooking()
+	request.Rooms = request.Rooms[:1]
+	s.itineraries.On("create", int64(7), "key-1", request).Return(confirmedItinerary(), false, nil)
+
+	_, _, err := s.service.Book(context.Background(), 7, "key-1", request)
+
+	assert.Equal(s.T(), Conflict("idempotency_key_reused", "the idempotency key was used for another booking"), err)
+	s.client.AssertNotCalled(s.T(), "priceCheck", mock.Anything)
+}
+
+func (s *BookingServiceTestSuite) TestBookShouldFailWhenThePriceChanged() {
+	request := testBooking()
+	s.itineraries.On("create", int64(7), "key-1", request).Return(heldItinerary(), true, nil)
+	s.client.On("priceCheck", request).Return(matched("780.00"), nil)
+	s.itineraries.On("transition", heldItinerary(), ItineraryFailed, itineraryChange{failure: "price changed"}).
+		Return(Itinerary{}, nil)
+
+	_, _, err := s.service.Book(context.Background(), 7, "key-1", request)
+
+	assert.Equal(s.T(), Conflict("price_changed", "the total is now 780.00 EUR"), err)
+	s.client.AssertNotCalled(s.T(), "book", mock.Anything, mock.Anything)
+	s.itineraries.AssertExpectations(s.T())
+}
+
+func (s *BookingServiceTestSuite) TestBookShouldFailWhenTheRateSoldOut() {
+	request := testBooking()
+	s.itineraries.On("create", int64(7), "key-1", request).Return(heldItinerary(), true, nil)
+	s.client.On("priceCheck", request).Return(priceCheck{Status: priceSoldOut}, nil)
+	s.itineraries.On("transition", heldItinerary(), ItineraryFailed, itineraryChange{failure: "rate sold out"}).
+		Return(Itinerary{}, nil)
+
+	_, _, err := s.service.Book(context.Background(), 7, "key-1", request)
+
+	assert.Equal(s.T(), Conflict("rate_sold_out", "the rate is sold out"), err)
+	s.itineraries.AssertExpectations(s.T())
+}
+
+func (s *BookingServiceTestSuite) TestBookShouldFailWhenEANRejectsTheBooking() {
+	request := testBooking()
+	rejected := &APIError{StatusCode: http.StatusBadRequest}
+	s.itineraries.On("create", int64(7), "key-1", request).Return(heldItinerary(), true, nil)
+	s.client.On("priceCheck", request).Return(matched("740.00"), nil)
+	s.client.On("book", "book-me", request.rapid(1)).Return("", "", rejected)
+	s.itineraries.On("transition", heldItinerary(), ItineraryFailed, itineraryChange{failure: "rejected by EAN"}).
+		Return(Itinerary{}, nil)
+
+	_, _, err := s.service.Book(context.Background(), 7, "key-1", request)
+
+	assert.Equal(s.T(), UpstreamUnavailable(rejected), err)
+	s.itineraries.AssertExpectations(s.T())
+}
+
+func (s *BookingServiceTestSuite) TestBookShouldLeaveTheItineraryHeldWhenEANMayHaveBooked() {
+	request := testBooking()
+	s.itineraries.On("create", int64(7), "key-1", request).Return(heldItinerary(), true, nil)
+	s.client.On("priceCheck", request).Return(matched("740.00"), nil)
+	s.client.On("book", "book-me", request.rapid(1)).Return("", "", errors.New("connection reset"))
+
+	_, _, err := s.service.Book(context.Background(), 7, "key-1", request)
+
+	assert.Equal(s.T(), KindUpstreamUnavailable, AsError(err).Kind)
+	s.itineraries.AssertNotCalled(s.T(), "transition", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *BookingServiceTestSuite) TestBookShouldRetryRecordingWhatEANBooked() {
+	request := testBooking()
+	confirmation := itineraryChange{eanItineraryId: "8955599932111", eanToken: "retrieve-me"}
+	s.itineraries.On("create", int64(7), "key-1", request).Return(heldItinerary(), true, nil)
+	s.client.On("priceCheck", request).Return(matched("740.00"), nil)
+	s.client.On("book", "book-me", request.rapid(1)).Return("8955599932111", "retrieve-me", nil)
+	s.itineraries.On("transition", heldItinerary(), ItineraryConfirmed, confirmation).
+		Return(Itinerary{}, errors.New("connection refused")).Once()
+	s.itineraries.On("transition", heldItinerary(), ItineraryConfirmed, confirmation).
+		Return(confirmedItinerary(), nil).Once()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	itinerary, created, err := s.service.Book(ctx, 7, "key-1", request)
+
+	assert.NoError(s.T(), err)
+	assert.True(s.T(), created)
+	assert.Equal(s.T(), confirmedItinerary(), itinerary)
+	s.itineraries.AssertNumberOfCalls(s.T(), "transition", 2)
+}
+
+func (s *BookingServiceTestSuite) TestBookShouldFailWhenWhatEANBookedCannotBeRecorded() {
+	request := testBooking()
+	s.itineraries.On("create", int64(7), "key-1", request).Return(heldItinerary(), true, nil)
+	s.client.On("priceCheck", request).Return(matched("740.00"), nil)
+	s.client.On("book", "book-me", request.rapid(1)).Return("8955599932111", "retrieve-me", nil)
+	s.itineraries.On("transition", heldItinerary(), ItineraryConfirmed, mock.Anything).
+		Return(Itinerary{}, errors.New("connection refused"))
+
+	_, _, err := s.service.Book(context.Background(), 7, "key-1", request)
+
+	assert.EqualError(s.T(), err, "connection refused")
+	s.itineraries.AssertNumberOfCalls(s.T(), "transition", recordAttempts)
+}
+
+func (s *BookingServiceTestSuite) TestBookShouldReconcileAHeldItineraryEANBooked() {
+	request := testBooking()
+	var rapid rapidItinerary
+	_ = json.Unmarshal([]byte(`{"itinerary_id": "8955599932111",
+		"links": {"retrieve": {"href": "/2.4/itineraries/8955599932111?token=retrieve-me"}}}`), &rapid)
+	s.itineraries.On("create", int64(7), "key-1", request).Return(heldItinerary(), false, nil)
+	s.client.On("findItinerary", "hst-1", "jane.doe@example.com").Return(rapid, true, nil)
+	s.itineraries.On("transition", heldItinerary(), ItineraryConfirmed,
+		itineraryChange{eanItineraryId: "8955599932111", eanToken: "retrieve-me"}).Return(confirmedItinerary(), nil)
+
+	itinerary, created, err := s.service.Book(context.Background(), 7, "key-1", request)
+
+	assert.NoError(s.T(), err)
+	assert.False(s.T(), created)
+	assert.Equal(s.T(), confirmedItinerary(), itinerary)
+	s.client.AssertNotCalled(s.T(), "book", mock.Anything, mock.Anything)
+}
+
+func (s *BookingServiceTestSuite) TestBookShouldKeepARecentHeldItineraryEANHasNoTraceOf() {
+	request := testBooking()
+	held := heldItinerary()
+	held.CreatedAt = bookingDay.Add(-time.Minute)
+	s.itineraries.On("create", int64(7), "key-1", request).Return(held, false, nil)
+	s.client.On("findItinerary", "hst-1", "jane.doe@example.com").Return(rapidItinerary{}, false, nil)
+
+	itinerary, _, err := s.service.Book(context.Background(), 7, "key-1", request)
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), held, itinerary)
+	s.itineraries.AssertNotCalled(s.T(), "transition", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *BookingServiceTestSuite) TestBookShouldFailAnOldHeldItineraryEANHasNoTraceOf() {
+	request := testBooking()
+	held := heldItinerary()
+	held.CreatedAt = bookingDay.Add(-heldTimeout)
+	failed := held
+	failed.State = ItineraryFailed
+	s.itineraries.On("create", int64(7), "key-1", request).Return(held, false, nil)
+	s.client.On("findItinerary", "hst-1", "jane.doe@example.com").Return(rapidItinerary{}, false, nil)
+	s.itineraries.On("transition", held, ItineraryFailed, itineraryChange{failure: "not booked on EAN"}).
+		Return(failed, nil)
+
+	itinerary, _, err := s.service.Book(context.Background(), 7, "key-1", request)
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), failed, itinerary)
+}
+
+func (s *BookingServiceTestSuite) TestItineraryShouldComeWithTheRoomsEANHas() {
+	s.itineraries.On("get", int64(7), int64(1)).Return(confirmedItinerary(), nil)
+	var rapid rapidItinerary
+	_ = json.Unmarshal([]byte(`{"itinerary_id": "8955599932111",
+		"rooms": [{"id": "926784314", "status": "booked"}]}`), &rapid)
+	s.client.On("retrieveItinerary", "8955599932111", "retrieve-me").Return(rapid, nil)
+
+	itinerary, err := s.service.Itinerary(context.Background(), 7, 1)
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []ItineraryRoom{{Id: "926784314", Status: "booked"}}, itinerary.Rooms)
+}
+
+func (s *BookingServiceTestSuite) TestItineraryShouldBeServedWithoutRoomsWhenEANIsUnavailable() {
+	s.itineraries.On("get", int64(7), int64(1)).Return(confirmedItinerary(), nil)
+	s.client.On("retrieveItinerary", "8955599932111", "retrieve-me").Return(rapidItinerary{},
+		&APIError{StatusCode: http.StatusServiceUnavailable})
+
+	itinerary, err := s.service.Itinerary(context.Background(), 7, 1)
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), confirmedItinerary(), itinerary)
+}
+
+func (s *BookingServiceTestSuite) TestItineraryOfAnotherKey() {
+	s.itineraries.On("get", int64(8), int64(1)).Return(Itinerary{}, sql.ErrNoRows)
+
+	_, err := s.service.Itinerary(context.Background(), 8, 1)
+
+	assert.Equal(s.T(), NotFound("itinerary_not_found", "itinerary 1 does not exist"), err)
+}
+
+func (s *BookingServiceTestSuite) TestCancelShouldCancelOnEANThenStoreIt() {
+	cancelled := confirmedItinerary()
+	cancelled.State = ItineraryCancelled
+	s.itineraries.On("get", int64(7), int64(1)).Return(confirmedItinerary(), nil)
+	s.client.On("cancelItinerary", "8955599932111", "retrieve-me").Return(nil)
+	s.itineraries.On("transition", confirmedItinerary(), ItineraryCancelled, itineraryChange{}).
+		Return(cancelled, nil)
+
+	itinerary, err := s.service.Cancel(context.Background(), 7, 1)
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), cancelled, itinerary)
+}
+
+func (s *BookingServiceTestSuite) TestCancelShouldRetryRecordingWhatEANCancelled() {
+	cancelled := confirmedItinerary()
+	cancelled.State = ItineraryCancelled
+	s.itineraries.On("get", int64(7), int64(1)).Return(confirmedItinerary(), nil)
+	s.client.On("cancelItinerary", "8955599932111", "retrieve-me").Return(nil)
+	s.itineraries.On("transition", confirmedItinerary(), ItineraryCancelled, itineraryChange{}).
+		Return(Itinerary{}, errors.New("connection refused")).Once()
+	s.itineraries.On("transition", confirmedItinerary(), ItineraryCancelled, itineraryChange{}).
+		Return(cancelled, nil).Once()
+
+	itinerary, err := s.service.Cancel(context.Background(), 7, 1)
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), cancelled, itinerary)
+}
+
+func (s *BookingServiceTestSuite) TestCancelShouldKeepTheItineraryConfirmedWhenEANFails() {
+	s.itineraries.On("get", int64(7), int64(1)).Return(confirmedItinerary(), nil)
+	s.client.On("cancelItinerary", "8955599932111", "retrieve-me").Return(errors.New("timeout"))
+
+	_, err := s.service.Cancel(context.Background(), 7, 1)
+
+	assert.Equal(s.T(), KindUpstreamUnavailable, AsError(err).Kind)
+	s.itineraries.AssertNotCalled(s.T(), "transition", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *BookingServiceTestSuite) TestCancelOfItinerariesNotBooked() {
+	failed := heldItinerary()
+	failed.State = ItineraryFailed
+	s.itineraries.On("get", int64(7), int64(1)).Return(heldItinerary(), nil)
+	s.itineraries.On("get", int64(7), int64(2)).Return(failed, nil)
+
+	_, heldErr := s.service.Cancel(context.Background(), 7, 1)
+	_, failedErr := s.service.Cancel(context.Background(), 7, 2)
+
+	assert.Equal(s.T(), Conflict("booking_in_progress", "itinerary 1 is still being booked"), heldErr)
+	assert.Equal(s.T(), Conflict("itinerary_not_cancellable", "itinerary 2 was not booked"), failedErr)
+	s.client.AssertNotCalled(s.T(), "cancelItinerary", mock.Anything, mock.Anything)
+}
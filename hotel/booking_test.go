@@ -0,0 +1,147 @@
+package hotel
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testBooking() BookingRequest {
+	return BookingRequest{PropertyId: "12345", RoomId: "1234501", RateId: "123450101", Token: "sim-token",
+		Total: Amount{Value: "740.00", Currency: "EUR"}, Email: "jane.doe@example.com",
+		Phone: Phone{CountryCode: "1", AreaCode: "487", Number: "5550077"},
+		Rooms: []Guest{{GivenName: "Jane", FamilyName: "Doe"}, {GivenName: "John", FamilyName: "O'Brien"}},
+		Payment: Payment{Number: "4111111111111111", SecurityCode: "737", ExpirationMonth: "08",
+			ExpirationYear: "2028", BillingContact: BillingContact{GivenName: "Jane", FamilyName: "Doe",
+				Address: Address{Line1: "555 1st St", City: "Seattle", CountryCode: "US"}}}}
+}
+
+var bookingDay = time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC)
+
+func TestValidateBooking(t *testing.T) {
+	assert.Nil(t, testBooking().validate(bookingDay))
+}
+
+func TestValidateBookingShouldNameEveryInvalidField(t *testing.T) {
+	request := testBooking()
+	request.Email = "jane.doe"
+	request.Rooms[1].GivenName = ""
+	request.Rooms[1].SpecialRequest = strings.Repeat("x", 256)
+	request.Payment.Number = "4111111111111112"
+	request.Payment.ExpirationYear = "2026"
+	request.Payment.ExpirationMonth = "09"
+
+	err := request.validate(bookingDay)
+
+	assert.Equal(t, InvalidParams([]InvalidParam{
+		{Name: "email", Reason: "must be an email address"},
+		{Name: "rooms[1].given_name", Reason: "is required"},
+		{Name: "rooms[1].special_request", Reason: "must be at most 255 characters"},
+		{Name: "payment.number", Reason: "must be a valid card number"},
+		{Name: "payment.expiration_year", Reason: "the card has expired"},
+	}), err)
+}
+
+func TestValidateBookingShouldNotQuoteTheValues(t *testing.T) {
+	request := testBooking()
+	request.Rooms[0].FamilyName = "Doe<script>"
+	request.Payment.SecurityCode = "12a"
+
+	err := request.validate(bookingDay)
+
+	assert.NotContains(t, fmt.Sprint(AsError(err).Params), "script")
+	assert.NotContains(t, fmt.Sprint(AsError(err).Params), "12a")
+}
+
+func TestValidateBookingShouldNeedOneToEightRooms(t *testing.T) {
+	request := testBooking()
+	request.Rooms = nil
+
+	err := request.validate(bookingDay)
+
+	assert.Equal(t, []InvalidParam{{Name: "rooms", Reason: "must list from 1 to 8 guests, one per room"}},
+		AsError(err).Params)
+}
+
+func TestLuhn(t *testing.T) {
+	assert.True(t, luhn("4111111111111111"))
+	assert.True(t, luhn("5500005555555559"))
+	assert.False(t, luhn("4111111111111112"))
+}
+
+func TestBookingRequestShouldNotPrintCustomerDetails(t *testing.T) {
+	request := testBooking()
+	var logged strings.Builder
+	//without the time, whose digits could match the details looked for
+	logger := slog.New(slog.NewJSONHandler(&logged, &slog.HandlerOptions{ReplaceAttr: func(groups []string,
+		attr slog.Attr) slog.Attr {
+		if attr.Key == slog.TimeKey && len(groups) == 0 {
+			return slog.Attr{}
+		}
+		return attr
+	}}))
+
+	logger.Info("booking", "request", request, "payment", request.Payment, "guest", request.Rooms[0],
+		"phone", request.Phone)
+	printed := fmt.Sprintf("%v %+v %#v %s", request, request, request, request.Phone) + logged.String()
+
+	for _, detail := range []string{"Jane", "jane.doe", "5550077", "4111111111111111", "737", "Seattle"} {
+		assert.NotContains(t, printed, detail)
+	}
+	assert.Contains(t, logged.String(), `"property_id":"12345"`)
+}
+
+func TestBookingRequestHashShouldLeaveThePaymentOut(t *testing.T) {
+	request := testBooking()
+	other := testBooking()
+	other.Payment.Number = "5500005555555559"
+	changed := testBooking()
+	changed.Rooms[0].GivenName = "Janet"
+
+	assert.Equal(t, request.hash(), other.hash())
+	assert.NotEqual(t, request.hash(), changed.hash())
+	assert.Len(t, request.hash(), 64)
+}
+
+func TestRapidBookingShouldReferenceTheItinerary(t *testing.T) {
+	booking := testBooking().rapid(42)
+
+	assert.Equal(t, "hst-42", booking.AffiliateReferenceId)
+	assert.Len(t, booking.Rooms, 2)
+	data, _ := json.Marshal(booking.Payments)
+	assert.JSONEq(t, `[{"type": "customer_card", "number": "4111111111111111", "security_code": "737",
+		"expiration_month": "08", "expiration_year": "2028", "billing_contact": {"given_name": "Jane",
+		"family_name": "Doe", "address": {"line_1": "555 1st St", "city": "Seattle", "country_code": "US"}}}]`,
+		string(data))
+}
+
+func TestRapidPriceCheck(t *testing.T) {
+	var rapid rapidPriceCheck
+	_ = json.Unmarshal([]byte(`{"status": "matched", "occupancy_pricing": {
+		"2": {"totals": {"inclusive": {"request_currency": {"value": "370.00", "currency": "EUR"}}}},
+		"1": {"totals": {"inclusive": {"request_currency": {"value": "370", "currency": "EUR"}}}}},
+		"links": {"book": {"method": "POST", "href": "/2.4/itineraries?token=book-me"}}}`), &rapid)
+
+	assert.Equal(t, priceCheck{Status: priceMatched, Total: Amount{Value: "740.00", Currency: "EUR"},
+		BookToken: "book-me"}, rapid.priceCheck())
+}
+
+func TestSameAmount(t *testing.T) {
+	assert.True(t, sameAmount(Amount{Value: "740", Currency: "EUR"}, Amount{Value: "740.00", Currency: "EUR"}))
+	assert.False(t, sameAmount(Amount{Value: "740", Currency: "EUR"}, Amount{Value: "740", Currency: "USD"}))
+	assert.False(t, sameAmount(Amount{Value: "740", Currency: "EUR"}, Amount{Value: "740.01", Currency: "EUR"}))
+	assert.False(t, sameAmount(Amount{}, Amount{}))
+}
+
+func TestItineraryStates(t *testing.T) {
+	assert.True(t, ItineraryHeld.canBecome(ItineraryConfirmed))
+	assert.True(t, ItineraryHeld.canBecome(ItineraryFailed))
+	assert.True(t, ItineraryConfirmed.canBecome(ItineraryCancelled))
+	assert.False(t, ItineraryHeld.canBecome(ItineraryCancelled))
+	assert.False(t, ItineraryFailed.canBecome(ItineraryConfirmed))
+	assert.False(t, ItineraryCancelled.canBecome(ItineraryConfirmed))
+}
@@ -1,124 +1,462 @@
 package hotel
 
 import (
+	"bytes"
 	"compress/gzip"
+	"context"
 	"crypto/sha512"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
-	"github.com/spf13/viper"
+	"hotels-service-template/tracing"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 )
 
-const regionsEndpoint = "regions"
+const (
+	regionsEndpoint      = "regions"
+	propertiesEndpoint   = "properties/content"
+	availabilityEndpoint = "properties/availability"
+	//supplySource asks properties/content for the properties sold by Expedia
+	supplySource = "expedia"
+	//salesChannel and salesEnvironment tell EAN availability is shopped for a website selling hotels on their own
+	salesChannel     = "website"
+	salesEnvironment = "hotel_only"
+	//MaxAvailabilityIds is the most properties EAN prices in one availability request
+	MaxAvailabilityIds = 250
+)
 
 type clientInt interface {
-	getRegions() (Regions, error)
+	streamRegions(ctx context.Context, batchSize int, handle func(Regions) error, pageFetched func()) error
+	streamProperties(ctx context.Context, batchSize int, handle func(Properties) error, pageFetched func()) error
+	availability(ctx context.Context, stay Stay, propertyIds []string) ([]PropertyAvailability, error)
+}
+
+//retryPolicy bounds the retries of a single request. The wait before retry n is drawn between half and all of
+//baseDelay * 2^(n-1), capped at maxDelay
+type retryPolicy struct {
+	attempts  int
+	baseDelay time.Duration
+	maxDelay  time.Duration
+}
+
+var defaultRetryPolicy = retryPolicy{attempts: 5, baseDelay: 500 * time.Millisecond, maxDelay: 30 * time.Second}
+
+const DefaultLanguage = "en-US"
+
+//DefaultInclude asks EAN for everything a stored region holds
+var DefaultInclude = []string{"details", "property_ids", "property_ids_expanded"}
+
+//ClientConfig says where EAN is, how to sign requests and what to ask for
+type ClientConfig struct {
+	URL       string
+	ApiKey    string
+	SecretKey string
+	Language  string
+	Include   []string
 }
 
 type client struct {
-	url string
+	ClientConfig
+	retry  retryPolicy
+	sleep  func(ctx context.Context, d time.Duration) error
+	logger *slog.Logger
 	*http.Client
 }
 
-func NewClient(url string) *client {
-	return &client{url: url, Client: &http.Client{}}
+func NewClient(config ClientConfig, logger *slog.Logger) *client {
+	return &client{ClientConfig: config, retry: defaultRetryPolicy, sleep: sleep, logger: logger,
+		Client: &http.Client{}}
+}
+
+//streamRegions pages through the EAN regions and hands them to handle in batches of at most batchSize, so only
+//one batch and the page being decoded are held in memory no matter how many regions EAN returns. pageFetched is
+//called after every page is decoded. The download is traced in a span, with a child span for every page
+func (client client) streamRegions(ctx context.Context, batchSize int, handle func(Regions) error,
+	pageFetched func()) (err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "EAN regions")
+	defer span.Finish(&err)
+	query := url.Values{"language": {client.Language}, "include": client.Include}
+	request, err := client.createRequest(fmt.Sprintf("%s/%s", client.URL, regionsEndpoint), query)
+	if err != nil {
+		return err
+	}
+
+	batch := Regions{}
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := handle(batch)
+		batch = Regions{}
+		return err
+	}
+
+	pages, err := client.stream(ctx, "regions", request, func(id string, decoder *json.Decoder) error {
+		var region Region
+		if err := decoder.Decode(&region); err != nil {
+			return err
+		}
+		batch[id] = region
+		if len(batch) >= batchSize {
+			return flush()
+		}
+		return nil
+	}, pageFetched)
+	if err != nil {
+		return err
+	}
+	span.SetAttribute("ean.pages", pages)
+	return flush()
 }
 
-func (client client) getRegions() (Regions, error) {
-	request, err := createRequest(fmt.Sprintf("%s/%s", client.url, regionsEndpoint))
+//streamProperties pages through the content of the EAN properties and hands it to handle in batches of at most
+//batchSize, the way streamRegions does with regions
+func (client client) streamProperties(ctx context.Context, batchSize int, handle func(Properties) error,
+	pageFetched func()) (err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "EAN properties")
+	defer span.Finish(&err)
+	query := url.Values{"language": {client.Language}, "supply_source": {supplySource}}
+	request, err := client.createRequest(fmt.Sprintf("%s/%s", client.URL, propertiesEndpoint), query)
 	if err != nil {
-		return Regions{}, err
+		return err
 	}
 
-	var regions Regions
-	retries := 5
+	batch := Properties{}
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := handle(batch)
+		batch = Properties{}
+		return err
+	}
+
+	pages, err := client.stream(ctx, "properties", request, func(id string, decoder *json.Decoder) error {
+		var rapid rapidProperty
+		if err := decoder.Decode(&rapid); err != nil {
+			return err
+		}
+		batch[id] = rapid.property(id)
+		if len(batch) >= batchSize {
+			return flush()
+		}
+		return nil
+	}, pageFetched)
+	if err != nil {
+		return err
+	}
+	span.SetAttribute("ean.pages", pages)
+	return flush()
+}
+
+//availability asks EAN for the rates of at most MaxAvailabilityIds properties for a stay. Properties EAN has
+//nothing available in are left out
+func (client client) availability(ctx context.Context, stay Stay, propertyIds []string) (
+	availabilities []PropertyAvailability, err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "EAN availability")
+	defer span.Finish(&err)
+	span.SetAttribute("ean.properties", len(propertyIds))
+	query := url.Values{
+		"checkin":           {stay.CheckIn.Format(DateLayout)},
+		"checkout":          {stay.CheckOut.Format(DateLayout)},
+		"occupancy":         stay.Occupancy,
+		"currency":          {stay.Currency},
+		"country_code":      {stay.CountryOfResidence},
+		"language":          {client.Language},
+		"property_id":       propertyIds,
+		"sales_channel":     {salesChannel},
+		"sales_environment": {salesEnvironment},
+		"rate_plan_count":   {"1"},
+	}
+	request, err := client.createRequest(fmt.Sprintf("%s/%s", client.URL, availabilityEndpoint), query)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.fetch(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := uncompressed(resp)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var rapid []rapidAvailability
+	if err = json.NewDecoder(body).Decode(&rapid); err != nil {
+		return nil, err
+	}
+	availabilities = make([]PropertyAvailability, 0, len(rapid))
+	for _, property := range rapid {
+		if availability := property.availability(stay.Occupancy); len(availability.Rates) > 0 {
+			availabilities = append(availabilities, availability)
+		}
+	}
+	span.SetAttribute("ean.available", len(availabilities))
+	return availabilities, nil
+}
+
+//stream follows the pages of an EAN listing from request, handing every entry to handle as it is decoded and
+//calling pageFetched after every page. what names the entries in spans, logs and errors. It returns how many pages
+//were fetched
+func (client client) stream(ctx context.Context, what string, request *http.Request,
+	handle func(id string, decoder *json.Decoder) error, pageFetched func()) (int, error) {
+	pages := 0
 	for ok := true; ok; {
-		resp, err := client.Do(request)
-		if err != nil || resp.StatusCode != http.StatusOK {
-			if retries > 0 {
-				retries--
-				continue
-			} else {
-				return Regions{}, errors.New(fmt.Sprintf("Do error : %v ", err))
+		pages++
+		var err error
+		request, ok, err = client.streamPage(ctx, what, pages, request, handle)
+		if err != nil {
+			return pages, err
+		}
+		pageFetched()
+	}
+	return pages, nil
+}
+
+//streamPage fetches page number page of a listing with request and decodes it into handle, in a span. It returns
+//the request of the next page and whether there is one
+func (client client) streamPage(ctx context.Context, what string, page int, request *http.Request,
+	handle func(id string, decoder *json.Decoder) error) (next *http.Request, more bool, err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "EAN "+what+" page")
+	defer span.Finish(&err)
+	span.SetAttribute("ean.page", page)
+	resp, err := client.fetch(ctx, request)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	next, more, err = client.getNextLink(resp)
+	if err != nil {
+		return nil, false, err
+	}
+	if err = decode(resp, what, handle); err != nil {
+		return nil, false, err
+	}
+	eanPages.Inc(what)
+	client.logger.DebugContext(ctx, "EAN "+what+" page fetched", "page", page, "more", more)
+	return next, more, nil
+}
+
+//fetch sends request, retrying temporary failures with exponential backoff, or for as long as EAN asks in
+//Retry-After up to the longest backoff. Transport errors are retried only when retryable. The returned error is the
+//last *APIError or transport error, or the context error once ctx is done
+func (client client) fetch(ctx context.Context, request *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := client.send(ctx, request, attempt)
+		if ctx.Err() != nil {
+			if err == nil {
+				resp.Body.Close()
 			}
+			return nil, ctx.Err()
 		}
-		request, ok, err = getNextLink(resp)
-		if err != nil {
-			return Regions{}, err
+		if err == nil && resp.StatusCode == http.StatusOK {
+			return resp, nil
 		}
-		err = decode(resp, &regions)
-		if err != nil {
-			return Regions{}, err
+
+		if err != nil && !retryable(err) {
+			return nil, err
+		}
+		delay := client.retry.delay(attempt)
+		if err == nil {
+			apiErr := newAPIError(resp)
+			resp.Body.Close()
+			if !apiErr.Temporary() {
+				return nil, apiErr
+			}
+			if apiErr.RetryAfter > 0 {
+				delay = apiErr.RetryAfter
+			}
+			if delay > client.retry.maxDelay {
+				delay = client.retry.maxDelay
+			}
+			err = apiErr
 		}
+		if attempt >= client.retry.attempts {
+			return nil, errors.Wrapf(err, "giving up after %d attempts", attempt)
+		}
+		eanRetries.Inc()
+		client.logger.WarnContext(ctx, "EAN request failed, retrying", "url", request.URL.Path, "attempt", attempt,
+			"delay", delay, "error", err)
+		if err := client.sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
 
-		resp.Body.Close()
+//send makes one attempt at request in a client span, passing the trace on to EAN in the traceparent header
+func (client client) send(ctx context.Context, request *http.Request, attempt int) (*http.Response, error) {
+	ctx, span := tracing.Start(ctx, tracing.Client, request.Method+" "+request.URL.Path)
+	defer span.End()
+	span.SetAttribute("http.method", request.Method)
+	span.SetAttribute("http.url", request.URL.Scheme+"://"+request.URL.Host+request.URL.Path)
+	span.SetAttribute("attempt", attempt)
+	request.Header.Set("Authorization", client.getAuthHeader())
+	if ip := CustomerIp(ctx); ip != "" {
+		request.Header.Set("Customer-Ip", ip)
+	}
+	tracing.Inject(ctx, request.Header)
+	resp, err := client.Do(request.WithContext(ctx))
+	if urlErr, ok := err.(*url.Error); ok {
+		//the query carries booking tokens and emails, which must not end in logs and spans
+		err = &url.Error{Op: urlErr.Op, URL: request.URL.Scheme + "://" + request.URL.Host + request.URL.Path,
+			Err: urlErr.Err}
+	}
+	if err != nil {
+		eanRequests.Inc("error")
+		span.SetError(err)
+		return nil, err
 	}
-	return regions, nil
+	eanRequests.Inc(strconv.Itoa(resp.StatusCode))
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetError(errors.Errorf("EAN answered %s", resp.Status))
+	}
+	return resp, nil
 }
 
-func decode(resp *http.Response, regions *Regions) error {
+func (policy retryPolicy) delay(attempt int) time.Duration {
+	delay := policy.maxDelay
+	if attempt < 32 && policy.baseDelay<<uint(attempt-1) < policy.maxDelay {
+		delay = policy.baseDelay << uint(attempt-1)
+	}
+	if delay <= 1 {
+		return delay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+}
 
-	if resp.Header.Get("Content-Encoding") == "gzip" {
-		gzipReader, err := gzip.NewReader(resp.Body)
+//sleep waits for d or until ctx is done, whichever comes first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+//decode reads a page, a json object of entries by id, one entry at a time instead of unmarshalling the whole page
+//into a map. handle decodes the entry from decoder. what names the entries in errors
+func decode(resp *http.Response, what string, handle func(id string, decoder *json.Decoder) error) error {
+	body, err := uncompressed(resp)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != json.Delim('{') {
+		return errors.Errorf("expected an object of %s, got %v", what, token)
+	}
+	for decoder.More() {
+		key, err := decoder.Token()
 		if err != nil {
 			return err
 		}
-		resp.Body = gzipReader
+		if err = handle(key.(string), decoder); err != nil {
+			return err
+		}
 	}
-	err := json.NewDecoder(resp.Body).Decode(regions)
-	if err != nil {
-		return err
+	_, err = decoder.Token()
+	return err
+}
+
+//uncompressed returns the body of resp, gunzipped when EAN compressed it. Closing it leaves resp.Body open
+func uncompressed(resp *http.Response) (io.ReadCloser, error) {
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		return gzip.NewReader(resp.Body)
 	}
-	return nil
+	return ioutil.NopCloser(resp.Body), nil
 }
 
-func getNextLink(resp *http.Response) (*http.Request, bool, error) {
+func (client client) getNextLink(resp *http.Response) (*http.Request, bool, error) {
 	link := resp.Header.Get("Link")
 	sep := func(c rune) bool {
 		return c == ';' || c == '<' || c == '>' || c == '"'
 	}
 	if values := strings.FieldsFunc(link, sep); len(values) > 0 {
-		req, err := createRequest(values[0])
+		req, err := client.createRequest(values[0], nil)
 		return req, true, err
 	}
 	return &http.Request{}, false, nil
 }
 
-func createRequest(target string) (*http.Request, error) {
-	request, err := http.NewRequest("GET", target, nil)
+//createRequest builds a GET request of target with the headers EAN expects. The parameters in query replace those
+//of the same name in target, next page links are used as they are
+func (client client) createRequest(target string, query url.Values) (*http.Request, error) {
+	return client.newRequest(http.MethodGet, target, query, nil)
+}
+
+//newRequest builds a request the way createRequest does, for any method. body, unless nil, is sent as json
+func (client client) newRequest(method string, target string, query url.Values, body []byte) (*http.Request,
+	error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	request, err := http.NewRequest(method, target, reader)
 	if err != nil {
 		return &http.Request{}, err
 	}
 
+	if body != nil {
+		request.Header.Add("Content-Type", "application/json")
+	}
 	request.Header.Add("Accept", "application/json")
 	request.Header.Add("Accept-Encoding", "gzip")
-	request.Header.Add("Customer-Ip", "10.132.20.37") //to be taken from request
+	//send replaces it with the ip of the customer the request is made for, when there is one
+	request.Header.Add("Customer-Ip", "10.132.20.37")
 	request.Header.Add("User-Agent", "BigLife/0.1")
-	request.Header.Add("Authorization", getAuthHeader())
-	q := request.URL.Query()
-	q.Add("language", "en-US")
-	q.Add("include", "details")
-	q.Add("include", "property_ids")
-	q.Add("include", "property_ids_expanded")
-	request.URL.RawQuery = q.Encode()
+	request.Header.Add("Authorization", client.getAuthHeader())
+	if query != nil {
+		q := request.URL.Query()
+		for key, values := range query {
+			q[key] = values
+		}
+		request.URL.RawQuery = q.Encode()
+	}
 
 	return request, nil
 }
 
+type customerIpKey struct{}
+
+//WithCustomerIp stores in ctx the ip of the customer requests to EAN are made for, sent as their Customer-Ip
+func WithCustomerIp(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, customerIpKey{}, ip)
+}
+
+//CustomerIp returns the ip stored by WithCustomerIp, empty when requests are not made for a customer
+func CustomerIp(ctx context.Context) string {
+	ip, _ := ctx.Value(customerIpKey{}).(string)
+	return ip
+}
+
 var now = func() time.Time {
 	return time.Now()
 }
 
-func getAuthHeader() string {
+func (client client) getAuthHeader() string {
 	timeStamp := strconv.FormatInt(now().Unix(), 10)
 	hash := sha512.New()
-	apiKey := viper.GetString("API_KEY")
-	hash.Write([]byte(apiKey + viper.GetString("SECRET_KEY") + timeStamp ))
+	hash.Write([]byte(client.ApiKey + client.SecretKey + timeStamp ))
 	signature := hex.EncodeToString(hash.Sum(nil))
-	return fmt.Sprintf("EAN apikey=%s,signature=%s,timestamp=%s", apiKey, signature, timeStamp)
+	return fmt.Sprintf("EAN apikey=%s,signature=%s,timestamp=%s", client.ApiKey, signature, timeStamp)
 }
@@ -0,0 +1,89 @@
+package hotel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+//maxErrorBody bounds how much of an error response is kept on an APIError
+const maxErrorBody = 1 << 10
+
+//APIError is returned when EAN answers with a status other than 200
+type APIError struct {
+	StatusCode int
+	Body       string
+	//Type is the type of EAN error, such as invalid_input. Errors of booking calls keep only it, not the body
+	Type string
+	//RetryAfter is how long EAN asked us to wait before retrying, zero when it did not say
+	RetryAfter time.Duration
+}
+
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+//newBookingAPIError is newAPIError for booking calls, whose error bodies may echo the guests and payment of the
+//booking: only the type of the EAN error is kept
+func newBookingAPIError(resp *http.Response) *APIError {
+	var rapid struct {
+		Type string `json:"type"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, maxErrorBody)).Decode(&rapid)
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Type:       rapid.Type,
+		RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+func (e *APIError) Error() string {
+	detail := e.Body
+	if detail == "" {
+		detail = e.Type
+	}
+	return fmt.Sprintf("ean returned %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), detail)
+}
+
+//Temporary tells whether the same request may succeed later: rate limiting and server errors are, a rejected
+//request or bad credentials are not
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError &&
+		e.StatusCode != http.StatusNotImplemented
+}
+
+//retryable tells whether a transport error may go away on another attempt: timeouts and connections refused,
+//reset or dropped by EAN are, bad certificates, unknown hosts and malformed urls are not
+func retryable(err error) bool {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+//retryAfter reads a Retry-After header given either in seconds or as an http date
+func retryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil && date.After(now()) {
+		return date.Sub(now())
+	}
+	return 0
+}
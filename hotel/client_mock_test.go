@@ -2,7 +2,6 @@ package hotel
 
 import (
 	"context"
-	"fmt"
 	"github.com/stretchr/testify/mock"
 	"net"
 	"net/http"
@@ -13,14 +12,37 @@ type mockClient struct {
 	mock.Mock
 }
 
-func (m *mockClient) getRegions() (Regions, error) {
-	fmt.Println("mockClient getRegions called")
-	args := m.Called()
-	fmt.Println("args extracted are :", args[0])
+//streamRegions hands each of the batches given to On to handle as a page of its own, then returns the error given
+//to On
+func (m *mockClient) streamRegions(ctx context.Context, batchSize int, handle func(Regions) error, pageFetched func()) error {
+	args := m.Called(batchSize)
+	for _, batch := range args[0].([]Regions) {
+		if err := handle(batch); err != nil {
+			return err
+		}
+		pageFetched()
+	}
+	if args[1] != nil {
+		return args[1].(error)
+	}
+	return nil
+}
+
+//streamProperties hands each of the batches given to On to handle as a page of its own, then returns the error
+//given to On
+func (m *mockClient) streamProperties(ctx context.Context, batchSize int, handle func(Properties) error,
+	pageFetched func()) error {
+	args := m.Called(batchSize)
+	for _, batch := range args[0].([]Properties) {
+		if err := handle(batch); err != nil {
+			return err
+		}
+		pageFetched()
+	}
 	if args[1] != nil {
-		return args[0].(Regions), args[1].(error)
+		return args[1].(error)
 	}
-	return args[0].(Regions), nil
+	return nil
 }
 
 func MockHTTPClient(handler http.Handler) (*http.Client, func()) {
@@ -36,3 +58,59 @@ func MockHTTPClient(handler http.Handler) (*http.Client, func()) {
 
 	return cli, s.Close
 }
+
+func (m *mockClient) availability(ctx context.Context, stay Stay, propertyIds []string) ([]PropertyAvailability,
+	error) {
+	args := m.Called(stay, propertyIds)
+	if args[1] != nil {
+		return nil, args[1].(error)
+	}
+	return args[0].([]PropertyAvailability), nil
+}
+
+type mockBookingClient struct {
+	mock.Mock
+}
+
+func (m *mockBookingClient) priceCheck(ctx context.Context, request BookingRequest) (priceCheck, error) {
+	args := m.Called(request)
+	if args[1] != nil {
+		return args[0].(priceCheck), args[1].(error)
+	}
+	return args[0].(priceCheck), nil
+}
+
+func (m *mockBookingClient) book(ctx context.Context, bookToken string, booking rapidBooking) (string, string,
+	error) {
+	args := m.Called(bookToken, booking)
+	if args[2] != nil {
+		return args[0].(string), args[1].(string), args[2].(error)
+	}
+	return args[0].(string), args[1].(string), nil
+}
+
+func (m *mockBookingClient) retrieveItinerary(ctx context.Context, itineraryId string, token string) (
+	rapidItinerary, error) {
+	args := m.Called(itineraryId, token)
+	if args[1] != nil {
+		return args[0].(rapidItinerary), args[1].(error)
+	}
+	return args[0].(rapidItinerary), nil
+}
+
+func (m *mockBookingClient) cancelItinerary(ctx context.Context, itineraryId string, token string) error {
+	args := m.Called(itineraryId, token)
+	if args[0] != nil {
+		return args[0].(error)
+	}
+	return nil
+}
+
+func (m *mockBookingClient) findItinerary(ctx context.Context, affiliateReferenceId string, email string) (
+	rapidItinerary, bool, error) {
+	args := m.Called(affiliateReferenceId, email)
+	if args[2] != nil {
+		return args[0].(rapidItinerary), args[1].(bool), args[2].(error)
+	}
+	return args[0].(rapidItinerary), args[1].(bool), nil
+}
@@ -2,16 +2,46 @@ package hotel
 
 import (
 	"compress/gzip"
-	"github.com/spf13/viper"
+	"context"
+	"crypto/x509"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"hotels-service-template/ean_simulator"
+	"hotels-service-template/logging"
+	"hotels-service-template/tracing"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
 	"path/filepath"
+	"syscall"
 	"testing"
 	"time"
 )
 
-func TestGetRegionsShouldReturnValidRegion(t *testing.T) {
+func testConfig(url string) ClientConfig {
+	return ClientConfig{URL: url, ApiKey: "abc", SecretKey: "secret", Language: DefaultLanguage,
+		Include: DefaultInclude}
+}
+
+//collectRegions streams all regions from the client into one map and records the size of every batch
+func collectRegions(client *client, batchSize int) (Regions, []int, error) {
+	regions := Regions{}
+	var batches []int
+	err := client.streamRegions(context.Background(), batchSize, func(batch Regions) error {
+		batches = append(batches, len(batch))
+		for id, region := range batch {
+			regions[id] = region
+		}
+		return nil
+	}, func() {})
+	return regions, batches, err
+}
+
+func TestStreamRegionsShouldReturnValidRegion(t *testing.T) {
 	b, err := ioutil.ReadFile(filepath.Join("testdata", "regions_stub.txt"))
 	if err != nil {
 		panic(err)
@@ -22,36 +52,286 @@ func TestGetRegionsShouldReturnValidRegion(t *testing.T) {
 	})
 	httpCli, stop := MockHTTPClient(h)
 	defer stop()
-	client := NewClient("http://test.com")
+	client := NewClient(testConfig("http://test.com"), logging.Discard())
 	client.Client = httpCli
 	client.Timeout = time.Duration(1) * time.Second
 
-	regions, err := client.getRegions()
+	regions, batches, err := collectRegions(client, DefaultSyncBatchSize)
 
 	assert.Nil(t, err)
 	assert.Equal(t, 250, len(regions))
+	assert.Equal(t, []int{250}, batches)
 	assert.Equal(t, "Albania", regions["2"].Name)
 }
 
-func TestGetRegionsShouldReturnDoError(t *testing.T) {
+func TestStreamRegionsShouldPassTheTraceOn(t *testing.T) {
+	var traceparent string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		_, _ = w.Write([]byte(`{}`))
+	})
+	httpCli, stop := MockHTTPClient(h)
+	defer stop()
+	client := NewClient(testConfig("http://test.com"), logging.Discard())
+	client.Client = httpCli
+	ctx, span := tracing.Start(context.Background(), tracing.Server, "POST update")
+
+	err := client.streamRegions(ctx, DefaultSyncBatchSize, func(Regions) error { return nil }, func() {})
+
+	assert.Nil(t, err)
+	assert.Regexp(t, "^00-"+span.Context().TraceId.String()+"-[0-9a-f]{16}-00$", traceparent)
+}
+
+func TestStreamRegionsShouldHandOverBoundedBatchesAcrossPages(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "regions_stub.txt"))
+	if err != nil {
+		panic(err)
+	}
+	pages := 0
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages++
+		w.Header().Add("Content-Type", "application/json")
+		if pages < 3 {
+			w.Header().Add("Link", `<http://test.com/regions?token=next>; rel="next"`)
+		}
+		_, _ = w.Write(b)
+	})
+	httpCli, stop := MockHTTPClient(h)
+	defer stop()
+	client := NewClient(testConfig("http://test.com"), logging.Discard())
+	client.Client = httpCli
+	client.Timeout = time.Duration(1) * time.Second
+	pagesFetched := 0
+
+	regions := Regions{}
+	var batches []int
+	err = client.streamRegions(context.Background(), 100, func(batch Regions) error {
+		batches = append(batches, len(batch))
+		for id, region := range batch {
+			regions[id] = region
+		}
+		return nil
+	}, func() {
+		pagesFetched++
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, pages)
+	assert.Equal(t, 3, pagesFetched)
+	assert.Equal(t, 250, len(regions))
+	assert.Equal(t, []int{100, 100, 100, 100, 100, 100, 100, 50}, batches)
+}
+
+func TestStreamRegionsShouldReturnHandlerError(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "regions_stub.txt"))
+	if err != nil {
+		panic(err)
+	}
 	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		_, _ = w.Write(b)
+	})
+	httpCli, stop := MockHTTPClient(h)
+	defer stop()
+	client := NewClient(testConfig("http://test.com"), logging.Discard())
+	client.Client = httpCli
+	client.Timeout = time.Duration(1) * time.Second
+	calls := 0
+
+	err = client.streamRegions(context.Background(), 100, func(batch Regions) error {
+		calls++
+		return errors.New("repository error")
+	}, func() {})
+
+	assert.EqualError(t, err, "repository error")
+	assert.Equal(t, 1, calls)
+}
+
+//retryingClient returns a client on httpCli that records the delays it would sleep instead of sleeping
+func retryingClient(httpCli *http.Client, delays *[]time.Duration) *client {
+	client := NewClient(testConfig("http://test.com"), logging.Discard())
+	client.Client = httpCli
+	client.Timeout = time.Duration(1) * time.Second
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		*delays = append(*delays, d)
+		return ctx.Err()
+	}
+	return client
+}
+
+func TestStreamRegionsShouldGiveUpAfterRetries(t *testing.T) {
+	calls := 0
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("test"))
 	})
 	httpCli, stop := MockHTTPClient(h)
 	defer stop()
-	client := client{url: "http://test.com", Client: httpCli}
-	client.Timeout = time.Duration(1) * time.Second
+	var delays []time.Duration
+	client := retryingClient(httpCli, &delays)
 
-	regions, err := client.getRegions()
+	regions, _, err := collectRegions(client, DefaultSyncBatchSize)
 
 	assert.Equal(t, Regions{}, regions)
-	assert.EqualError(t, err, "Do error : <nil> ", "expected do error")
+	assert.EqualError(t, err, "giving up after 5 attempts: ean returned 500 Internal Server Error: test")
+	apiErr, ok := errors.Cause(err).(*APIError)
+	assert.True(t, ok, "expected an APIError")
+	assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+	assert.Equal(t, 5, calls)
+	assert.Len(t, delays, 4)
+	for i, delay := range delays {
+		maxDelay := defaultRetryPolicy.baseDelay << uint(i)
+		assert.True(t, delay >= maxDelay/2 && delay <= maxDelay, "delay %d is %v", i, delay)
+	}
+}
+
+func TestStreamRegionsShouldRetryTemporaryErrorsOnTheSamePage(t *testing.T) {
+	calls := 0
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if calls == 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"2":{"id":"2","name":"Albania"}}`))
+	})
+	httpCli, stop := MockHTTPClient(h)
+	defer stop()
+	var delays []time.Duration
+	client := retryingClient(httpCli, &delays)
+
+	regions, _, err := collectRegions(client, DefaultSyncBatchSize)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Regions{"2": Region{Id: "2", Name: "Albania"}}, regions)
+	assert.Equal(t, 3, calls)
+	assert.Len(t, delays, 2)
+	assert.Equal(t, 7*time.Second, delays[0], "Retry-After should be honoured")
+}
+
+func TestStreamRegionsShouldWaitNoLongerThanTheLongestBackoff(t *testing.T) {
+	calls := 0
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "86400")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+	httpCli, stop := MockHTTPClient(h)
+	defer stop()
+	var delays []time.Duration
+	client := retryingClient(httpCli, &delays)
+
+	_, _, err := collectRegions(client, DefaultSyncBatchSize)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{defaultRetryPolicy.maxDelay}, delays)
+}
+
+//failingTransport fails every round trip with err
+type failingTransport struct {
+	err   error
+	calls int
+}
+
+func (t *failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	t.calls++
+	return nil, t.err
+}
+
+func TestStreamRegionsShouldRetryOnlyTransientTransportErrors(t *testing.T) {
+	dial := func(err error) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", err)}
+	}
+	tests := []struct {
+		name  string
+		err   error
+		calls int
+	}{
+		{"timeout", &net.DNSError{Err: "i/o timeout", IsTimeout: true}, 5},
+		{"connection refused", dial(syscall.ECONNREFUSED), 5},
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)},
+			5},
+		{"connection dropped", io.EOF, 5},
+		{"unknown host", &net.DNSError{Err: "no such host", Name: "test.com", IsNotFound: true}, 1},
+		{"untrusted certificate", x509.UnknownAuthorityError{}, 1},
+		{"other dial failure", dial(syscall.EACCES), 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transport := &failingTransport{err: test.err}
+			var delays []time.Duration
+			client := retryingClient(&http.Client{Transport: transport}, &delays)
+
+			_, _, err := collectRegions(client, DefaultSyncBatchSize)
+
+			assert.Error(t, err)
+			assert.Equal(t, test.calls, transport.calls)
+			assert.Len(t, delays, test.calls-1)
+		})
+	}
+}
+
+func TestStreamRegionsShouldNotRetryClientErrors(t *testing.T) {
+	calls := 0
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"type":"request_unauthenticated"}`))
+	})
+	httpCli, stop := MockHTTPClient(h)
+	defer stop()
+	var delays []time.Duration
+	client := retryingClient(httpCli, &delays)
+
+	_, _, err := collectRegions(client, DefaultSyncBatchSize)
+
+	assert.Equal(t, &APIError{StatusCode: http.StatusUnauthorized, Body: `{"type":"request_unauthenticated"}`}, err)
+	assert.Equal(t, 1, calls)
+	assert.Empty(t, delays)
+}
+
+func TestStreamRegionsShouldStopWhenContextIsCancelled(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	httpCli, stop := MockHTTPClient(h)
+	defer stop()
+	client := NewClient(testConfig("http://test.com"), logging.Discard())
+	client.Client = httpCli
+	ctx, cancel := context.WithCancel(context.Background())
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return sleep(ctx, d)
+	}
+
+	err := client.streamRegions(ctx, DefaultSyncBatchSize, func(Regions) error { return nil }, func() {})
+
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestRetryAfter(t *testing.T) {
+	now = func() time.Time {
+		return time.Unix(1559215747, 0)
+	}
+
+	assert.Equal(t, 120*time.Second, retryAfter("120"))
+	assert.Equal(t, 30*time.Second, retryAfter(time.Unix(1559215777, 0).UTC().Format(http.TimeFormat)))
+	assert.Equal(t, time.Duration(0), retryAfter(time.Unix(1559215700, 0).UTC().Format(http.TimeFormat)))
+	assert.Equal(t, time.Duration(0), retryAfter("soon"))
+	assert.Equal(t, time.Duration(0), retryAfter(""))
 }
 
 func TestAuthorization(t *testing.T) {
-	viper.Set("API_KEY", "abc")
-	viper.Set("SECRET_KEY", "secret")
 	now = func() time.Time {
 		return time.Unix(1559215747, 0)
 	}
@@ -63,10 +343,10 @@ func TestAuthorization(t *testing.T) {
 
 	httpCli, stop := MockHTTPClient(h)
 	defer stop()
-	client := client{url: "http://test.com", Client: httpCli}
+	client := client{ClientConfig: testConfig("http://test.com"), logger: logging.Discard(), Client: httpCli}
 	client.Timeout = time.Duration(1) * time.Second
 
-	client.getRegions()
+	_, _, _ = collectRegions(&client, DefaultSyncBatchSize)
 }
 
 func TestGetNextLink(t *testing.T) {
@@ -81,13 +361,13 @@ func TestGetNextLink(t *testing.T) {
 	})
 	httpCli, stop := MockHTTPClient(h)
 	defer stop()
-	client := NewClient("http://test.com")
+	client := NewClient(testConfig("http://test.com"), logging.Discard())
 	client.Client = httpCli
 	client.Timeout = time.Duration(1) * time.Second
 
-	regions, err := client.getRegions()
+	regions, _, err := collectRegions(client, DefaultSyncBatchSize)
 
-	assert.EqualError(t, err, "parse ://next_link: missing protocol scheme")
+	assert.EqualError(t, err, `parse "://next_link": missing protocol scheme`)
 	assert.Equal(t, Regions{}, regions)
 
 }
@@ -99,22 +379,23 @@ func TestCreateRequestShouldReturnAValidRequestWithHeaders(t *testing.T) {
 		assert.Equal(t, "gzip", r.Header.Get("Accept-Encoding"))
 		assert.Equal(t, "BigLife/0.1", r.Header.Get("User-Agent"))
 		assert.Equal(t, []string{"details", "property_ids", "property_ids_expanded"}, r.URL.Query()["include"])
+		assert.Equal(t, "en-US", r.URL.Query().Get("language"))
 
 	})
 	httpCli, stop := MockHTTPClient(h)
 	defer stop()
-	client := client{url: "http://test.com", Client: httpCli}
+	client := client{ClientConfig: testConfig("http://test.com"), logger: logging.Discard(), Client: httpCli}
 	client.Timeout = time.Duration(1) * time.Second
 
-	client.getRegions()
+	_, _, _ = collectRegions(&client, DefaultSyncBatchSize)
 }
 
 func TestCreateRequestShouldReturnError(t *testing.T) {
-	client := NewClient("://test.com")
+	client := NewClient(testConfig("://test.com"), logging.Discard())
 
-	regions, err := client.getRegions()
+	regions, _, err := collectRegions(client, DefaultSyncBatchSize)
 	assert.Equal(t, Regions{}, regions)
-	assert.EqualError(t, err, "parse ://test.com/regions: missing protocol scheme")
+	assert.EqualError(t, err, `parse "://test.com/regions": missing protocol scheme`)
 }
 
 func TestDecode(t *testing.T) {
@@ -134,11 +415,10 @@ func TestDecode(t *testing.T) {
 
 	httpCli, stop := MockHTTPClient(h)
 	defer stop()
-	client := client{url: "http://test.com", Client: httpCli}
+	client := client{ClientConfig: testConfig("http://test.com"), logger: logging.Discard(), Client: httpCli}
 	client.Timeout = time.Duration(1) * time.Second
 
-
-	regions, err := client.getRegions()
+	regions, _, err := collectRegions(&client, DefaultSyncBatchSize)
 	assert.Nil(t, err)
 	assert.Equal(t, "Nigeria", regions["136"].Name)
 }
@@ -155,10 +435,10 @@ func TestDecodeShouldReturnGzipError(t *testing.T) {
 
 	httpCli, stop := MockHTTPClient(h)
 	defer stop()
-	client := client{url: "http://test", Client: httpCli}
+	client := client{ClientConfig: testConfig("http://test"), logger: logging.Discard(), Client: httpCli}
 	client.Timeout = time.Duration(1) * time.Second
 
-	regions, err := client.getRegions()
+	regions, _, err := collectRegions(&client, DefaultSyncBatchSize)
 	assert.EqualError(t, err, "gzip: invalid header", "expected gzip error")
 	assert.Equal(t, Regions{}, regions)
 
@@ -179,11 +459,238 @@ func TestDecodeShouldReturnJsonError(t *testing.T) {
 
 	httpCli, stop := MockHTTPClient(h)
 	defer stop()
-	client := client{url: "http://test", Client: httpCli}
+	client := client{ClientConfig: testConfig("http://test"), logger: logging.Discard(), Client: httpCli}
+	client.Timeout = time.Duration(1) * time.Second
+
+	regions, _, err := collectRegions(&client, DefaultSyncBatchSize)
+	assert.EqualError(t, err, "json: cannot unmarshal string into Go value of type hotel.Region", "expected json error")
+	assert.Equal(t, Regions{}, regions)
+
+}
+
+func TestDecodeShouldRejectNonObjectPage(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"id": "1"}]`))
+	})
+
+	httpCli, stop := MockHTTPClient(h)
+	defer stop()
+	client := client{ClientConfig: testConfig("http://test"), logger: logging.Discard(), Client: httpCli}
 	client.Timeout = time.Duration(1) * time.Second
 
-	regions, err := client.getRegions()
-	assert.EqualError(t, err, "json: cannot unmarshal string into Go value of type hotel.Region", "expected gzip error")
+	regions, _, err := collectRegions(&client, DefaultSyncBatchSize)
+	assert.EqualError(t, err, "expected an object of regions, got [")
 	assert.Equal(t, Regions{}, regions)
+}
+
+func simulatorClient(t *testing.T, pageSize int) (*client, *ean_simulator.Simulator, func()) {
+	now = func() time.Time {
+		return time.Now()
+	}
+	regions, err := ean_simulator.LoadRegions(filepath.Join("testdata", "regions_stub.txt"))
+	assert.NoError(t, err)
+	properties, err := ean_simulator.LoadProperties(filepath.Join("testdata", "properties_stub.txt"))
+	assert.NoError(t, err)
+	simulator := ean_simulator.New(ean_simulator.Config{ApiKey: "abc", SecretKey: "secret", PageSize: pageSize,
+		Regions: regions, Properties: properties})
+	server := httptest.NewServer(simulator)
+	client := NewClient(testConfig(server.URL+"/2.2"), logging.Discard())
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		return ctx.Err()
+	}
+	return client, simulator, server.Close
+}
+
+func TestStreamRegionsAgainstSimulator(t *testing.T) {
+	client, simulator, stop := simulatorClient(t, 100)
+	defer stop()
+	simulator.Inject(ean_simulator.Fault{Status: http.StatusTooManyRequests, RetryAfter: 1},
+		ean_simulator.Fault{Status: http.StatusInternalServerError})
+
+	regions, batches, err := collectRegions(client, 60)
+
+	assert.NoError(t, err)
+	assert.Len(t, regions, 250)
+	assert.Equal(t, "Nigeria", regions["136"].Name)
+	assert.Equal(t, []int{60, 60, 60, 60, 10}, batches)
+	assert.Equal(t, 5, simulator.Requests(), "3 pages and 2 retries")
+}
+
+func TestStreamRegionsAgainstSimulatorShouldFailOnTruncatedPage(t *testing.T) {
+	client, simulator, stop := simulatorClient(t, 100)
+	defer stop()
+	simulator.Inject(ean_simulator.Fault{}, ean_simulator.Fault{Truncate: true})
+
+	_, _, err := collectRegions(client, DefaultSyncBatchSize)
+
+	assert.EqualError(t, err, "unexpected EOF")
+}
+
+func TestStreamPropertiesShouldAskForExpediaContent(t *testing.T) {
+	var query url.Values
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/properties/content", r.URL.Path)
+		query = r.URL.Query()
+		_, _ = w.Write([]byte(`{}`))
+	})
+	httpCli, stop := MockHTTPClient(h)
+	defer stop()
+	client := NewClient(testConfig("http://test.com"), logging.Discard())
+	client.Client = httpCli
+
+	err := client.streamProperties(context.Background(), DefaultSyncBatchSize, func(Properties) error { return nil },
+		func() {})
+
+	assert.Nil(t, err)
+	assert.Equal(t, url.Values{"language": {"en-US"}, "supply_source": {"expedia"}}, query)
+}
+
+func TestStreamPropertiesAgainstSimulator(t *testing.T) {
+	client, simulator, stop := simulatorClient(t, 4)
+	defer stop()
+	simulator.Inject(ean_simulator.Fault{Status: http.StatusInternalServerError})
+	properties := Properties{}
+	var batches []int
+	pages := 0
+
+	err := client.streamProperties(context.Background(), 3, func(batch Properties) error {
+		batches = append(batches, len(batch))
+		for id, property := range batch {
+			properties[id] = property
+		}
+		return nil
+	}, func() {
+		pages++
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, properties, 6)
+	assert.Equal(t, []int{3, 3}, batches)
+	assert.Equal(t, 2, pages)
+	hotel := properties["12345"]
+	assert.Equal(t, "Tirana International Hotel", hotel.Name)
+	assert.Equal(t, Category{Id: "1", Name: "Hotel"}, hotel.Category)
+	assert.Equal(t, 4.0, hotel.StarRating)
+	assert.Equal(t, &Coordinates{Latitude: 41.3275, Longitude: 19.8189}, hotel.Coordinates)
+	assert.Equal(t, Address{Line1: "Sheshi Skenderbej 8", City: "Tirana", PostalCode: "1001", CountryCode: "AL"},
+		hotel.Address)
+	assert.Equal(t, []Amenity{{Id: "3", Name: "Bar/lounge"}, {Id: "9", Name: "Fitness facilities"},
+		{Id: "2403", Name: "Free WiFi"}}, hotel.Amenities)
+	assert.Len(t, hotel.Images, 2)
+	assert.True(t, hotel.Images[0].HeroImage)
+	assert.Equal(t, "https://i.travelapi.com/hotels/12345/1_b.jpg", hotel.Images[0].Links["350px"])
+	assert.Equal(t, "Near Tirana centre", hotel.Descriptions["headline"])
+	assert.Zero(t, properties["45678"].StarRating, "a property without a star rating")
+}
+
+func testStay() Stay {
+	return Stay{CheckIn: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+		CheckOut: time.Date(2026, 11, 3, 0, 0, 0, 0, time.UTC), Occupancy: []string{"2", "1-9"}, Currency: "EUR",
+		CountryOfResidence: "FR"}
+}
+
+func TestAvailabilityShouldShopTheStayAndSumOccupancies(t *testing.T) {
+	var query url.Values
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/properties/availability", r.URL.Path)
+		query = r.URL.Query()
+		w.Header().Set("Content-Encoding", "gzip")
+		writer := gzip.NewWriter(w)
+		defer writer.Close()
+		_, _ = writer.Write([]byte(`[{"property_id": "12345", "rooms": [{"id": "a", "room_name": "Suite",
+			"rates": [{"id": "r1", "status": "available", "available_rooms": 2, "refundable": false,
+				"occupancy_pricing": {
+					"2": {"totals": {"inclusive": {"request_currency": {"value": "200.50", "currency": "EUR"}}}},
+					"1-9": {"totals": {"inclusive": {"request_currency": {"value": "99.5", "currency": "EUR"}}}}},
+				"bed_groups": {"2": {"description": "2 Twin Beds", "links": {"price_check":
+					{"href": "/2.4/properties/12345/rooms/a/rates/r1?token=t2"}}},
+					"1": {"id": "1", "description": "1 King Bed", "links": {"price_check":
+					{"href": "/2.4/properties/12345/rooms/a/rates/r1?token=t1"}}}}},
+				{"id": "r2", "status": "sold_out"}]},
+			{"id": "b", "room_name": "Double Room", "rates": [{"id": "r3", "available_rooms": 1, "refundable": true,
+				"occupancy_pricing": {
+					"2": {"totals": {"inclusive": {"request_currency": {"value": "150.00", "currency": "EUR"}}}},
+					"1-9": {"totals": {"inclusive": {"request_currency": {"value": "90.00", "currency": "EUR"}}}}}}]}]},
+			{"property_id": "23456", "rooms": []}]`))
+	})
+	httpCli, stop := MockHTTPClient(h)
+	defer stop()
+	client := NewClient(testConfig("http://test.com"), logging.Discard())
+	client.Client = httpCli
+
+	availabilities, err := client.availability(context.Background(), testStay(), []string{"12345", "23456"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, url.Values{"checkin": {"2026-11-01"}, "checkout": {"2026-11-03"}, "occupancy": {"2", "1-9"},
+		"currency": {"EUR"}, "country_code": {"FR"}, "language": {"en-US"}, "property_id": {"12345", "23456"},
+		"sales_channel": {"website"}, "sales_environment": {"hotel_only"}, "rate_plan_count": {"1"}}, query)
+	assert.Equal(t, []PropertyAvailability{{PropertyId: "12345", Rates: []Rate{
+		{RoomId: "b", RoomName: "Double Room", RateId: "r3", Refundable: true, AvailableRooms: 1,
+			Total: Amount{Value: "240.00", Currency: "EUR"}, BedGroups: []BedGroup{}},
+		{RoomId: "a", RoomName: "Suite", RateId: "r1", AvailableRooms: 2,
+			Total: Amount{Value: "300.00", Currency: "EUR"}, BedGroups: []BedGroup{
+				{Id: "1", Description: "1 King Bed", Token: "t1"}, {Id: "2", Description: "2 Twin Beds", Token: "t2"}}},
+	}}}, availabilities, "sold out rates and properties without rates are left out")
+}
+
+func TestAvailabilityShouldPriceEveryRoomOfARepeatedOccupancy(t *testing.T) {
+	h := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, _ = writer.Write([]byte(`[{"property_id": "12345", "rooms": [{"id": "a", "room_name": "Suite",
+			"rates": [{"id": "r1", "available_rooms": 3, "occupancy_pricing": {
+				"2": {"totals": {"inclusive": {"request_currency": {"value": "200.50", "currency": "EUR"}}}},
+				"1-9": {"totals": {"inclusive": {"request_currency": {"value": "99.5", "currency": "EUR"}}}}}}]}]}]`))
+	})
+	httpCli, stop := MockHTTPClient(h)
+	defer stop()
+	client := NewClient(testConfig("http://test.com"), logging.Discard())
+	client.Client = httpCli
+	stay := testStay()
+	stay.Occupancy = []string{"2", "1-9", "2"}
+
+	availabilities, err := client.availability(context.Background(), stay, []string{"12345"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, Amount{Value: "500.50", Currency: "EUR"}, availabilities[0].Rates[0].Total)
+}
+
+func TestAvailabilityShouldNotRetryARejectedStay(t *testing.T) {
+	requests := 0
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"type":"invalid_input"}`))
+	})
+	httpCli, stop := MockHTTPClient(h)
+	defer stop()
+	client := NewClient(testConfig("http://test.com"), logging.Discard())
+	client.Client = httpCli
+
+	_, err := client.availability(context.Background(), testStay(), []string{"12345"})
+
+	assert.EqualError(t, err, `ean returned 400 Bad Request: {"type":"invalid_input"}`)
+	assert.Equal(t, 1, requests)
+}
+
+func TestAvailabilityAgainstSimulator(t *testing.T) {
+	client, simulator, stop := simulatorClient(t, 100)
+	defer stop()
+	simulator.Inject(ean_simulator.Fault{Status: http.StatusServiceUnavailable})
+
+	availabilities, err := client.availability(context.Background(), testStay(), []string{"12345", "99999"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []PropertyAvailability{{PropertyId: "12345", Rates: []Rate{{RoomId: "1234501",
+		RoomName: "Standard Room", RateId: "123450101", Refundable: true, AvailableRooms: 5,
+		Total: Amount{Value: "740.00", Currency: "EUR"}, BedGroups: []BedGroup{{Id: "37321",
+			Description: "1 King Bed", Token: ean_simulator.PriceCheckToken("12345", "1234501", "123450101")}}}}}},
+		availabilities)
+	assert.Equal(t, 2, simulator.Requests(), "one retry")
+
+	stay := testStay()
+	stay.Occupancy = []string{"2", "2", "2"}
+	repeated, err := client.availability(context.Background(), stay, []string{"12345"})
 
+	assert.NoError(t, err)
+	assert.Equal(t, Amount{Value: "1110.00", Currency: "EUR"}, repeated[0].Rates[0].Total)
 }
@@ -0,0 +1,121 @@
+package hotel
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/pkg/errors"
+	"net"
+)
+
+//ErrorKind classifies domain errors so callers such as the HTTP handlers can react without knowing their origin
+type ErrorKind string
+
+const (
+	KindNotFound            ErrorKind = "not_found"
+	KindInvalidInput        ErrorKind = "invalid_input"
+	KindUpstreamUnavailable ErrorKind = "upstream_unavailable"
+	KindConflict            ErrorKind = "conflict"
+	KindUnauthenticated     ErrorKind = "unauthenticated"
+	KindForbidden           ErrorKind = "forbidden"
+	KindTimeout             ErrorKind = "timeout"
+	KindCanceled            ErrorKind = "canceled"
+	KindInternal            ErrorKind = "internal"
+)
+
+//Error is a domain error. Code is stable and machine readable, such as region_not_found, while Message is meant
+//for people and may change
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	//Params lists what is wrong with each invalid parameter of an invalid input error
+	Params []InvalidParam
+	Err    error
+}
+
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+//Cause lets errors.Cause reach the underlying error
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+func NotFound(code string, format string, args ...interface{}) *Error {
+	return &Error{Kind: KindNotFound, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func InvalidInput(code string, format string, args ...interface{}) *Error {
+	return &Error{Kind: KindInvalidInput, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+//InvalidParams reports every invalid parameter of a request at once
+func InvalidParams(params []InvalidParam) *Error {
+	return &Error{Kind: KindInvalidInput, Code: "invalid_parameter", Message: "the request has invalid parameters",
+		Params: params}
+}
+
+func Conflict(code string, format string, args ...interface{}) *Error {
+	return &Error{Kind: KindConflict, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+//Unauthenticated reports a request without valid credentials
+func Unauthenticated(code string, format string, args ...interface{}) *Error {
+	return &Error{Kind: KindUnauthenticated, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+//Forbidden reports valid credentials that do not allow what was asked
+func Forbidden(code string, format string, args ...interface{}) *Error {
+	return &Error{Kind: KindForbidden, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+//UpstreamUnavailable wraps err, a failure to reach EAN
+func UpstreamUnavailable(err error) *Error {
+	return &Error{Kind: KindUpstreamUnavailable, Code: "upstream_unavailable", Message: "EAN is unavailable",
+		Err: err}
+}
+
+//AsError finds the domain error in the chain of err. EAN failures that are not domain errors yet are reported as
+//upstream unavailable, requests out of time as timed out, requests their client gave up on as canceled and anything
+//else as internal
+func AsError(err error) *Error {
+	for cause := err; cause != nil; {
+		//checked before the type switch, as context.DeadlineExceeded is a net.Error too
+		switch cause {
+		case context.DeadlineExceeded:
+			return &Error{Kind: KindTimeout, Code: "timeout", Message: "the request took too long", Err: err}
+		case context.Canceled:
+			return &Error{Kind: KindCanceled, Code: "canceled", Message: "the request was canceled", Err: err}
+		}
+		switch e := cause.(type) {
+		case *Error:
+			return e
+		case *APIError, net.Error:
+			return UpstreamUnavailable(err)
+		}
+		causer, ok := cause.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		cause = causer.Cause()
+	}
+	return &Error{Kind: KindInternal, Code: "internal_error", Message: "internal error", Err: err}
+}
+
+//notFound turns sql.ErrNoRows into a not found error with code and message, leaving other errors untouched
+func notFound(err error, code string, format string, args ...interface{}) error {
+	if errors.Cause(err) == sql.ErrNoRows {
+		return NotFound(code, format, args...)
+	}
+	return err
+}
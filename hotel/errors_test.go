@@ -0,0 +1,44 @@
+package hotel
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAsError(t *testing.T) {
+	apiErr := &APIError{StatusCode: 503, Body: "down"}
+	notFoundErr := NotFound("region_not_found", "region 1 does not exist")
+	tests := []struct {
+		testDescription string
+		err             error
+		expectedKind    ErrorKind
+		expectedCode    string
+	}{
+		{"domain error", notFoundErr, KindNotFound, "region_not_found"},
+		{"wrapped domain error", errors.Wrap(notFoundErr, "lookup"), KindNotFound, "region_not_found"},
+		{"ean error", errors.Wrap(apiErr, "giving up after 5 attempts"), KindUpstreamUnavailable,
+			"upstream_unavailable"},
+		{"deadline exceeded", errors.Wrap(context.DeadlineExceeded, "availability"), KindTimeout, "timeout"},
+		{"canceled", context.Canceled, KindCanceled, "canceled"},
+		{"other error", errors.New("db error"), KindInternal, "internal_error"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.testDescription, func(t *testing.T) {
+			obtained := AsError(tc.err)
+
+			assert.Equal(t, tc.expectedKind, obtained.Kind)
+			assert.Equal(t, tc.expectedCode, obtained.Code)
+		})
+	}
+}
+
+func TestNotFoundShouldOnlyReplaceNoRows(t *testing.T) {
+	assert.Nil(t, notFound(nil, "region_not_found", "region %s does not exist", "1"))
+	assert.EqualError(t, notFound(errors.New("db error"), "region_not_found", "region %s does not exist", "1"),
+		"db error")
+	assert.Equal(t, NotFound("region_not_found", "region 1 does not exist"),
+		notFound(sql.ErrNoRows, "region_not_found", "region %s does not exist", "1"))
+}
@@ -0,0 +1,139 @@
+package hotel
+
+import (
+	"context"
+	"database/sql"
+	"hotels-service-template/tracing"
+	"log/slog"
+	"time"
+)
+
+type itineraryRepositoryInt interface {
+	create(ctx context.Context, keyId int64, idempotencyKey string, request BookingRequest) (Itinerary, bool, error)
+	get(ctx context.Context, keyId int64, id int64) (Itinerary, error)
+	transition(ctx context.Context, itinerary Itinerary, to ItineraryState, change itineraryChange) (Itinerary,
+		error)
+}
+
+//itineraryChange is what a transition records besides the new state. Empty fields are left as they are
+type itineraryChange struct {
+	eanItineraryId string
+	eanToken       string
+	failure        string
+}
+
+//itineraryRepository stores itineraries and every transition between their states. Customer details are not
+//stored, only what is booked
+type itineraryRepository struct {
+	db     tracing.DB
+	logger *slog.Logger
+}
+
+func NewItineraryRepository(db *sql.DB, logger *slog.Logger) itineraryRepository {
+	return itineraryRepository{
+		db:     tracing.WrapDB(db),
+		logger: logger,
+	}
+}
+
+//itineraryColumns are the itineraries columns scanItinerary reads
+const itineraryColumns = `id, state, property_id, room_id, rate_id, room_count, total_value, total_currency,
+	coalesce(ean_itinerary_id, ''), coalesce(ean_token, ''), coalesce(failure, ''), request_hash, created_at,
+	updated_at`
+
+func scanItinerary(row *sql.Row) (Itinerary, error) {
+	var itinerary Itinerary
+	err := row.Scan(&itinerary.Id, &itinerary.State, &itinerary.PropertyId, &itinerary.RoomId, &itinerary.RateId,
+		&itinerary.RoomCount, &itinerary.Total.Value, &itinerary.Total.Currency, &itinerary.EANItineraryId,
+		&itinerary.eanToken, &itinerary.Failure, &itinerary.requestHash, &itinerary.CreatedAt, &itinerary.UpdatedAt)
+	if err != nil {
+		return Itinerary{}, err
+	}
+	return itinerary, nil
+}
+
+//create stores a held itinerary for request, unless the api key already used idempotencyKey. It then returns the
+//itinerary stored with that key and false
+func (repository itineraryRepository) create(ctx context.Context, keyId int64, idempotencyKey string,
+	request BookingRequest) (Itinerary, bool, error) {
+	defer observeQuery("createItinerary", time.Now())
+	tx, err := repository.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Itinerary{}, false, err
+	}
+	defer tx.Rollback()
+
+	query := `insert into itineraries (api_key_id, idempotency_key, request_hash, state, property_id, room_id, rate_id,
+		room_count, total_value, total_currency) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		on conflict (api_key_id, idempotency_key) do nothing
+		returning ` + itineraryColumns
+	itinerary, err := scanItinerary(tx.QueryRowContext(ctx, query, keyId, idempotencyKey, request.hash(),
+		ItineraryHeld, request.PropertyId, request.RoomId, request.RateId, len(request.Rooms), request.Total.Value,
+		request.Total.Currency))
+	if err == sql.ErrNoRows {
+		query = `select ` + itineraryColumns + ` from itineraries where api_key_id = $1 and idempotency_key = $2`
+		itinerary, err = scanItinerary(tx.QueryRowContext(ctx, query, keyId, idempotencyKey))
+		return itinerary, false, err
+	}
+	if err != nil {
+		return Itinerary{}, false, err
+	}
+	_, err = tx.ExecContext(ctx, `insert into itinerary_transitions (itinerary_id, to_state) values ($1, $2)`,
+		itinerary.Id, ItineraryHeld)
+	if err != nil {
+		return Itinerary{}, false, err
+	}
+	if err = tx.CommitContext(ctx); err != nil {
+		return Itinerary{}, false, err
+	}
+	return itinerary, true, nil
+}
+
+//get returns an itinerary booked with the api key keyId, sql.ErrNoRows for those of other keys
+func (repository itineraryRepository) get(ctx context.Context, keyId int64, id int64) (Itinerary, error) {
+	defer observeQuery("getItinerary", time.Now())
+	query := `select ` + itineraryColumns + ` from itineraries where id = $1 and api_key_id = $2`
+	return scanItinerary(repository.db.QueryRowContext(ctx, query, id, keyId))
+}
+
+//transition moves an itinerary from its state to to and records the transition. It fails with a conflict when the
+//state machine does not allow it, or when the itinerary left that state meanwhile
+func (repository itineraryRepository) transition(ctx context.Context, itinerary Itinerary, to ItineraryState,
+	change itineraryChange) (Itinerary, error) {
+	defer observeQuery("transitionItinerary", time.Now())
+	if !itinerary.State.canBecome(to) {
+		return Itinerary{}, Conflict("invalid_transition", "itinerary %d is %s and cannot become %s", itinerary.Id,
+			itinerary.State, to)
+	}
+	tx, err := repository.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Itinerary{}, err
+	}
+	defer tx.Rollback()
+
+	query := `update itineraries set state = $3, ean_itinerary_id = coalesce($4, ean_itinerary_id),
+		ean_token = coalesce($5, ean_token), failure = coalesce($6, failure), updated_at = now()
+		where id = $1 and state = $2
+		returning ` + itineraryColumns
+	updated, err := scanItinerary(tx.QueryRowContext(ctx, query, itinerary.Id, itinerary.State, to,
+		nullIfEmpty(change.eanItineraryId), nullIfEmpty(change.eanToken), nullIfEmpty(change.failure)))
+	if err == sql.ErrNoRows {
+		return Itinerary{}, Conflict("itinerary_changed", "itinerary %d is no longer %s", itinerary.Id,
+			itinerary.State)
+	}
+	if err != nil {
+		return Itinerary{}, err
+	}
+	_, err = tx.ExecContext(ctx, `insert into itinerary_transitions (itinerary_id, from_state, to_state, reason)
+		values ($1, $2, $3, $4)`, itinerary.Id, itinerary.State, to, nullIfEmpty(change.failure))
+	if err != nil {
+		return Itinerary{}, err
+	}
+	if err = tx.CommitContext(ctx); err != nil {
+		return Itinerary{}, err
+	}
+	itineraryStates.Inc(string(to))
+	repository.logger.InfoContext(ctx, "itinerary transitioned", "itinerary", itinerary.Id, "from", itinerary.State,
+		"to", to)
+	return updated, nil
+}
@@ -0,0 +1,36 @@
+package hotel
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockItineraryRepository struct {
+	mock.Mock
+}
+
+func (m *MockItineraryRepository) create(ctx context.Context, keyId int64, idempotencyKey string,
+	request BookingRequest) (Itinerary, bool, error) {
+	args := m.Called(keyId, idempotencyKey, request)
+	if args[2] != nil {
+		return args[0].(Itinerary), args[1].(bool), args[2].(error)
+	}
+	return args[0].(Itinerary), args[1].(bool), nil
+}
+
+func (m *MockItineraryRepository) get(ctx context.Context, keyId int64, id int64) (Itinerary, error) {
+	args := m.Called(keyId, id)
+	if args[1] != nil {
+		return args[0].(Itinerary), args[1].(error)
+	}
+	return args[0].(Itinerary), nil
+}
+
+func (m *MockItineraryRepository) transition(ctx context.Context, itinerary Itinerary, to ItineraryState,
+	change itineraryChange) (Itinerary, error) {
+	args := m.Called(itinerary, to, change)
+	if args[1] != nil {
+		return args[0].(Itinerary), args[1].(error)
+	}
+	return args[0].(Itinerary), nil
+}
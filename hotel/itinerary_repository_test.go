@@ -0,0 +1,124 @@
+package hotel
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"hotels-service-template/logging"
+	"testing"
+	"time"
+)
+
+var itineraryRowColumns = []string{"id", "state", "property_id", "room_id", "rate_id", "room_count", "total_value",
+	"total_currency", "ean_itinerary_id", "ean_token", "failure", "request_hash", "created_at", "updated_at"}
+
+func itineraryRow(mock sqlmock.Sqlmock, id int64, state ItineraryState, eanItineraryId string) *sqlmock.Rows {
+	at := time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC)
+	return mock.NewRows(itineraryRowColumns).AddRow(id, state, "12345", "1234501", "123450101", 2, "740.00", "EUR",
+		eanItineraryId, "", "", testBooking().hash(), at, at)
+}
+
+func TestCreateItineraryShouldHoldItAndRecordTheTransition(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewItineraryRepository(db, logging.Discard())
+	request := testBooking()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`insert into itineraries .* on conflict \(api_key_id, idempotency_key\) do nothing`).
+		WithArgs(int64(7), "key-1", request.hash(), ItineraryHeld, "12345", "1234501", "123450101", 2, "740.00",
+			"EUR").
+		WillReturnRows(itineraryRow(mock, 1, ItineraryHeld, ""))
+	mock.ExpectExec("insert into itinerary_transitions").WithArgs(int64(1), ItineraryHeld).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	itinerary, created, err := repo.create(context.Background(), 7, "key-1", request)
+
+	assert.NoError(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.True(t, created)
+	assert.Equal(t, ItineraryHeld, itinerary.State)
+	assert.Equal(t, Amount{Value: "740.00", Currency: "EUR"}, itinerary.Total)
+	assert.Equal(t, request.hash(), itinerary.requestHash)
+}
+
+func TestCreateItineraryShouldReturnTheOneStoredWithTheKey(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewItineraryRepository(db, logging.Discard())
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("insert into itineraries").WillReturnRows(mock.NewRows(itineraryRowColumns))
+	mock.ExpectQuery(`select .* from itineraries where api_key_id = \$1 and idempotency_key = \$2`).
+		WithArgs(int64(7), "key-1").
+		WillReturnRows(itineraryRow(mock, 1, ItineraryConfirmed, "8955599932111"))
+	mock.ExpectRollback()
+
+	itinerary, created, err := repo.create(context.Background(), 7, "key-1", testBooking())
+
+	assert.NoError(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.False(t, created)
+	assert.Equal(t, ItineraryConfirmed, itinerary.State)
+	assert.Equal(t, "8955599932111", itinerary.EANItineraryId)
+}
+
+func TestGetItineraryShouldBeScopedToTheKey(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewItineraryRepository(db, logging.Discard())
+
+	mock.ExpectQuery(`select .* from itineraries where id = \$1 and api_key_id = \$2`).WithArgs(int64(1), int64(8)).
+		WillReturnRows(mock.NewRows(itineraryRowColumns))
+
+	_, err := repo.get(context.Background(), 8, 1)
+
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestTransitionItineraryShouldMoveItFromItsState(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewItineraryRepository(db, logging.Discard())
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`update itineraries set state = \$3, .* where id = \$1 and state = \$2`).
+		WithArgs(int64(1), ItineraryHeld, ItineraryConfirmed, "8955599932111", "retrieve-me", nil).
+		WillReturnRows(itineraryRow(mock, 1, ItineraryConfirmed, "8955599932111"))
+	mock.ExpectExec("insert into itinerary_transitions").
+		WithArgs(int64(1), ItineraryHeld, ItineraryConfirmed, nil).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	itinerary, err := repo.transition(context.Background(), Itinerary{Id: 1, State: ItineraryHeld},
+		ItineraryConfirmed, itineraryChange{eanItineraryId: "8955599932111", eanToken: "retrieve-me"})
+
+	assert.NoError(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, ItineraryConfirmed, itinerary.State)
+}
+
+func TestTransitionItineraryShouldConflictWhenItChangedMeanwhile(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewItineraryRepository(db, logging.Discard())
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("update itineraries").WillReturnRows(mock.NewRows(itineraryRowColumns))
+	mock.ExpectRollback()
+
+	_, err := repo.transition(context.Background(), Itinerary{Id: 1, State: ItineraryConfirmed}, ItineraryCancelled,
+		itineraryChange{})
+
+	assert.Equal(t, Conflict("itinerary_changed", "itinerary 1 is no longer confirmed"), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestTransitionItineraryShouldFollowTheStateMachine(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewItineraryRepository(db, logging.Discard())
+
+	_, err := repo.transition(context.Background(), Itinerary{Id: 1, State: ItineraryFailed}, ItineraryConfirmed,
+		itineraryChange{})
+
+	assert.Equal(t, Conflict("invalid_transition", "itinerary 1 is failed and cannot become confirmed"), err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,30 @@
+package hotel
+
+import (
+	"hotels-service-template/metrics"
+	"time"
+)
+
+var (
+	queryDuration = metrics.Default.Histogram("db_query_duration_seconds",
+		"Time spent on repository queries, by repository method.", metrics.DefaultBuckets, "query")
+	eanRequests = metrics.Default.Counter("ean_requests_total",
+		"Requests sent to EAN Rapid, by response status, or error when no response came back.", "status")
+	eanRetries = metrics.Default.Counter("ean_retries_total",
+		"EAN Rapid requests retried after a temporary failure.")
+	eanPages = metrics.Default.Counter("ean_pages_fetched_total",
+		"Pages fetched from EAN Rapid, by what they list: regions or properties.", "listing")
+	syncs = metrics.Default.Counter("syncs_total",
+		"Finished syncs, by what they sync and final state.", "kind", "state")
+	lastSyncSuccess = metrics.Default.Gauge("sync_last_success_timestamp_seconds",
+		"Unix time the last successful sync of this process finished, by what it synced.", "kind")
+	storedRegions = metrics.Default.Gauge("regions_stored",
+		"Live regions stored, as of the last autocomplete index refresh.")
+	itineraryStates = metrics.Default.Counter("itinerary_transitions_total",
+		"Itineraries moved to a state, by the state they moved to.", "state")
+)
+
+//observeQuery records the duration of the repository query started at started
+func observeQuery(query string, started time.Time) {
+	queryDuration.Observe(time.Since(started).Seconds(), query)
+}
@@ -0,0 +1,182 @@
+package hotel
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+)
+
+//Property is the static content of a hotel, flattened from what EAN Rapid serves on properties/content
+type Property struct {
+	Id           string            `json:"id"`
+	Name         string            `json:"name"`
+	Category     Category          `json:"category"`
+	StarRating   float64           `json:"star_rating,omitempty"`
+	Address      Address           `json:"address"`
+	Coordinates  *Coordinates      `json:"coordinates,omitempty"`
+	Amenities    []Amenity         `json:"amenities"`
+	Images       []Image           `json:"images"`
+	Descriptions map[string]string `json:"descriptions"`
+}
+type Properties map[string]Property
+
+//PropertySummary is what a list of properties shows of each
+type PropertySummary struct {
+	Id          string       `json:"id"`
+	Name        string       `json:"name"`
+	Category    Category     `json:"category"`
+	StarRating  float64      `json:"star_rating,omitempty"`
+	Address     Address      `json:"address"`
+	Coordinates *Coordinates `json:"coordinates,omitempty"`
+}
+
+type PropertySort string
+
+const (
+	SortByName   PropertySort = "name"
+	SortByRating PropertySort = "rating"
+)
+
+//PropertyFilter narrows down and orders the properties of a region. Zero values do not filter, a property must have
+//all of Amenities and one of Categories
+type PropertyFilter struct {
+	MinStarRating float64
+	MaxStarRating float64
+	Categories    []string
+	Amenities     []string
+	Sort          PropertySort
+	Limit         int
+	Offset        int
+}
+
+//PropertyPage is one page of the properties matching a filter. Total counts all of them
+type PropertyPage struct {
+	Properties []PropertySummary `json:"properties"`
+	Total      int               `json:"total"`
+	Limit      int               `json:"limit"`
+	Offset     int               `json:"offset"`
+}
+
+//Category is the kind of property, such as Hotel, Hostel or Apartment
+type Category struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type Address struct {
+	Line1             string `json:"line_1"`
+	Line2             string `json:"line_2,omitempty"`
+	City              string `json:"city"`
+	StateProvinceCode string `json:"state_province_code,omitempty"`
+	StateProvinceName string `json:"state_province_name,omitempty"`
+	PostalCode        string `json:"postal_code,omitempty"`
+	CountryCode       string `json:"country_code"`
+}
+
+type Coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type Amenity struct {
+	Id    string `json:"id"`
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
+
+//Image is a photo of a property. Links holds its url by size, such as 350px
+type Image struct {
+	Caption   string            `json:"caption"`
+	HeroImage bool              `json:"hero_image"`
+	Category  int               `json:"category"`
+	Links     map[string]string `json:"links"`
+}
+
+func (property Property) summary() PropertySummary {
+	return PropertySummary{
+		Id:          property.Id,
+		Name:        property.Name,
+		Category:    property.Category,
+		StarRating:  property.StarRating,
+		Address:     property.Address,
+		Coordinates: property.Coordinates,
+	}
+}
+
+//rapidProperty is a property as EAN Rapid serves it
+type rapidProperty struct {
+	PropertyId string   `json:"property_id"`
+	Name       string   `json:"name"`
+	Address    Address  `json:"address"`
+	Category   Category `json:"category"`
+	Ratings    struct {
+		Property struct {
+			Rating string `json:"rating"`
+		} `json:"property"`
+	} `json:"ratings"`
+	Location struct {
+		Coordinates *Coordinates `json:"coordinates"`
+	} `json:"location"`
+	Amenities map[string]struct {
+		//Id is a number on Rapid, json.Number also accepts it quoted
+		Id    json.Number `json:"id"`
+		Name  string      `json:"name"`
+		Value string      `json:"value"`
+	} `json:"amenities"`
+	Images []struct {
+		Caption   string `json:"caption"`
+		HeroImage bool   `json:"hero_image"`
+		Category  int    `json:"category"`
+		Links     map[string]struct {
+			Href string `json:"href"`
+		} `json:"links"`
+	} `json:"images"`
+	Descriptions map[string]string `json:"descriptions"`
+}
+
+//property flattens a Rapid property. Amenities are sorted by name, and a star rating Rapid does not give as a
+//number is left out
+func (rapid rapidProperty) property(id string) Property {
+	property := Property{
+		Id:           rapid.PropertyId,
+		Name:         rapid.Name,
+		Category:     rapid.Category,
+		Address:      rapid.Address,
+		Coordinates:  rapid.Location.Coordinates,
+		Amenities:    make([]Amenity, 0, len(rapid.Amenities)),
+		Images:       make([]Image, 0, len(rapid.Images)),
+		Descriptions: rapid.Descriptions,
+	}
+	if property.Id == "" {
+		property.Id = id
+	}
+	if rating, err := strconv.ParseFloat(rapid.Ratings.Property.Rating, 64); err == nil {
+		property.StarRating = rating
+	}
+	for key, amenity := range rapid.Amenities {
+		amenityId := amenity.Id.String()
+		if amenityId == "" {
+			amenityId = key
+		}
+		property.Amenities = append(property.Amenities, Amenity{Id: amenityId, Name: amenity.Name,
+			Value: amenity.Value})
+	}
+	sort.Slice(property.Amenities, func(i, j int) bool {
+		if property.Amenities[i].Name != property.Amenities[j].Name {
+			return property.Amenities[i].Name < property.Amenities[j].Name
+		}
+		return property.Amenities[i].Id < property.Amenities[j].Id
+	})
+	for _, image := range rapid.Images {
+		links := make(map[string]string, len(image.Links))
+		for size, link := range image.Links {
+			links[size] = link.Href
+		}
+		property.Images = append(property.Images, Image{Caption: image.Caption, HeroImage: image.HeroImage,
+			Category: image.Category, Links: links})
+	}
+	if property.Descriptions == nil {
+		property.Descriptions = map[string]string{}
+	}
+	return property
+}
@@ -0,0 +1,257 @@
+package hotel
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/lib/pq"
+	"hotels-service-template/tracing"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+type propertyRepositoryInt interface {
+	upsert(ctx context.Context, properties Properties, seenAt time.Time) (SyncSummary, error)
+	removeUnseen(ctx context.Context, seenAt time.Time) (int, error)
+	get(ctx context.Context, id string) (Property, error)
+	inRegion(ctx context.Context, regionId string, filter PropertyFilter) (PropertyPage, error)
+}
+
+//propertyRepository stores the property content. The columns next to data are those properties are filtered and
+//sorted by, amenities are also kept in property_amenities for the same reason
+type propertyRepository struct {
+	db     tracing.DB
+	logger *slog.Logger
+}
+
+func NewPropertyRepository(db *sql.DB, logger *slog.Logger) propertyRepository {
+	return propertyRepository{
+		db:     tracing.WrapDB(db),
+		logger: logger,
+	}
+}
+
+//upsert syncs a batch of fetched properties in its own transaction, the way the region repository does: new and
+//changed properties are written together with their amenities, unchanged ones are only marked as seen
+func (repository propertyRepository) upsert(ctx context.Context, properties Properties, seenAt time.Time) (
+	SyncSummary, error) {
+	defer observeQuery("upsertProperties", time.Now())
+	ids := make([]string, 0, len(properties))
+	for id := range properties {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	tx, err := repository.db.BeginTx(ctx, nil)
+	if err != nil {
+		return SyncSummary{}, err
+	}
+	defer tx.Rollback()
+
+	stored, err := storedPropertyHashes(ctx, tx, ids)
+	if err != nil {
+		return SyncSummary{}, err
+	}
+
+	var summary SyncSummary
+	var unchanged, rewritten []string
+	var rows, amenities [][]interface{}
+	for _, id := range ids {
+		property := properties[id]
+		data, err := json.Marshal(property)
+		if err != nil {
+			return SyncSummary{}, err
+		}
+		hash := contentHash(data)
+		current, exists := stored[id]
+		switch {
+		case !exists || current.deleted:
+			summary.Added++
+		case current.hash != hash:
+			summary.Changed++
+		default:
+			unchanged = append(unchanged, id)
+			continue
+		}
+		if exists {
+			rewritten = append(rewritten, id)
+		}
+		var starRating, latitude, longitude interface{}
+		if property.StarRating > 0 {
+			starRating = property.StarRating
+		}
+		if property.Coordinates != nil {
+			latitude, longitude = property.Coordinates.Latitude, property.Coordinates.Longitude
+		}
+		rows = append(rows, []interface{}{id, property.Name, nullIfEmpty(property.Category.Id), starRating, latitude,
+			longitude, nullIfEmpty(property.Address.CountryCode), data, hash, seenAt})
+		for _, amenity := range property.Amenities {
+			amenities = append(amenities, []interface{}{id, amenity.Id, amenity.Name})
+		}
+	}
+
+	if len(unchanged) > 0 {
+		_, err = tx.ExecContext(ctx, `update properties set last_seen_at = $1 where id = any($2)`, seenAt,
+			pq.Array(unchanged))
+		if err != nil {
+			return SyncSummary{}, err
+		}
+	}
+	err = bulkInsert(ctx, tx, `insert into properties (id, name, category_id, star_rating, latitude, longitude,
+		country_code, data, content_hash, last_seen_at)`, rows,
+		`on conflict (id) do update set name = excluded.name, category_id = excluded.category_id,
+			star_rating = excluded.star_rating, latitude = excluded.latitude, longitude = excluded.longitude,
+			country_code = excluded.country_code, data = excluded.data, content_hash = excluded.content_hash,
+			last_seen_at = excluded.last_seen_at, updated_at = now(), deleted_at = null`)
+	if err != nil {
+		return SyncSummary{}, err
+	}
+	if len(rewritten) > 0 {
+		_, err = tx.ExecContext(ctx, `delete from property_amenities where property_id = any($1)`,
+			pq.Array(rewritten))
+		if err != nil {
+			return SyncSummary{}, err
+		}
+	}
+	err = bulkInsert(ctx, tx, `insert into property_amenities (property_id, amenity_id, name)`, amenities,
+		`on conflict do nothing`)
+	if err != nil {
+		return SyncSummary{}, err
+	}
+
+	err = tx.CommitContext(ctx)
+	if err != nil {
+		return SyncSummary{}, err
+	}
+	repository.logger.DebugContext(ctx, "property batch stored", "properties", len(properties), "added",
+		summary.Added, "changed", summary.Changed, "unchanged", len(unchanged))
+	return summary, nil
+}
+
+//removeUnseen soft deletes the properties a completed sync started at seenAt did not see and returns how many
+func (repository propertyRepository) removeUnseen(ctx context.Context, seenAt time.Time) (int, error) {
+	defer observeQuery("removeUnseenProperties", time.Now())
+	result, err := repository.db.ExecContext(ctx, `update properties set deleted_at = now(), updated_at = now()
+		where deleted_at is null and last_seen_at < $1`, seenAt)
+	if err != nil {
+		return 0, err
+	}
+	removed, err := result.RowsAffected()
+	repository.logger.DebugContext(ctx, "unseen properties removed", "removed", removed, "seen_before", seenAt)
+	return int(removed), err
+}
+
+func (repository propertyRepository) get(ctx context.Context, id string) (Property, error) {
+	defer observeQuery("getProperty", time.Now())
+	var b []byte
+	query := `select data from properties where id = $1 and deleted_at is null`
+	err := repository.db.QueryRowContext(ctx, query, id).Scan(&b)
+	if err != nil {
+		return Property{}, err
+	}
+	var property Property
+	err = json.Unmarshal(b, &property)
+	if err != nil {
+		return Property{}, err
+	}
+	return property, nil
+}
+
+//propertyFilters is the condition properties are joined on by inRegion, $2 to $5 being the fields of a
+//PropertyFilter
+const propertyFilters = `p.deleted_at is null
+	and ($2::numeric = 0 or p.star_rating >= $2::numeric)
+	and ($3::numeric = 0 or p.star_rating <= $3::numeric)
+	and (cardinality($4::text[]) = 0 or p.category_id = any($4))
+	and (cardinality($5::text[]) = 0 or cardinality($5::text[]) = (select count(*) from property_amenities a
+		where a.property_id = p.id and a.amenity_id = any($5)))`
+
+//propertyOrders maps the sorts inRegion accepts to their order by clause. Ties are broken by id so pages do not
+//overlap
+var propertyOrders = map[PropertySort]string{
+	SortByName:   "p.name, p.id",
+	SortByRating: "p.star_rating desc nulls last, p.name, p.id",
+}
+
+//inRegion returns a page of the stored properties a region lists, directly or in its expanded property ids, that
+//match filter. An unknown region gives sql.ErrNoRows, a region without matching properties an empty page
+func (repository propertyRepository) inRegion(ctx context.Context, regionId string, filter PropertyFilter) (
+	PropertyPage, error) {
+	defer observeQuery("propertiesInRegion", time.Now())
+	order, ok := propertyOrders[filter.Sort]
+	if !ok {
+		order = propertyOrders[SortByName]
+	}
+	args := []interface{}{regionId, filter.MinStarRating, filter.MaxStarRating, pq.Array(filter.Categories),
+		pq.Array(filter.Amenities)}
+	page := PropertyPage{Properties: []PropertySummary{}, Limit: filter.Limit, Offset: filter.Offset}
+
+	count := `select count(p.id) from regions r
+		left join region_properties rp on rp.region_id = r.id
+		left join properties p on p.id = rp.property_id and ` + propertyFilters + `
+		where r.id = $1 and r.deleted_at is null
+		group by r.id`
+	err := repository.db.QueryRowContext(ctx, count, args...).Scan(&page.Total)
+	if err != nil {
+		return PropertyPage{}, err
+	}
+	if page.Total <= filter.Offset {
+		return page, nil
+	}
+
+	query := `select p.data from region_properties rp
+		join properties p on p.id = rp.property_id and ` + propertyFilters + `
+		where rp.region_id = $1
+		order by ` + order + `
+		limit $6 offset $7`
+	rows, err := repository.db.QueryContext(ctx, query, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return PropertyPage{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b []byte
+		if err = rows.Scan(&b); err != nil {
+			return PropertyPage{}, err
+		}
+		var property Property
+		if err = json.Unmarshal(b, &property); err != nil {
+			return PropertyPage{}, err
+		}
+		page.Properties = append(page.Properties, property.summary())
+	}
+	if err = rows.Err(); err != nil {
+		return PropertyPage{}, err
+	}
+	return page, nil
+}
+
+func storedPropertyHashes(ctx context.Context, tx tracing.Tx, ids []string) (map[string]storedContent, error) {
+	rows, err := tx.QueryContext(ctx, `select id, content_hash, deleted_at is not null from properties
+		where id = any($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := map[string]storedContent{}
+	for rows.Next() {
+		var id string
+		var property storedContent
+		if err = rows.Scan(&id, &property.hash, &property.deleted); err != nil {
+			return nil, err
+		}
+		stored[id] = property
+	}
+	return stored, rows.Err()
+}
+
+//nullIfEmpty stores an empty string as null
+func nullIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
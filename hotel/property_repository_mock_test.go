@@ -0,0 +1,45 @@
+package hotel
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+type MockPropertyRepository struct {
+	mock.Mock
+}
+
+func (m *MockPropertyRepository) upsert(ctx context.Context, properties Properties, seenAt time.Time) (SyncSummary,
+	error) {
+	args := m.Called(properties, seenAt)
+	if args[1] != nil {
+		return args[0].(SyncSummary), args[1].(error)
+	}
+	return args[0].(SyncSummary), nil
+}
+
+func (m *MockPropertyRepository) removeUnseen(ctx context.Context, seenAt time.Time) (int, error) {
+	args := m.Called(seenAt)
+	if args[1] != nil {
+		return args[0].(int), args[1].(error)
+	}
+	return args[0].(int), nil
+}
+
+func (m *MockPropertyRepository) get(ctx context.Context, id string) (Property, error) {
+	args := m.Called(id)
+	if args[1] != nil {
+		return args[0].(Property), args[1].(error)
+	}
+	return args[0].(Property), nil
+}
+
+func (m *MockPropertyRepository) inRegion(ctx context.Context, regionId string, filter PropertyFilter) (PropertyPage,
+	error) {
+	args := m.Called(regionId, filter)
+	if args[1] != nil {
+		return args[0].(PropertyPage), args[1].(error)
+	}
+	return args[0].(PropertyPage), nil
+}
@@ -0,0 +1,176 @@
+package hotel
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"hotels-service-template/logging"
+	"testing"
+)
+
+func TestUpsertPropertiesShouldInsertNewPropertiesWithAmenities(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewPropertyRepository(db, logging.Discard())
+	rated := Property{Id: "12345", Name: "Tirana International Hotel", Category: Category{Id: "1", Name: "Hotel"},
+		StarRating: 4, Address: Address{City: "Tirana", CountryCode: "AL"},
+		Coordinates: &Coordinates{Latitude: 41.3275, Longitude: 19.8189},
+		Amenities:   []Amenity{{Id: "2403", Name: "Free WiFi"}, {Id: "9", Name: "Fitness facilities"}}}
+	unrated := Property{Id: "45678", Name: "Yerevan Cascade Hotel"}
+	ratedData, _ := json.Marshal(rated)
+	unratedData, _ := json.Marshal(unrated)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("select id, content_hash").WithArgs(pq.Array([]string{"12345", "45678"})).
+		WillReturnRows(mock.NewRows([]string{"id", "content_hash", "deleted"}))
+	mock.ExpectExec(`insert into properties \(id, name, category_id, star_rating, latitude, longitude,\s+`+
+		`country_code, data, content_hash, last_seen_at\) values \(\$1, .*\$10\), \(\$11, .*\$20\) on conflict`).
+		WithArgs("12345", "Tirana International Hotel", "1", 4.0, 41.3275, 19.8189, "AL", ratedData,
+			contentHash(ratedData), seenAt,
+			"45678", "Yerevan Cascade Hotel", nil, nil, nil, nil, nil, unratedData, contentHash(unratedData), seenAt).
+		WillReturnResult(sqlmock.NewResult(2, 2))
+	mock.ExpectExec("insert into property_amenities").
+		WithArgs("12345", "2403", "Free WiFi", "12345", "9", "Fitness facilities").
+		WillReturnResult(sqlmock.NewResult(2, 2))
+	mock.ExpectCommit()
+
+	summary, err := repo.upsert(context.Background(), Properties{"12345": rated, "45678": unrated}, seenAt)
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, SyncSummary{Added: 2}, summary)
+}
+
+func TestUpsertPropertiesShouldRewriteOnlyChangedProperties(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewPropertyRepository(db, logging.Discard())
+	unchanged := Property{Id: "1", Name: "unchanged"}
+	changed := Property{Id: "2", Name: "changed", Amenities: []Amenity{{Id: "24", Name: "Outdoor pool"}}}
+	unchangedData, _ := json.Marshal(unchanged)
+	changedData, _ := json.Marshal(changed)
+
+	storedRows := mock.NewRows([]string{"id", "content_hash", "deleted"}).
+		AddRow("1", contentHash(unchangedData), false).
+		AddRow("2", "stale hash", false)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("select id, content_hash").WillReturnRows(storedRows)
+	mock.ExpectExec("update properties set last_seen_at").WithArgs(seenAt, pq.Array([]string{"1"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("insert into properties").
+		WithArgs("2", "changed", nil, nil, nil, nil, nil, changedData, contentHash(changedData), seenAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("delete from property_amenities").WithArgs(pq.Array([]string{"2"})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("insert into property_amenities").WithArgs("2", "24", "Outdoor pool").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	summary, err := repo.upsert(context.Background(), Properties{"1": unchanged, "2": changed}, seenAt)
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, SyncSummary{Changed: 1}, summary)
+}
+
+func TestUpsertPropertiesShouldRollbackOnInsertError(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewPropertyRepository(db, logging.Discard())
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("select id").WillReturnRows(mock.NewRows([]string{"id", "content_hash", "deleted"}))
+	mock.ExpectExec("insert into properties").WillReturnError(errors.New("insert exec error"))
+	mock.ExpectRollback()
+
+	summary, err := repo.upsert(context.Background(), Properties{"1": Property{Id: "1", Name: "test"}}, seenAt)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.EqualError(t, err, "insert exec error")
+	assert.Equal(t, SyncSummary{}, summary)
+}
+
+func TestRemoveUnseenProperties(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewPropertyRepository(db, logging.Discard())
+
+	mock.ExpectExec("update properties set deleted_at").WithArgs(seenAt).WillReturnResult(sqlmock.NewResult(0, 2))
+
+	removed, err := repo.removeUnseen(context.Background(), seenAt)
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 2, removed)
+}
+
+func TestGetProperty(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewPropertyRepository(db, logging.Discard())
+	expected := Property{Id: "12345", Name: "Tirana International Hotel", StarRating: 4,
+		Amenities: []Amenity{{Id: "2403", Name: "Free WiFi"}}, Images: []Image{}, Descriptions: map[string]string{}}
+	data, _ := json.Marshal(expected)
+
+	mock.ExpectQuery("select data from properties where id").WithArgs("12345").
+		WillReturnRows(mock.NewRows([]string{"data"}).AddRow(data))
+
+	property, err := repo.get(context.Background(), "12345")
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, expected, property)
+}
+
+func TestPropertiesInRegionShouldFilterSortAndPage(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewPropertyRepository(db, logging.Discard())
+	property := Property{Id: "34567", Name: "Grand Hotel Buenos Aires", Category: Category{Id: "1", Name: "Hotel"},
+		StarRating: 5, Address: Address{City: "Buenos Aires", CountryCode: "AR"},
+		Amenities: []Amenity{{Id: "2403", Name: "Free WiFi"}}, Images: []Image{}, Descriptions: map[string]string{}}
+	data, _ := json.Marshal(property)
+	filter := PropertyFilter{MinStarRating: 4, Categories: []string{"1"}, Amenities: []string{"2403"},
+		Sort: SortByRating, Limit: 1, Offset: 1}
+	filterArgs := []driver.Value{"6023", 4.0, 0.0, pq.Array([]string{"1"}), pq.Array([]string{"2403"})}
+
+	mock.ExpectQuery(`select count\(p.id\) from regions r`).WithArgs(filterArgs...).
+		WillReturnRows(mock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(`select p.data from region_properties rp .* order by p.star_rating desc nulls last, p.name, ` +
+		`p.id\s+limit \$6 offset \$7`).WithArgs(append(filterArgs, 1, 1)...).
+		WillReturnRows(mock.NewRows([]string{"data"}).AddRow(data))
+
+	page, err := repo.inRegion(context.Background(), "6023", filter)
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, PropertyPage{Properties: []PropertySummary{{Id: "34567", Name: "Grand Hotel Buenos Aires",
+		Category: Category{Id: "1", Name: "Hotel"}, StarRating: 5,
+		Address: Address{City: "Buenos Aires", CountryCode: "AR"}}}, Total: 2, Limit: 1, Offset: 1}, page)
+}
+
+func TestPropertiesInRegionShouldNotQueryPastTheLastPage(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewPropertyRepository(db, logging.Discard())
+
+	mock.ExpectQuery(`select count\(p.id\) from regions r`).
+		WillReturnRows(mock.NewRows([]string{"count"}).AddRow(0))
+
+	page, err := repo.inRegion(context.Background(), "6023", PropertyFilter{Sort: SortByName, Limit: 10})
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, PropertyPage{Properties: []PropertySummary{}, Limit: 10}, page)
+}
+
+func TestPropertiesInUnknownRegion(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewPropertyRepository(db, logging.Discard())
+
+	mock.ExpectQuery(`select count\(p.id\) from regions r`).WillReturnRows(mock.NewRows([]string{"count"}))
+
+	_, err := repo.inRegion(context.Background(), "1", PropertyFilter{Sort: SortByName, Limit: 10})
+
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,95 @@
+package hotel
+
+import (
+	"context"
+	"hotels-service-template/tracing"
+	"log/slog"
+)
+
+type PropertyServiceInt interface {
+	Update(ctx context.Context, progress func(SyncProgress)) (SyncSummary, error)
+	Property(ctx context.Context, id string) (Property, error)
+	RegionProperties(ctx context.Context, regionId string, filter PropertyFilter) (PropertyPage, error)
+}
+
+type propertyService struct {
+	repository propertyRepositoryInt
+	client     clientInt
+	batchSize  int
+	logger     *slog.Logger
+}
+
+func NewPropertyService(repo propertyRepositoryInt, client clientInt, logger *slog.Logger) *propertyService {
+	return &propertyService{
+		repository: repo,
+		client:     client,
+		logger:     logger,
+		batchSize:  DefaultSyncBatchSize,
+	}
+}
+
+func (s *propertyService) Property(ctx context.Context, id string) (property Property, err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "propertyService.Property")
+	defer span.Finish(&err)
+	property, err = s.repository.get(ctx, id)
+	return property, notFound(err, "property_not_found", "property %s does not exist", id)
+}
+
+//RegionProperties returns a page of the properties in a region, sorted by name unless filter asks for another sort.
+//Limits outside 1..MaxSearchLimit fall back to the nearest valid value
+func (s *propertyService) RegionProperties(ctx context.Context, regionId string, filter PropertyFilter) (
+	page PropertyPage, err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "propertyService.RegionProperties")
+	defer span.Finish(&err)
+	filter.Limit = clampLimit(filter.Limit)
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	if filter.Sort == "" {
+		filter.Sort = SortByName
+	}
+	page, err = s.repository.inRegion(ctx, regionId, filter)
+	return page, notFound(err, "region_not_found", "region %s does not exist", regionId)
+}
+
+//Update syncs the stored property content with EAN the way regionService.Update syncs regions: batch by batch as
+//it is downloaded, removing properties EAN no longer lists only once the whole download succeeded
+func (s *propertyService) Update(ctx context.Context, progress func(SyncProgress)) (summary SyncSummary,
+	err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "propertyService.Update")
+	defer span.Finish(&err)
+	seenAt := now()
+	var current SyncProgress
+	report := func() {
+		if progress != nil {
+			progress(current)
+		}
+	}
+	err = s.client.streamProperties(ctx, s.batchSize, func(batch Properties) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batchSummary, err := s.repository.upsert(ctx, batch, seenAt)
+		if err != nil {
+			return err
+		}
+		summary.Added += batchSummary.Added
+		summary.Changed += batchSummary.Changed
+		current.PropertiesProcessed += len(batch)
+		report()
+		return nil
+	}, func() {
+		current.PagesFetched++
+		report()
+	})
+	if err != nil {
+		return summary, err
+	}
+	summary.Removed, err = s.repository.removeUnseen(ctx, seenAt)
+	if err != nil {
+		return summary, err
+	}
+	s.logger.InfoContext(ctx, "properties updated", "pages", current.PagesFetched, "properties",
+		current.PropertiesProcessed, "added", summary.Added, "changed", summary.Changed, "removed", summary.Removed)
+	return summary, nil
+}
@@ -0,0 +1,98 @@
+package hotel
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"hotels-service-template/logging"
+	"testing"
+)
+
+type PropertyServiceTestSuite struct {
+	suite.Suite
+	repository *MockPropertyRepository
+	client     *mockClient
+	service    *propertyService
+}
+
+func (s *PropertyServiceTestSuite) SetupTest() {
+	s.repository = &MockPropertyRepository{}
+	s.client = &mockClient{}
+	s.service = NewPropertyService(s.repository, s.client, logging.Discard())
+}
+
+func TestPropertyServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(PropertyServiceTestSuite))
+}
+
+func (s *PropertyServiceTestSuite) TestUpdateShouldStoreBatchesAndReportProgress() {
+	firstBatch := Properties{"1": Property{Id: "1", Name: "first"}, "2": Property{Id: "2", Name: "second"}}
+	secondBatch := Properties{"3": Property{Id: "3", Name: "third"}}
+
+	s.client.On("streamProperties", DefaultSyncBatchSize).Return([]Properties{firstBatch, secondBatch}, nil)
+	s.repository.On("upsert", firstBatch, mock.AnythingOfType("time.Time")).Return(SyncSummary{Added: 2}, nil)
+	s.repository.On("upsert", secondBatch, mock.AnythingOfType("time.Time")).Return(SyncSummary{Changed: 1}, nil)
+	s.repository.On("removeUnseen", mock.AnythingOfType("time.Time")).Return(4, nil)
+	var reported []SyncProgress
+
+	summary, err := s.service.Update(context.Background(), func(progress SyncProgress) {
+		reported = append(reported, progress)
+	})
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), SyncSummary{Added: 2, Changed: 1, Removed: 4}, summary)
+	assert.Equal(s.T(), []SyncProgress{
+		{PagesFetched: 0, PropertiesProcessed: 2},
+		{PagesFetched: 1, PropertiesProcessed: 2},
+		{PagesFetched: 1, PropertiesProcessed: 3},
+		{PagesFetched: 2, PropertiesProcessed: 3},
+	}, reported)
+	s.repository.AssertExpectations(s.T())
+}
+
+func (s *PropertyServiceTestSuite) TestUpdateShouldNotRemoveAfterClientError() {
+	s.client.On("streamProperties", DefaultSyncBatchSize).Return([]Properties{}, errors.New("client error"))
+
+	_, err := s.service.Update(context.Background(), nil)
+
+	assert.EqualError(s.T(), err, "client error")
+	s.repository.AssertNotCalled(s.T(), "removeUnseen", mock.Anything)
+}
+
+func (s *PropertyServiceTestSuite) TestProperty() {
+	property := Property{Id: "12345", Name: "Tirana International Hotel"}
+	s.repository.On("get", "12345").Return(property, nil)
+	s.repository.On("get", "1").Return(Property{}, sql.ErrNoRows)
+
+	obtained, err := s.service.Property(context.Background(), "12345")
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), property, obtained)
+
+	_, err = s.service.Property(context.Background(), "1")
+	assert.Equal(s.T(), NotFound("property_not_found", "property 1 does not exist"), err)
+}
+
+func (s *PropertyServiceTestSuite) TestRegionPropertiesShouldDefaultSortAndLimit() {
+	page := PropertyPage{Properties: []PropertySummary{{Id: "12345"}}, Total: 1, Limit: DefaultSearchLimit}
+	s.repository.On("inRegion", "11", PropertyFilter{MinStarRating: 4, Sort: SortByName,
+		Limit: DefaultSearchLimit}).Return(page, nil)
+
+	obtained, err := s.service.RegionProperties(context.Background(), "11", PropertyFilter{MinStarRating: 4,
+		Offset: -1})
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), page, obtained)
+}
+
+func (s *PropertyServiceTestSuite) TestRegionPropertiesOfUnknownRegion() {
+	s.repository.On("inRegion", "1", PropertyFilter{Sort: SortByRating, Limit: MaxSearchLimit}).
+		Return(PropertyPage{}, sql.ErrNoRows)
+
+	_, err := s.service.RegionProperties(context.Background(), "1", PropertyFilter{Sort: SortByRating,
+		Limit: 1000})
+
+	assert.Equal(s.T(), NotFound("region_not_found", "region 1 does not exist"), err)
+}
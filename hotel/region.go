@@ -1,17 +1,37 @@
 package hotel
 
 type Region struct {
-	Id          string `json:"id"`
-	Type        string `json:"type"`
-	Name        string `json:"name"`
-	NameFull    string `json:"name_full"`
-	Descriptor  string `json:"descriptor"`
-	Ancestors   []Data `json:"ancestors"`
-	Descendants map[string][]string
+	Id                  string   `json:"id"`
+	Type                string   `json:"type"`
+	Name                string   `json:"name"`
+	NameFull            string   `json:"name_full"`
+	Descriptor          string   `json:"descriptor"`
+	Ancestors           []Data   `json:"ancestors"`
+	Descendants         map[string][]string
+	PropertyIds         []string `json:"property_ids"`
+	PropertyIdsExpanded []string `json:"property_ids_expanded"`
 }
 type Regions map[string]Region
 
+//RegionTypes are the region types EAN Rapid knows
+var RegionTypes = []string{"continent", "country", "province_state", "high_level_region", "multi_city_vicinity",
+	"city", "neighborhood", "airport", "point_of_interest", "train_station", "metro_station", "bus_station"}
+
 type Data struct {
 	Id   string
 	Type string
+}
+
+//SyncSummary counts what a sync changed in the stored regions or properties
+type SyncSummary struct {
+	Added   int `json:"added"`
+	Changed int `json:"changed"`
+	Removed int `json:"removed"`
+}
+
+//SyncProgress tells how far a running sync got
+type SyncProgress struct {
+	PagesFetched        int
+	RegionsProcessed    int
+	PropertiesProcessed int
 }
\ No newline at end of file
@@ -1,58 +1,261 @@
 package hotel
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"github.com/lib/pq"
+	"hotels-service-template/tracing"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type regionRepositoryInt interface {
-	update(Regions) error
-	get(dest string) (Region, error)
+	upsert(ctx context.Context, regions Regions, seenAt time.Time) (SyncSummary, error)
+	removeUnseen(ctx context.Context, seenAt time.Time) (int, error)
+	get(ctx context.Context, dest string) (Region, error)
+	search(ctx context.Context, query string, limit int) ([]Region, error)
+	suggestions(ctx context.Context) ([]RegionSuggestion, error)
+	getById(ctx context.Context, id string) (Region, error)
+	ancestors(ctx context.Context, id string, depth int) ([]Region, error)
+	descendants(ctx context.Context, id string, regionType string) ([]Region, error)
+	regionsWithProperty(ctx context.Context, propertyId string) ([]Region, error)
+	each(ctx context.Context, handle func(Region) error) error
 }
 
+//regionRepository runs its statements through tracing.DB, so each is a span of the trace in the context it is given
 type regionRepository struct {
-	db *sql.DB
+	db     tracing.DB
+	logger *slog.Logger
 }
 
-func NewRepository(db *sql.DB) regionRepository {
+func NewRepository(db *sql.DB, logger *slog.Logger) regionRepository {
 	return regionRepository{
-		db: db,
+		db:     tracing.WrapDB(db),
+		logger: logger,
 	}
 }
 
-func (repository regionRepository) update(regions Regions) error {
-	tx, err := repository.db.Begin()
+//upsert syncs a batch of fetched regions in its own transaction. New regions are inserted and regions whose
+//content hash changed are rewritten together with their links, while unchanged regions are only marked as seen.
+//Every region in the batch gets last_seen_at set to seenAt, which removeUnseen relies on
+func (repository regionRepository) upsert(ctx context.Context, regions Regions, seenAt time.Time) (SyncSummary,
+	error) {
+	defer observeQuery("upsert", time.Now())
+	ids := make([]string, 0, len(regions))
+	for id := range regions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	tx, err := repository.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return SyncSummary{}, err
 	}
-	_, err = tx.Exec(`delete from regions`)
+	defer tx.Rollback()
+
+	stored, err := storedHashes(ctx, tx, ids)
 	if err != nil {
-		return err
+		return SyncSummary{}, err
 	}
-	query := `insert into regions (id, name, data) values ($1, $2, $3)`
 
-	for _, value := range regions {
+	var summary SyncSummary
+	var unchanged, relinked []string
+	var rows, ancestors, descendants, properties [][]interface{}
+	for _, id := range ids {
+		value := regions[id]
 		data, err := json.Marshal(value)
-		_, err = tx.Exec(query, value.Id, value.Name, data)
 		if err != nil {
-			return err
+			return SyncSummary{}, err
+		}
+		hash := contentHash(data)
+		current, exists := stored[id]
+		switch {
+		case !exists || current.deleted:
+			summary.Added++
+		case current.hash != hash:
+			summary.Changed++
+		default:
+			unchanged = append(unchanged, id)
+			continue
+		}
+		if exists {
+			relinked = append(relinked, id)
 		}
+		rows = append(rows, []interface{}{id, value.Name, value.NameFull, data, hash, seenAt})
+		ancestors, descendants, properties = appendLinks(value, ancestors, descendants, properties)
 	}
-	err = tx.Commit()
+
+	if len(unchanged) > 0 {
+		_, err = tx.ExecContext(ctx, `update regions set last_seen_at = $1 where id = any($2::bigint[])`, seenAt,
+			pq.Array(unchanged))
+		if err != nil {
+			return SyncSummary{}, err
+		}
+	}
+	err = bulkInsert(ctx, tx, `insert into regions (id, name, name_full, data, content_hash, last_seen_at)`, rows,
+		`on conflict (id) do update set name = excluded.name, name_full = excluded.name_full, data = excluded.data,
+			content_hash = excluded.content_hash, last_seen_at = excluded.last_seen_at, updated_at = now(),
+			deleted_at = null`)
 	if err != nil {
-		return err
+		return SyncSummary{}, err
+	}
+	if len(relinked) > 0 {
+		for _, table := range []string{"region_ancestors", "region_descendants", "region_properties"} {
+			_, err = tx.ExecContext(ctx, `delete from `+table+` where region_id = any($1::bigint[])`, pq.Array(relinked))
+			if err != nil {
+				return SyncSummary{}, err
+			}
+		}
+	}
+	links := []struct {
+		insert string
+		rows   [][]interface{}
+	}{
+		{`insert into region_ancestors (region_id, ancestor_id, ancestor_type, position)`, ancestors},
+		{`insert into region_descendants (region_id, descendant_id, descendant_type)`, descendants},
+		{`insert into region_properties (region_id, property_id, expanded)`, properties},
+	}
+	for _, link := range links {
+		err = bulkInsert(ctx, tx, link.insert, link.rows, `on conflict do nothing`)
+		if err != nil {
+			return SyncSummary{}, err
+		}
+	}
+
+	err = tx.CommitContext(ctx)
+	if err != nil {
+		return SyncSummary{}, err
+	}
+	repository.logger.DebugContext(ctx, "region batch stored", "regions", len(regions), "added", summary.Added,
+		"changed", summary.Changed, "unchanged", len(unchanged))
+	return summary, nil
+}
+
+//removeUnseen soft deletes the regions a completed sync started at seenAt did not see and returns how many
+func (repository regionRepository) removeUnseen(ctx context.Context, seenAt time.Time) (int, error) {
+	defer observeQuery("removeUnseen", time.Now())
+	result, err := repository.db.ExecContext(ctx, `update regions set deleted_at = now(), updated_at = now()
+		where deleted_at is null and (last_seen_at is null or last_seen_at < $1)`, seenAt)
+	if err != nil {
+		return 0, err
+	}
+	removed, err := result.RowsAffected()
+	repository.logger.DebugContext(ctx, "unseen regions removed", "removed", removed, "seen_before", seenAt)
+	return int(removed), err
+}
+
+type storedContent struct {
+	hash    string
+	deleted bool
+}
+
+func storedHashes(ctx context.Context, tx tracing.Tx, ids []string) (map[string]storedContent, error) {
+	rows, err := tx.QueryContext(ctx, `select id, coalesce(content_hash, ''), deleted_at is not null from regions
+		where id = any($1::bigint[])`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := map[string]storedContent{}
+	for rows.Next() {
+		var id string
+		var region storedContent
+		if err = rows.Scan(&id, &region.hash, &region.deleted); err != nil {
+			return nil, err
+		}
+		stored[id] = region
+	}
+	return stored, rows.Err()
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+//appendLinks adds the rows of the region_ancestors, region_descendants and region_properties tables for a region,
+//so hierarchy and property lookups can be answered in SQL
+func appendLinks(region Region, ancestors, descendants, properties [][]interface{}) ([][]interface{},
+	[][]interface{}, [][]interface{}) {
+	for position, ancestor := range region.Ancestors {
+		ancestors = append(ancestors, []interface{}{region.Id, ancestor.Id, ancestor.Type, position})
+	}
+	descendantTypes := make([]string, 0, len(region.Descendants))
+	for descendantType := range region.Descendants {
+		descendantTypes = append(descendantTypes, descendantType)
+	}
+	sort.Strings(descendantTypes)
+	for _, descendantType := range descendantTypes {
+		for _, id := range region.Descendants[descendantType] {
+			descendants = append(descendants, []interface{}{region.Id, id, descendantType})
+		}
+	}
+	for _, id := range region.PropertyIds {
+		properties = append(properties, []interface{}{region.Id, id, false})
+	}
+	for _, id := range region.PropertyIdsExpanded {
+		properties = append(properties, []interface{}{region.Id, id, true})
+	}
+	return ancestors, descendants, properties
+}
+
+//maxParams is the most bind parameters Postgres accepts in one statement
+const maxParams = 65535
+
+//bulkInsert writes rows with multi-row inserts, as few statements as the bind parameter limit allows
+func bulkInsert(ctx context.Context, tx tracing.Tx, insert string, rows [][]interface{}, conflict string) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	rowsPerStatement := maxParams / len(rows[0])
+	for start := 0; start < len(rows); start += rowsPerStatement {
+		end := start + rowsPerStatement
+		if end > len(rows) {
+			end = len(rows)
+		}
+		var statement strings.Builder
+		statement.WriteString(insert)
+		statement.WriteString(" values ")
+		args := make([]interface{}, 0, (end-start)*len(rows[0]))
+		for i, row := range rows[start:end] {
+			if i > 0 {
+				statement.WriteString(", ")
+			}
+			statement.WriteString("(")
+			for j, value := range row {
+				if j > 0 {
+					statement.WriteString(", ")
+				}
+				args = append(args, value)
+				statement.WriteString("$" + strconv.Itoa(len(args)))
+			}
+			statement.WriteString(")")
+		}
+		statement.WriteString(" ")
+		statement.WriteString(conflict)
+		if _, err := tx.ExecContext(ctx, statement.String(), args...); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (repository regionRepository) get(dest string) (Region, error) {
-	tx, err := repository.db.Begin()
+func (repository regionRepository) get(ctx context.Context, dest string) (Region, error) {
+	defer observeQuery("get", time.Now())
+	tx, err := repository.db.BeginTx(ctx, nil)
 	if err != nil {
 		return Region{}, err
 	}
 	var b []byte
-	query := `select data from regions where name=$1`
-	row := tx.QueryRow(query, dest)
+	query := `select data from regions where name=$1 and deleted_at is null`
+	row := tx.QueryRowContext(ctx, query, dest)
 	err = row.Scan(&b)
 	if err != nil {
 		return Region{}, err
@@ -62,9 +265,194 @@ func (repository regionRepository) get(dest string) (Region, error) {
 	if err != nil {
 		return Region{}, err
 	}
-	err = tx.Commit()
+	err = tx.CommitContext(ctx)
+	if err != nil {
+		return Region{}, err
+	}
+	return region, nil
+}
+
+func (repository regionRepository) getById(ctx context.Context, id string) (Region, error) {
+	defer observeQuery("getById", time.Now())
+	var b []byte
+	query := `select data from regions where id=$1 and deleted_at is null`
+	err := repository.db.QueryRowContext(ctx, query, id).Scan(&b)
+	if err != nil {
+		return Region{}, err
+	}
+	var region Region
+	err = json.Unmarshal(b, &region)
 	if err != nil {
 		return Region{}, err
 	}
 	return region, nil
 }
+
+//ancestors returns the stored ancestors of a region nearest first, at most depth of them when depth is positive.
+//The region itself is joined in so an unknown id gives sql.ErrNoRows rather than an empty list
+func (repository regionRepository) ancestors(ctx context.Context, id string, depth int) ([]Region, error) {
+	defer observeQuery("ancestors", time.Now())
+	query := `select r.data from regions self
+		left join region_ancestors a on a.region_id = self.id and ($2 <= 0 or a.position < $2)
+		left join regions r on r.id = a.ancestor_id and r.deleted_at is null
+		where self.id = $1 and self.deleted_at is null
+		order by a.position`
+	return repository.linkedRegions(ctx, query, id, depth)
+}
+
+//descendants returns the stored descendants of a region, only those of regionType unless it is empty
+func (repository regionRepository) descendants(ctx context.Context, id string, regionType string) ([]Region,
+	error) {
+	defer observeQuery("descendants", time.Now())
+	query := `select r.data from regions self
+		left join region_descendants d on d.region_id = self.id and ($2 = '' or d.descendant_type = $2)
+		left join regions r on r.id = d.descendant_id and r.deleted_at is null
+		where self.id = $1 and self.deleted_at is null
+		order by d.descendant_type, r.name`
+	return repository.linkedRegions(ctx, query, id, regionType)
+}
+
+//regionsWithProperty returns the regions containing a property, those listing it directly before those that only
+//list it in their expanded property ids
+func (repository regionRepository) regionsWithProperty(ctx context.Context, propertyId string) ([]Region, error) {
+	defer observeQuery("regionsWithProperty", time.Now())
+	query := `select r.data from region_properties p
+		join regions r on r.id = p.region_id
+		where p.property_id = $1 and r.deleted_at is null
+		order by p.expanded, r.name`
+	return repository.linkedRegions(ctx, query, propertyId)
+}
+
+//linkedRegions runs a query selecting region data. Null data, from a left join without a match, is skipped but
+//still counts as a row, so no rows at all is reported as sql.ErrNoRows
+func (repository regionRepository) linkedRegions(ctx context.Context, query string, args ...interface{}) ([]Region,
+	error) {
+	rows, err := repository.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := false
+	regions := []Region{}
+	for rows.Next() {
+		found = true
+		var b []byte
+		if err = rows.Scan(&b); err != nil {
+			return nil, err
+		}
+		if b == nil {
+			continue
+		}
+		var region Region
+		if err = json.Unmarshal(b, &region); err != nil {
+			return nil, err
+		}
+		regions = append(regions, region)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, sql.ErrNoRows
+	}
+	return regions, nil
+}
+
+//search matches the query against name and name_full ignoring case. Exact names rank first, then name prefixes,
+//then trigram similarity which also catches typos and partial full names such as "Paris, France"
+func (repository regionRepository) search(ctx context.Context, query string, limit int) ([]Region, error) {
+	defer observeQuery("search", time.Now())
+	tx, err := repository.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	normalized := strings.ToLower(strings.TrimSpace(query))
+	statement := `select data from regions
+		where deleted_at is null
+			and (lower(name) like $2 or lower(name_full) like $2 or lower(name) % $1 or $1 <% lower(name_full))
+		order by lower(name) = $1 desc, lower(name) like $2 desc,
+			greatest(similarity(lower(name), $1), word_similarity($1, lower(name_full))) desc, name
+		limit $3`
+	rows, err := tx.QueryContext(ctx, statement, normalized, escapeLike(normalized)+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	regions := []Region{}
+	for rows.Next() {
+		var b []byte
+		if err = rows.Scan(&b); err != nil {
+			return nil, err
+		}
+		var region Region
+		if err = json.Unmarshal(b, &region); err != nil {
+			return nil, err
+		}
+		regions = append(regions, region)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	err = tx.CommitContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return regions, nil
+}
+
+//suggestions loads the fields needed by the autocomplete index for every region
+func (repository regionRepository) suggestions(ctx context.Context) ([]RegionSuggestion, error) {
+	defer observeQuery("suggestions", time.Now())
+	query := `select id, coalesce(name, ''), coalesce(name_full, ''), coalesce(data ->> 'type', ''),
+		coalesce(data ->> 'descriptor', '') from regions where deleted_at is null`
+	rows, err := repository.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []RegionSuggestion{}
+	for rows.Next() {
+		var s RegionSuggestion
+		if err = rows.Scan(&s.Id, &s.Name, &s.NameFull, &s.Type, &s.Descriptor); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+//each hands every live region to handle in id order, reading them from the database as handle consumes them
+func (repository regionRepository) each(ctx context.Context, handle func(Region) error) error {
+	rows, err := repository.db.QueryContext(ctx, `select data from regions where deleted_at is null order by id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b []byte
+		if err = rows.Scan(&b); err != nil {
+			return err
+		}
+		var region Region
+		if err = json.Unmarshal(b, &region); err != nil {
+			return err
+		}
+		if err = handle(region); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func escapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
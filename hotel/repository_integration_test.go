@@ -1,4 +1,5 @@
-// +build integration
+//go:build integration
+//+build integration
 
 package hotel
 
@@ -6,13 +7,16 @@ package hotel
 //link: https://github.com/go-testfixtures/testfixtures
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	_ "github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"hotels-service-template/logging"
 	"testing"
+	"time"
 )
 
 type RepositoryIntegrationTestSuite struct {
@@ -35,20 +39,23 @@ func TestRepositoryIntegrationTestSuite(t *testing.T) {
 }
 
 func (s *RepositoryIntegrationTestSuite) TestUpdateRegions() {
-	repository := NewRepository(s.db)
+	repository := NewRepository(s.db, logging.Discard())
 	var obtainedRegion Region
 	region1 := Region{Id: "1", Name: "first", Descriptor: "test region 1"}
 	region2 := Region{Id: "2", Name: "second", Descriptor: "test region 2"}
 	regions := Regions{"1": region1, "2": region2}
-	repository.update(regions)
+	seenAt := time.Now()
+	_, err := repository.upsert(context.Background(), regions, seenAt)
+	assert.Nil(s.T(), err)
+	_, err = repository.removeUnseen(context.Background(), seenAt)
+	assert.Nil(s.T(), err)
 
 	var b []byte
 	query := `select data from regions where name=$1`
 
 	row := repository.db.QueryRow(query, "first")
 
-	err := row.Scan(&b)
-	fmt.Println(string(b))
+	err = row.Scan(&b)
 	assert.Nil(s.T(), err)
 
 	err = json.Unmarshal(b, &obtainedRegion)
@@ -59,7 +66,7 @@ func (s *RepositoryIntegrationTestSuite) TestUpdateRegions() {
 }
 
 func (s *RepositoryIntegrationTestSuite) TestGetRegion() {
-	repository := NewRepository(s.db)
+	repository := NewRepository(s.db, logging.Discard())
 	region1 := Region{Id: "2", Name: "first", Descriptor: "test region 1"}
 	b, err := json.Marshal(region1)
 	assert.Nil(s.T(), err)
@@ -68,7 +75,7 @@ func (s *RepositoryIntegrationTestSuite) TestGetRegion() {
 	_, err = repository.db.Exec(query, region1.Id, region1.Name, b)
 	assert.Nil(s.T(), err)
 
-	obtainedRegion, _ := repository.get("first")
+	obtainedRegion, _ := repository.get(context.Background(), "first")
 
 	assert.Equal(s.T(), region1, obtainedRegion)
 }
@@ -76,7 +83,7 @@ func (s *RepositoryIntegrationTestSuite) TestGetRegion() {
 func (s *RepositoryIntegrationTestSuite) TearDownTest() {
 	_, err := s.db.Exec(`delete from regions`)
 	if err != nil {
-		fmt.Println("tx exec error delete", err)
+		s.T().Log("deleting regions failed", err)
 	}
 }
 
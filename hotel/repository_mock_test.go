@@ -1,30 +1,97 @@
 package hotel
 
 import (
-	"fmt"
+	"context"
 	"github.com/stretchr/testify/mock"
+	"time"
 )
 
 type MockRegionRepository struct {
 	mock.Mock
 }
 
-func (m *MockRegionRepository) update(regions Regions) error {
-	fmt.Println("Mocked repository update function")
-	args := m.Called(regions)
-	fmt.Println("Args extracted are: ", args[0])
-	if args[0] != nil {
-		return args[0].(error)
+func (m *MockRegionRepository) upsert(ctx context.Context, regions Regions, seenAt time.Time) (SyncSummary, error) {
+	args := m.Called(regions, seenAt)
+	if args[1] != nil {
+		return args[0].(SyncSummary), args[1].(error)
 	}
-	return nil
+	return args[0].(SyncSummary), nil
+}
+
+func (m *MockRegionRepository) removeUnseen(ctx context.Context, seenAt time.Time) (int, error) {
+	args := m.Called(seenAt)
+	if args[1] != nil {
+		return args[0].(int), args[1].(error)
+	}
+	return args[0].(int), nil
 }
 
-func (m *MockRegionRepository) get(dest string) (Region, error) {
-	fmt.Println("Mocked repository get function")
+func (m *MockRegionRepository) get(ctx context.Context, dest string) (Region, error) {
 	args := m.Called(dest)
-	fmt.Println("Args extracted are: ", args[0], args[1])
 	if args[1] != nil {
 		return args[0].(Region), args[1].(error)
 	}
 	return args[0].(Region), nil
 }
+
+func (m *MockRegionRepository) search(ctx context.Context, query string, limit int) ([]Region, error) {
+	args := m.Called(query, limit)
+	if args[1] != nil {
+		return args[0].([]Region), args[1].(error)
+	}
+	return args[0].([]Region), nil
+}
+
+func (m *MockRegionRepository) suggestions(ctx context.Context) ([]RegionSuggestion, error) {
+	args := m.Called()
+	if args[1] != nil {
+		return args[0].([]RegionSuggestion), args[1].(error)
+	}
+	return args[0].([]RegionSuggestion), nil
+}
+
+func (m *MockRegionRepository) getById(ctx context.Context, id string) (Region, error) {
+	args := m.Called(id)
+	if args[1] != nil {
+		return args[0].(Region), args[1].(error)
+	}
+	return args[0].(Region), nil
+}
+
+func (m *MockRegionRepository) ancestors(ctx context.Context, id string, depth int) ([]Region, error) {
+	args := m.Called(id, depth)
+	if args[1] != nil {
+		return args[0].([]Region), args[1].(error)
+	}
+	return args[0].([]Region), nil
+}
+
+func (m *MockRegionRepository) descendants(ctx context.Context, id string, regionType string) ([]Region, error) {
+	args := m.Called(id, regionType)
+	if args[1] != nil {
+		return args[0].([]Region), args[1].(error)
+	}
+	return args[0].([]Region), nil
+}
+
+func (m *MockRegionRepository) regionsWithProperty(ctx context.Context, propertyId string) ([]Region, error) {
+	args := m.Called(propertyId)
+	if args[1] != nil {
+		return args[0].([]Region), args[1].(error)
+	}
+	return args[0].([]Region), nil
+}
+
+//each hands the regions given to On to handle, then returns the error given to On
+func (m *MockRegionRepository) each(ctx context.Context, handle func(Region) error) error {
+	args := m.Called()
+	for _, region := range args[0].([]Region) {
+		if err := handle(region); err != nil {
+			return err
+		}
+	}
+	if args[1] != nil {
+		return args[1].(error)
+	}
+	return nil
+}
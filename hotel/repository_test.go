@@ -1,16 +1,22 @@
 package hotel
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
+	"hotels-service-template/logging"
+	"hotels-service-template/tracing"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func TestGetRegion(t *testing.T) {
 	db, mock, _ := sqlmock.New()
-	repo := NewRepository(db)
+	repo := NewRepository(db, logging.Discard())
 
 	rowString := `{"id": "1", "name": "test", "type": "", "ancestors": null, "name_full": "", 
 	"descriptor": "test region 1", 
@@ -24,7 +30,7 @@ func TestGetRegion(t *testing.T) {
 	mock.ExpectQuery("select data from regions where name").WithArgs("test").WillReturnRows(mockRows)
 	mock.ExpectCommit()
 
-	region, err := repo.get("test")
+	region, err := repo.get(context.Background(), "test")
 	assert.Nil(t, err)
 
 	err = mock.ExpectationsWereMet()
@@ -35,11 +41,11 @@ func TestGetRegion(t *testing.T) {
 
 func TestGetRegionShouldReturnTxBeginError(t *testing.T) {
 	db, mock, _ := sqlmock.New()
-	repo := NewRepository(db)
+	repo := NewRepository(db, logging.Discard())
 
 	mock.ExpectBegin().WillReturnError(errors.New("tx begin error"))
 
-	region, err := repo.get("test")
+	region, err := repo.get(context.Background(), "test")
 	mockErr := mock.ExpectationsWereMet()
 
 	assert.Nil(t, mockErr, "Expectations not met: ", err)
@@ -49,7 +55,7 @@ func TestGetRegionShouldReturnTxBeginError(t *testing.T) {
 
 func TestGetRegionShouldReturnTxScanError(t *testing.T) {
 	db, mock, _ := sqlmock.New()
-	repo := NewRepository(db)
+	repo := NewRepository(db, logging.Discard())
 
 	columns := []string{"o_data"}
 	mockRows := mock.NewRows(columns)
@@ -58,7 +64,7 @@ func TestGetRegionShouldReturnTxScanError(t *testing.T) {
 	mock.ExpectBegin()
 	mock.ExpectQuery("select data from regions where name").WithArgs("test").WillReturnRows(mockRows)
 
-	region, err := repo.get("test")
+	region, err := repo.get(context.Background(), "test")
 
 	mockErr := mock.ExpectationsWereMet()
 	assert.Nil(t, mockErr, "Expectations not met: ", err)
@@ -68,7 +74,7 @@ func TestGetRegionShouldReturnTxScanError(t *testing.T) {
 
 func TestGetRegionShouldReturnJsonUnmarshalError(t *testing.T) {
 	db, mock, _ := sqlmock.New()
-	repo := NewRepository(db)
+	repo := NewRepository(db, logging.Discard())
 
 	rowString := `{"id": "1", "name": "test", "type": "", "ancestors": null, "name_full": "", 
 	"descriptor": "test region 1", 
@@ -80,7 +86,7 @@ func TestGetRegionShouldReturnJsonUnmarshalError(t *testing.T) {
 	mock.ExpectBegin()
 	mock.ExpectQuery("select data from regions where name").WithArgs("test").WillReturnRows(mockRows)
 
-	region, err := repo.get("test")
+	region, err := repo.get(context.Background(), "test")
 
 	mockErr := mock.ExpectationsWereMet()
 	assert.Nil(t, mockErr, "Expectations not met: ", err)
@@ -90,7 +96,7 @@ func TestGetRegionShouldReturnJsonUnmarshalError(t *testing.T) {
 
 func TestGetRegionShouldReturnTxCommitError(t *testing.T) {
 	db, mock, _ := sqlmock.New()
-	repo := NewRepository(db)
+	repo := NewRepository(db, logging.Discard())
 
 	rowString := `{"id": "1", "name": "test", "type": "", "ancestors": null, "name_full": "", 
 	"descriptor": "test region 1", 
@@ -103,7 +109,7 @@ func TestGetRegionShouldReturnTxCommitError(t *testing.T) {
 	mock.ExpectQuery("select data from regions where name").WithArgs("test").WillReturnRows(mockRows)
 	mock.ExpectCommit().WillReturnError(errors.New("tx commit error"))
 
-	region, err := repo.get("test")
+	region, err := repo.get(context.Background(), "test")
 
 	mockErr := mock.ExpectationsWereMet()
 	assert.Nil(t, mockErr, "Expectations not met: ", err)
@@ -111,90 +117,218 @@ func TestGetRegionShouldReturnTxCommitError(t *testing.T) {
 	assert.Equal(t, errors.New("tx commit error"), err)
 }
 
-func TestUpdateShouldInsertRegions(t *testing.T) {
+var seenAt = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+func TestUpsertShouldInsertNewRegions(t *testing.T) {
 	db, mock, _ := sqlmock.New()
-	repo := NewRepository(db)
-	region := Region{Id: "1", Name: "test"}
-	regions := Regions{"1": region}
-	data, _ := json.Marshal(region)
+	repo := NewRepository(db, logging.Discard())
+	first := Region{Id: "1", Name: "first"}
+	second := Region{Id: "2", Name: "second", NameFull: "second, full"}
+	firstData, _ := json.Marshal(first)
+	secondData, _ := json.Marshal(second)
 
 	mock.ExpectBegin()
-	mock.ExpectExec("delete from regions").WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec("insert into regions").WithArgs("1", "test", data).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("select id, coalesce\\(content_hash, ''\\)").WithArgs(pq.Array([]string{"1", "2"})).
+		WillReturnRows(mock.NewRows([]string{"id", "content_hash", "deleted"}))
+	mock.ExpectExec(`insert into regions \(id, name, name_full, data, content_hash, last_seen_at\) `+
+		`values \(\$1, \$2, \$3, \$4, \$5, \$6\), \(\$7, \$8, \$9, \$10, \$11, \$12\) on conflict`).
+		WithArgs("1", "first", "", firstData, contentHash(firstData), seenAt,
+			"2", "second", "second, full", secondData, contentHash(secondData), seenAt).
+		WillReturnResult(sqlmock.NewResult(2, 2))
 	mock.ExpectCommit()
 
-	err := repo.update(regions)
-	assert.Nil(t, err)
+	summary, err := repo.upsert(context.Background(), Regions{"1": first, "2": second}, seenAt)
 
-	err = mock.ExpectationsWereMet()
-	assert.Nil(t, err, "Expectations not met: ", err)
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, SyncSummary{Added: 2}, summary)
 }
 
-func TestUpdateShouldReturnTxBeginError(t *testing.T) {
+func TestUpsertShouldSyncChangedUnchangedAndRestoredRegions(t *testing.T) {
 	db, mock, _ := sqlmock.New()
-	repo := NewRepository(db)
-	regions := Regions{"1": Region{Id: "1", Name: "test"}}
+	repo := NewRepository(db, logging.Discard())
+	unchanged := Region{Id: "1", Name: "unchanged"}
+	changed := Region{Id: "2", Name: "changed", Ancestors: []Data{{Id: "1", Type: "country"}}}
+	restored := Region{Id: "3", Name: "restored", PropertyIds: []string{"12345"}}
+	unchangedData, _ := json.Marshal(unchanged)
+	changedData, _ := json.Marshal(changed)
+	restoredData, _ := json.Marshal(restored)
+
+	storedRows := mock.NewRows([]string{"id", "content_hash", "deleted"}).
+		AddRow(1, contentHash(unchangedData), false).
+		AddRow(2, "stale hash", false).
+		AddRow(3, contentHash(restoredData), true)
 
-	mock.ExpectBegin().WillReturnError(errors.New("tx begin error"))
+	mock.ExpectBegin()
+	mock.ExpectQuery("select id, coalesce\\(content_hash, ''\\)").WithArgs(pq.Array([]string{"1", "2", "3"})).
+		WillReturnRows(storedRows)
+	mock.ExpectExec("update regions set last_seen_at").WithArgs(seenAt, pq.Array([]string{"1"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("insert into regions").
+		WithArgs("2", "changed", "", changedData, contentHash(changedData), seenAt,
+			"3", "restored", "", restoredData, contentHash(restoredData), seenAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("delete from region_ancestors").WithArgs(pq.Array([]string{"2", "3"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("delete from region_descendants").WithArgs(pq.Array([]string{"2", "3"})).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("delete from region_properties").WithArgs(pq.Array([]string{"2", "3"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("insert into region_ancestors").WithArgs("2", "1", "country", 0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("insert into region_properties").WithArgs("3", "12345", false).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
-	err := repo.update(regions)
-	mockErr := mock.ExpectationsWereMet()
+	summary, err := repo.upsert(context.Background(), Regions{"1": unchanged, "2": changed, "3": restored}, seenAt)
 
-	assert.Nil(t, mockErr, "Expectations not met: ", err)
-	assert.EqualError(t, err, "tx begin error")
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, SyncSummary{Added: 1, Changed: 1}, summary)
 }
 
-func TestUpdateShouldReturnDeleteExecError(t *testing.T) {
+func TestUpsertShouldInsertRegionLinks(t *testing.T) {
 	db, mock, _ := sqlmock.New()
-	repo := NewRepository(db)
-	regions := Regions{"1": Region{Id: "1", Name: "test"}}
+	repo := NewRepository(db, logging.Discard())
+	region := Region{Id: "2734", Name: "Paris", NameFull: "Paris, France",
+		Ancestors:           []Data{{Id: "11", Type: "province_state"}, {Id: "73", Type: "country"}},
+		Descendants:         map[string][]string{"neighborhood": {"553248"}, "airport": {"6139"}},
+		PropertyIds:         []string{"12345"},
+		PropertyIdsExpanded: []string{"67890"}}
+	data, _ := json.Marshal(region)
 
 	mock.ExpectBegin()
-	mock.ExpectExec("delete from regions").WillReturnError(errors.New("delete exec error"))
+	mock.ExpectQuery("select id").WillReturnRows(mock.NewRows([]string{"id", "content_hash", "deleted"}))
+	mock.ExpectExec("insert into regions").WithArgs("2734", "Paris", "Paris, France", data, contentHash(data), seenAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`insert into region_ancestors \(region_id, ancestor_id, ancestor_type, position\) `+
+		`values \(\$1, \$2, \$3, \$4\), \(\$5, \$6, \$7, \$8\) on conflict do nothing`).
+		WithArgs("2734", "11", "province_state", 0, "2734", "73", "country", 1).
+		WillReturnResult(sqlmock.NewResult(2, 2))
+	mock.ExpectExec("insert into region_descendants").
+		WithArgs("2734", "6139", "airport", "2734", "553248", "neighborhood").
+		WillReturnResult(sqlmock.NewResult(2, 2))
+	mock.ExpectExec("insert into region_properties").WithArgs("2734", "12345", false, "2734", "67890", true).
+		WillReturnResult(sqlmock.NewResult(2, 2))
+	mock.ExpectCommit()
 
-	err := repo.update(regions)
-	mockErr := mock.ExpectationsWereMet()
+	_, err := repo.upsert(context.Background(), Regions{"2734": region}, seenAt)
 
-	assert.Nil(t, mockErr, "Expectations not met: ", err)
-	assert.EqualError(t, err, "delete exec error")
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestUpsertShouldReturnTxBeginError(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewRepository(db, logging.Discard())
+
+	mock.ExpectBegin().WillReturnError(errors.New("tx begin error"))
 
+	_, err := repo.upsert(context.Background(), Regions{"1": Region{Id: "1", Name: "test"}}, seenAt)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.EqualError(t, err, "tx begin error")
 }
 
-func TestUpdateShouldReturnInsertExecError(t *testing.T) {
+func TestUpsertShouldRollbackOnInsertError(t *testing.T) {
 	db, mock, _ := sqlmock.New()
-	repo := NewRepository(db)
-	region := Region{Id: "1", Name: "test"}
-	regions := Regions{"1": region}
-	data, _ := json.Marshal(region)
+	repo := NewRepository(db, logging.Discard())
 
 	mock.ExpectBegin()
-	mock.ExpectExec("delete from regions").WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec("insert into regions").WithArgs("1", "test", data).WillReturnError(errors.New("insert exec error"))
+	mock.ExpectQuery("select id").WillReturnRows(mock.NewRows([]string{"id", "content_hash", "deleted"}))
+	mock.ExpectExec("insert into regions").WillReturnError(errors.New("insert exec error"))
+	mock.ExpectRollback()
 
-	err := repo.update(regions)
-	mockErr := mock.ExpectationsWereMet()
+	summary, err := repo.upsert(context.Background(), Regions{"1": Region{Id: "1", Name: "test"}}, seenAt)
 
-	assert.Nil(t, mockErr, "Expectations not met: ", err)
+	assert.Nil(t, mock.ExpectationsWereMet())
 	assert.EqualError(t, err, "insert exec error")
-
+	assert.Equal(t, SyncSummary{}, summary)
 }
 
-func TestUpdateShouldReturnCommitError(t *testing.T) {
+func TestUpsertShouldReturnCommitError(t *testing.T) {
 	db, mock, _ := sqlmock.New()
-	repo := NewRepository(db)
-	region := Region{Id: "1", Name: "test"}
-	regions := Regions{"1": region}
-	data, _ := json.Marshal(region)
+	repo := NewRepository(db, logging.Discard())
 
 	mock.ExpectBegin()
-	mock.ExpectExec("delete from regions").WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec("insert into regions").WithArgs("1", "test", data).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("select id").WillReturnRows(mock.NewRows([]string{"id", "content_hash", "deleted"}))
+	mock.ExpectExec("insert into regions").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit().WillReturnError(errors.New("commit error"))
 
-	err := repo.update(regions)
-	mockErr := mock.ExpectationsWereMet()
+	summary, err := repo.upsert(context.Background(), Regions{"1": Region{Id: "1", Name: "test"}}, seenAt)
 
-	assert.Nil(t, mockErr, "Expectations not met: ", err)
+	assert.Nil(t, mock.ExpectationsWereMet())
 	assert.EqualError(t, err, "commit error")
+	assert.Equal(t, SyncSummary{}, summary)
 }
 
+func TestRemoveUnseen(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewRepository(db, logging.Discard())
+
+	mock.ExpectExec("update regions set deleted_at").WithArgs(seenAt).WillReturnResult(sqlmock.NewResult(0, 4))
+
+	removed, err := repo.removeUnseen(context.Background(), seenAt)
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 4, removed)
+}
+
+func TestEach(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewRepository(db, logging.Discard())
+
+	mock.ExpectQuery("select data from regions where deleted_at is null order by id").
+		WillReturnRows(mock.NewRows([]string{"data"}).
+			AddRow(`{"id": "1", "name": "first"}`).
+			AddRow(`{"id": "2", "name": "second"}`))
+
+	var regions []Region
+	err := repo.each(context.Background(), func(region Region) error {
+		regions = append(regions, region)
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []Region{{Id: "1", Name: "first"}, {Id: "2", Name: "second"}}, regions)
+}
+
+func TestEachShouldStopAtHandlerError(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewRepository(db, logging.Discard())
+
+	mock.ExpectQuery("select data from regions").
+		WillReturnRows(mock.NewRows([]string{"data"}).AddRow(`{"id": "1"}`).AddRow(`{"id": "2"}`))
+
+	calls := 0
+	err := repo.each(context.Background(), func(region Region) error {
+		calls++
+		return errors.New("write error")
+	})
+
+	assert.EqualError(t, err, "write error")
+	assert.Equal(t, 1, calls)
+}
+
+func TestBulkInsertShouldSplitStatementsAtParameterLimit(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	rows := make([][]interface{}, maxParams/3+1)
+	for i := range rows {
+		rows[i] = []interface{}{"2734", strconv.Itoa(i), false}
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("insert into region_properties").WillReturnResult(sqlmock.NewResult(0, int64(maxParams/3)))
+	mock.ExpectExec(`insert into region_properties \(region_id, property_id, expanded\) `+
+		`values \(\$1, \$2, \$3\) on conflict do nothing`).WithArgs("2734", strconv.Itoa(maxParams/3), false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	tx, _ := tracing.WrapDB(db).BeginTx(context.Background(), nil)
+
+	err := bulkInsert(context.Background(), tx, `insert into region_properties (region_id, property_id, expanded)`, rows,
+		`on conflict do nothing`)
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
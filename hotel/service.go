@@ -1,30 +1,199 @@
 package hotel
 
+import (
+	"context"
+	"github.com/pkg/errors"
+	"hotels-service-template/tracing"
+	"log/slog"
+	"sync"
+)
+
+const (
+	DefaultSearchLimit = 10
+	MaxSearchLimit     = 50
+	//DefaultSyncBatchSize is how many regions are decoded before they are written in one transaction
+	DefaultSyncBatchSize = 500
+)
+
 type RegionServiceInt interface {
-	Update() error
-	Search(destination string) (Region, error)
+	Update(ctx context.Context, progress func(SyncProgress)) (SyncSummary, error)
+	Search(ctx context.Context, destination string) (Region, error)
+	FuzzySearch(ctx context.Context, query string, limit int) ([]Region, error)
+	Autocomplete(ctx context.Context, prefix string, limit int) []RegionSuggestion
+	RefreshIndex(ctx context.Context) error
+	Region(ctx context.Context, id string) (Region, error)
+	Ancestors(ctx context.Context, id string) ([]Region, error)
+	Descendants(ctx context.Context, id string, regionType string) ([]Region, error)
+	Hierarchy(ctx context.Context, id string, depth int) ([]Region, error)
+	PropertyRegions(ctx context.Context, propertyId string) ([]Region, error)
+	Export(ctx context.Context, handle func(Region) error) error
 }
 
 type regionService struct {
 	repository regionRepositoryInt
 	client     clientInt
+	batchSize  int
+	indexLock  sync.RWMutex
+	index      *autocompleteIndex
+	logger     *slog.Logger
 }
 
-func NewRegionService(repo regionRepositoryInt, client clientInt) *regionService {
+func NewRegionService(repo regionRepositoryInt, client clientInt, logger *slog.Logger) *regionService {
 	return &regionService{
 		repository: repo,
 		client:     client,
+		logger:     logger,
+		batchSize:  DefaultSyncBatchSize,
+		index:      newAutocompleteIndex(nil),
 	}
 }
 
-func (s *regionService) Search(destination string) (Region, error) {
-	return s.repository.get(destination)
+func (s *regionService) Search(ctx context.Context, destination string) (region Region, err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "regionService.Search")
+	defer span.Finish(&err)
+	region, err = s.repository.get(ctx, destination)
+	return region, notFound(err, "destination_not_found", "no region is named %q", destination)
 }
 
-func (s *regionService) Update() error {
-	reg, err := s.client.getRegions()
+//FuzzySearch returns the regions best matching a partial or misspelt destination, best match first.
+//Limits outside 1..MaxSearchLimit fall back to the nearest valid value
+func (s *regionService) FuzzySearch(ctx context.Context, query string, limit int) (regions []Region, err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "regionService.FuzzySearch")
+	defer span.Finish(&err)
+	return s.repository.search(ctx, query, clampLimit(limit))
+}
+
+//Autocomplete serves prefix suggestions from the in memory index without touching the database
+func (s *regionService) Autocomplete(ctx context.Context, prefix string, limit int) []RegionSuggestion {
+	_, span := tracing.Start(ctx, tracing.Internal, "regionService.Autocomplete")
+	defer span.End()
+	s.indexLock.RLock()
+	index := s.index
+	s.indexLock.RUnlock()
+	return index.lookup(prefix, clampLimit(limit))
+}
+
+//RefreshIndex rebuilds the autocomplete index from the repository. The old index keeps serving until the new one
+//is ready
+func (s *regionService) RefreshIndex(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "regionService.RefreshIndex")
+	defer span.Finish(&err)
+	suggestions, err := s.repository.suggestions(ctx)
 	if err != nil {
 		return err
 	}
-	return s.repository.update(reg)
+	index := newAutocompleteIndex(suggestions)
+
+	s.indexLock.Lock()
+	s.index = index
+	s.indexLock.Unlock()
+	storedRegions.Set(float64(len(suggestions)))
+	s.logger.InfoContext(ctx, "autocomplete index refreshed", "suggestions", len(suggestions))
+	return nil
+}
+
+func (s *regionService) Region(ctx context.Context, id string) (region Region, err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "regionService.Region")
+	defer span.Finish(&err)
+	region, err = s.repository.getById(ctx, id)
+	return region, notFound(err, "region_not_found", "region %s does not exist", id)
+}
+
+//Ancestors returns the full ancestor regions of a region, nearest first
+func (s *regionService) Ancestors(ctx context.Context, id string) (regions []Region, err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "regionService.Ancestors")
+	defer span.Finish(&err)
+	regions, err = s.repository.ancestors(ctx, id, 0)
+	return regions, notFound(err, "region_not_found", "region %s does not exist", id)
+}
+
+//Descendants returns the full descendant regions of a region. An empty regionType returns descendants of every type
+func (s *regionService) Descendants(ctx context.Context, id string, regionType string) (regions []Region,
+	err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "regionService.Descendants")
+	defer span.Finish(&err)
+	regions, err = s.repository.descendants(ctx, id, regionType)
+	return regions, notFound(err, "region_not_found", "region %s does not exist", id)
+}
+
+//Hierarchy walks up from a region through at most depth of its ancestors, nearest first, giving breadcrumbs such
+//as Paris, Île-de-France, France, Europe. A depth of zero or less walks all the way up
+func (s *regionService) Hierarchy(ctx context.Context, id string, depth int) (regions []Region, err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "regionService.Hierarchy")
+	defer span.Finish(&err)
+	region, err := s.Region(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	ancestors, err := s.repository.ancestors(ctx, id, depth)
+	if err != nil {
+		return nil, notFound(err, "region_not_found", "region %s does not exist", id)
+	}
+	return append([]Region{region}, ancestors...), nil
+}
+
+//PropertyRegions returns the regions that contain a property
+func (s *regionService) PropertyRegions(ctx context.Context, propertyId string) (regions []Region, err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "regionService.PropertyRegions")
+	defer span.Finish(&err)
+	return s.repository.regionsWithProperty(ctx, propertyId)
+}
+
+//Export hands every stored region to handle, one at a time
+func (s *regionService) Export(ctx context.Context, handle func(Region) error) (err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "regionService.Export")
+	defer span.Finish(&err)
+	return s.repository.each(ctx, handle)
+}
+
+//Update syncs the stored regions with EAN batch by batch as they are downloaded and reports how many were added,
+//changed and removed. Regions are only removed once the whole download succeeded. progress, when not nil, is
+//called after every page and every stored batch. Cancelling ctx stops the sync before the next request or batch
+func (s *regionService) Update(ctx context.Context, progress func(SyncProgress)) (summary SyncSummary, err error) {
+	ctx, span := tracing.Start(ctx, tracing.Internal, "regionService.Update")
+	defer span.Finish(&err)
+	seenAt := now()
+	var current SyncProgress
+	report := func() {
+		if progress != nil {
+			progress(current)
+		}
+	}
+	err = s.client.streamRegions(ctx, s.batchSize, func(batch Regions) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batchSummary, err := s.repository.upsert(ctx, batch, seenAt)
+		if err != nil {
+			return err
+		}
+		summary.Added += batchSummary.Added
+		summary.Changed += batchSummary.Changed
+		current.RegionsProcessed += len(batch)
+		report()
+		return nil
+	}, func() {
+		current.PagesFetched++
+		report()
+	})
+	if err != nil {
+		return summary, err
+	}
+	summary.Removed, err = s.repository.removeUnseen(ctx, seenAt)
+	if err != nil {
+		return summary, err
+	}
+	s.logger.InfoContext(ctx, "regions updated", "pages", current.PagesFetched, "regions", current.RegionsProcessed,
+		"added", summary.Added, "changed", summary.Changed, "removed", summary.Removed)
+	return summary, errors.Wrap(s.RefreshIndex(ctx), "refresh autocomplete index")
+}
+
+func clampLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultSearchLimit
+	}
+	if limit > MaxSearchLimit {
+		return MaxSearchLimit
+	}
+	return limit
 }
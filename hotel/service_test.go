@@ -1,9 +1,13 @@
 package hotel
 
 import (
+	"context"
+	"database/sql"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"hotels-service-template/logging"
 	"testing"
 )
 
@@ -23,45 +27,78 @@ func TestRegionServiceTestSuite(t *testing.T) {
 }
 
 func (s *RegionServiceTestSuite) TestUpdate() {
-	service := NewRegionService(s.repository, s.client)
-	mockRegions := Regions{"1": Region{Name: "test region", Id: "1", Type: "city"}}
+	service := NewRegionService(s.repository, s.client, logging.Discard())
+	firstBatch := Regions{"1": Region{Name: "test region", Id: "1", Type: "city"}}
+	secondBatch := Regions{"2": Region{Name: "second region", Id: "2", Type: "city"}}
 
-	s.client.On("getRegions").Return(mockRegions,nil)
-	s.repository.On("update", mockRegions).Times(1).Return(nil)
+	s.client.On("streamRegions", DefaultSyncBatchSize).Return([]Regions{firstBatch, secondBatch}, nil)
+	s.repository.On("upsert", firstBatch, mock.AnythingOfType("time.Time")).Times(1).Return(SyncSummary{Added: 1}, nil)
+	s.repository.On("upsert", secondBatch, mock.AnythingOfType("time.Time")).Times(1).
+		Return(SyncSummary{Changed: 1}, nil)
+	s.repository.On("removeUnseen", mock.AnythingOfType("time.Time")).Times(1).Return(3, nil)
+	s.repository.On("suggestions").Times(1).Return([]RegionSuggestion{{Name: "test region", Id: "1"}}, nil)
 
-	err := service.Update()
+	summary, err := service.Update(context.Background(), nil)
 
 	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), SyncSummary{Added: 1, Changed: 1, Removed: 3}, summary)
 	s.client.AssertExpectations(s.T())
 	s.repository.AssertExpectations(s.T())
 }
 
+func (s *RegionServiceTestSuite) TestUpdateShouldReportProgress() {
+	service := NewRegionService(s.repository, s.client, logging.Discard())
+	firstBatch := Regions{"1": Region{Name: "test region", Id: "1"}, "2": Region{Name: "second region", Id: "2"}}
+	secondBatch := Regions{"3": Region{Name: "third region", Id: "3"}}
+
+	s.client.On("streamRegions", DefaultSyncBatchSize).Return([]Regions{firstBatch, secondBatch}, nil)
+	s.repository.On("upsert", mock.Anything, mock.AnythingOfType("time.Time")).Return(SyncSummary{}, nil)
+	s.repository.On("removeUnseen", mock.AnythingOfType("time.Time")).Return(0, nil)
+	s.repository.On("suggestions").Return([]RegionSuggestion{}, nil)
+	var reported []SyncProgress
+
+	_, err := service.Update(context.Background(), func(progress SyncProgress) {
+		reported = append(reported, progress)
+	})
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []SyncProgress{
+		{PagesFetched: 0, RegionsProcessed: 2},
+		{PagesFetched: 1, RegionsProcessed: 2},
+		{PagesFetched: 1, RegionsProcessed: 3},
+		{PagesFetched: 2, RegionsProcessed: 3},
+	}, reported)
+}
+
 func (s *RegionServiceTestSuite) TestUpdateShouldReturnClientError() {
-	service := NewRegionService(s.repository, s.client)
+	service := NewRegionService(s.repository, s.client, logging.Discard())
 
-	s.client.On("getRegions").Return(Regions{}, errors.New("client error"))
+	s.client.On("streamRegions", DefaultSyncBatchSize).Return([]Regions{}, errors.New("client error"))
 
-	err := service.Update()
+	_, err := service.Update(context.Background(), nil)
 
 	assert.EqualError(s.T(), err, "client error")
 	s.client.AssertExpectations(s.T())
+	s.repository.AssertNotCalled(s.T(), "removeUnseen", mock.Anything)
 }
 
 func (s *RegionServiceTestSuite) TestUpdateShouldReturnRepositoryError() {
-	service := NewRegionService(s.repository, s.client)
+	service := NewRegionService(s.repository, s.client, logging.Discard())
 
-	mockRegions := Regions{}
-	s.client.On("getRegions").Return(mockRegions, nil)
-	s.repository.On("update", mockRegions).Times(1).Return(errors.New("repository error"))
+	mockRegions := Regions{"1": Region{Name: "test region", Id: "1", Type: "city"}}
+	s.client.On("streamRegions", DefaultSyncBatchSize).Return([]Regions{mockRegions}, nil)
+	s.repository.On("upsert", mockRegions, mock.AnythingOfType("time.Time")).Times(1).
+		Return(SyncSummary{}, errors.New("repository error"))
 
-	err := service.Update()
+	_, err := service.Update(context.Background(), nil)
 
 	assert.EqualError(s.T(), err, "repository error")
 	s.client.AssertExpectations(s.T())
+	s.repository.AssertNotCalled(s.T(), "removeUnseen", mock.Anything)
 }
 
 func (s *RegionServiceTestSuite) TestSearch() {
-	service := NewRegionService(s.repository, s.client)
+	service := NewRegionService(s.repository, s.client, logging.Discard())
 	expectedRegion := Region{Name: "test region", Id: "1", Type: "city"}
 
 	tt := []struct {
@@ -74,13 +111,17 @@ func (s *RegionServiceTestSuite) TestSearch() {
 	}{
 		{"ShouldReturnRegion", "test region", expectedRegion, nil, expectedRegion, assert.NoError},
 		{"ShouldReturnError", "test ", Region{}, errors.New("error"), Region{}, assert.Error},
+		{"ShouldReturnNotFound", "nowhere", Region{}, sql.ErrNoRows, Region{},
+			func(t assert.TestingT, err error, _ ...interface{}) bool {
+				return assert.Equal(t, NotFound("destination_not_found", `no region is named "nowhere"`), err)
+			}},
 	}
 
 	for _, tc := range tt {
 		s.T().Run(tc.testDescription, func(t *testing.T) {
 			s.repository.On("get", tc.destination).Return(tc.mockRegion, tc.mockError)
 
-			returnedRegion, err := service.Search(tc.destination)
+			returnedRegion, err := service.Search(context.Background(), tc.destination)
 
 			s.repository.AssertExpectations(t)
 			assert.Equal(t, tc.expectedRegion, returnedRegion)
@@ -89,3 +130,151 @@ func (s *RegionServiceTestSuite) TestSearch() {
 
 	}
 }
+
+func (s *RegionServiceTestSuite) TestFuzzySearch() {
+	service := NewRegionService(s.repository, s.client, logging.Discard())
+	expectedRegions := []Region{{Name: "Paris", Id: "2734", Type: "city"}}
+
+	tt := []struct {
+		testDescription string
+		limit           int
+		expectedLimit   int
+		mockRegions     []Region
+		mockError       error
+		assertion       assert.ErrorAssertionFunc
+	}{
+		{"ShouldReturnRegions", 5, 5, expectedRegions, nil, assert.NoError},
+		{"ShouldUseDefaultLimit", 0, DefaultSearchLimit, expectedRegions, nil, assert.NoError},
+		{"ShouldCapLimit", 500, MaxSearchLimit, expectedRegions, nil, assert.NoError},
+		{"ShouldReturnError", 5, 5, nil, errors.New("error"), assert.Error},
+	}
+
+	for _, tc := range tt {
+		s.T().Run(tc.testDescription, func(t *testing.T) {
+			s.repository = &MockRegionRepository{}
+			service.repository = s.repository
+			s.repository.On("search", "pari", tc.expectedLimit).Return(tc.mockRegions, tc.mockError)
+
+			regions, err := service.FuzzySearch(context.Background(), "pari", tc.limit)
+
+			s.repository.AssertExpectations(t)
+			assert.Equal(t, tc.mockRegions, regions)
+			tc.assertion(t, err)
+		})
+	}
+}
+
+func (s *RegionServiceTestSuite) TestUpdateShouldReturnIndexRefreshError() {
+	service := NewRegionService(s.repository, s.client, logging.Discard())
+
+	s.client.On("streamRegions", DefaultSyncBatchSize).Return([]Regions{}, nil)
+	s.repository.On("removeUnseen", mock.AnythingOfType("time.Time")).Times(1).Return(0, nil)
+	s.repository.On("suggestions").Times(1).Return([]RegionSuggestion(nil), errors.New("query error"))
+
+	_, err := service.Update(context.Background(), nil)
+
+	assert.EqualError(s.T(), err, "refresh autocomplete index: query error")
+	s.repository.AssertExpectations(s.T())
+}
+
+func (s *RegionServiceTestSuite) TestAutocomplete() {
+	service := NewRegionService(s.repository, s.client, logging.Discard())
+	paris := RegionSuggestion{Id: "2734", Name: "Paris", NameFull: "Paris, France", Type: "city"}
+	s.repository.On("suggestions").Times(1).Return([]RegionSuggestion{paris}, nil)
+
+	assert.Equal(s.T(), []RegionSuggestion{}, service.Autocomplete(context.Background(), "par", 5))
+
+	err := service.RefreshIndex(context.Background())
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []RegionSuggestion{paris}, service.Autocomplete(context.Background(), "par", 5))
+	s.repository.AssertExpectations(s.T())
+}
+
+func (s *RegionServiceTestSuite) TestRefreshIndexShouldKeepOldIndexOnError() {
+	service := NewRegionService(s.repository, s.client, logging.Discard())
+	paris := RegionSuggestion{Id: "2734", Name: "Paris", NameFull: "Paris, France", Type: "city"}
+	s.repository.On("suggestions").Times(1).Return([]RegionSuggestion{paris}, nil)
+	assert.NoError(s.T(), service.RefreshIndex(context.Background()))
+
+	s.repository.On("suggestions").Times(1).Return([]RegionSuggestion(nil), errors.New("query error"))
+	err := service.RefreshIndex(context.Background())
+
+	assert.EqualError(s.T(), err, "query error")
+	assert.Equal(s.T(), []RegionSuggestion{paris}, service.Autocomplete(context.Background(), "paris", 5))
+}
+
+func (s *RegionServiceTestSuite) TestAncestors() {
+	service := NewRegionService(s.repository, s.client, logging.Discard())
+	ancestors := []Region{{Id: "11", Name: "Île-de-France"}, {Id: "73", Name: "France"}}
+	s.repository.On("ancestors", "2734", 0).Return(ancestors, nil)
+
+	regions, err := service.Ancestors(context.Background(), "2734")
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), ancestors, regions)
+	s.repository.AssertExpectations(s.T())
+}
+
+func (s *RegionServiceTestSuite) TestDescendants() {
+	service := NewRegionService(s.repository, s.client, logging.Discard())
+	descendants := []Region{{Id: "553248", Name: "Le Marais", Type: "neighborhood"}}
+	s.repository.On("descendants", "2734", "neighborhood").Return(descendants, nil)
+
+	regions, err := service.Descendants(context.Background(), "2734", "neighborhood")
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), descendants, regions)
+	s.repository.AssertExpectations(s.T())
+}
+
+func (s *RegionServiceTestSuite) TestHierarchy() {
+	service := NewRegionService(s.repository, s.client, logging.Discard())
+	paris := Region{Id: "2734", Name: "Paris", Type: "city"}
+	ancestors := []Region{{Id: "11", Name: "Île-de-France"}, {Id: "73", Name: "France"}}
+	s.repository.On("getById", "2734").Return(paris, nil)
+	s.repository.On("ancestors", "2734", 2).Return(ancestors, nil)
+
+	hierarchy, err := service.Hierarchy(context.Background(), "2734", 2)
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []Region{paris, ancestors[0], ancestors[1]}, hierarchy)
+	s.repository.AssertExpectations(s.T())
+}
+
+func (s *RegionServiceTestSuite) TestHierarchyShouldReturnError() {
+	service := NewRegionService(s.repository, s.client, logging.Discard())
+	s.repository.On("getById", "2734").Return(Region{}, sql.ErrNoRows)
+
+	hierarchy, err := service.Hierarchy(context.Background(), "2734", 2)
+
+	assert.Equal(s.T(), NotFound("region_not_found", "region 2734 does not exist"), err)
+	assert.Nil(s.T(), hierarchy)
+}
+
+func (s *RegionServiceTestSuite) TestPropertyRegions() {
+	service := NewRegionService(s.repository, s.client, logging.Discard())
+	regions := []Region{{Id: "2734", Name: "Paris"}}
+	s.repository.On("regionsWithProperty", "12345").Return(regions, nil)
+
+	obtained, err := service.PropertyRegions(context.Background(), "12345")
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), regions, obtained)
+	s.repository.AssertExpectations(s.T())
+}
+
+func (s *RegionServiceTestSuite) TestExport() {
+	service := NewRegionService(s.repository, s.client, logging.Discard())
+	regions := []Region{{Id: "2", Name: "Albania"}, {Id: "136", Name: "Nigeria"}}
+	s.repository.On("each").Return(regions, nil)
+
+	var exported []Region
+	err := service.Export(context.Background(), func(region Region) error {
+		exported = append(exported, region)
+		return nil
+	})
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), regions, exported)
+}
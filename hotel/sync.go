@@ -0,0 +1,207 @@
+package hotel
+
+import (
+	"context"
+	"hotels-service-template/logging"
+	"hotels-service-template/tracing"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+//SyncKind is what a sync refreshes from EAN. Syncs of different kinds are separate jobs and may run together
+type SyncKind string
+
+const (
+	SyncRegions    SyncKind = "regions"
+	SyncProperties SyncKind = "properties"
+)
+
+var (
+	ErrSyncInProgress         = Conflict("sync_in_progress", "a region sync is already running")
+	ErrPropertySyncInProgress = Conflict("sync_in_progress", "a property sync is already running")
+)
+
+//syncInProgress is the error refusing a sync because one of its kind is running
+var syncInProgress = map[SyncKind]error{
+	SyncRegions:    ErrSyncInProgress,
+	SyncProperties: ErrPropertySyncInProgress,
+}
+
+//SyncJob is one run of a sync, as reported by the sync status endpoint
+type SyncJob struct {
+	Id                  int64       `json:"id"`
+	Kind                SyncKind    `json:"kind"`
+	State               string      `json:"state"`
+	PagesFetched        int         `json:"pages_fetched"`
+	RegionsProcessed    int         `json:"regions_processed"`
+	PropertiesProcessed int         `json:"properties_processed"`
+	Summary             SyncSummary `json:"summary"`
+	Error               string      `json:"error,omitempty"`
+	StartedAt           time.Time   `json:"started_at"`
+	FinishedAt          *time.Time  `json:"finished_at,omitempty"`
+}
+
+type SyncServiceInt interface {
+	Start() (SyncJob, error)
+	Run() (SyncJob, error)
+	Job(id int64) (SyncJob, error)
+	LastSuccess(ctx context.Context) (SyncJob, error)
+}
+
+//updater syncs what a kind of sync refreshes, regionService for regions and propertyService for properties
+type updater interface {
+	Update(ctx context.Context, progress func(SyncProgress)) (SyncSummary, error)
+}
+
+//syncService runs the syncs of one kind as jobs whose progress is stored, allowing only one sync at a time. A
+//running flag guards against concurrent syncs in this process and an advisory lock against syncs on other
+//replicas. Syncs run under the service context, which Stop cancels
+type syncService struct {
+	kind    SyncKind
+	updater updater
+	jobs    syncJobRepositoryInt
+	running int32
+	ctx     context.Context
+	cancel  context.CancelFunc
+	syncs   sync.WaitGroup
+	logger  *slog.Logger
+}
+
+//NewSyncService creates the service running syncs of kind with updater. jobs must record jobs of the same kind
+func NewSyncService(kind SyncKind, updater updater, jobs syncJobRepositoryInt, logger *slog.Logger) *syncService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &syncService{
+		kind:    kind,
+		updater: updater,
+		jobs:    jobs,
+		ctx:     ctx,
+		cancel:  cancel,
+		logger:  logger,
+	}
+}
+
+//Stop cancels the sync in progress, if any, and waits for its job to be recorded. Syncs started afterwards fail
+func (s *syncService) Stop() {
+	s.cancel()
+	s.syncs.Wait()
+}
+
+//Start begins a sync in the background and returns its job straight away
+func (s *syncService) Start() (SyncJob, error) {
+	job, unlock, err := s.begin()
+	if err != nil {
+		return SyncJob{}, err
+	}
+	go func() {
+		defer unlock()
+		_, _ = s.run(job)
+	}()
+	return job, nil
+}
+
+//Run syncs in the foreground and returns the finished job, with the sync error if it failed
+func (s *syncService) Run() (SyncJob, error) {
+	job, unlock, err := s.begin()
+	if err != nil {
+		return SyncJob{}, err
+	}
+	defer unlock()
+	return s.run(job)
+}
+
+func (s *syncService) Job(id int64) (SyncJob, error) {
+	job, err := s.jobs.get(id)
+	return job, notFound(err, "sync_job_not_found", "sync job %d does not exist", id)
+}
+
+//LastSuccess returns the job of the latest successful sync, a not found error when its kind was never synced
+func (s *syncService) LastSuccess(ctx context.Context) (SyncJob, error) {
+	job, err := s.jobs.lastSucceeded(ctx)
+	return job, notFound(err, "no_successful_sync", "%s were never synced successfully", s.kind)
+}
+
+//begin claims the right to sync and records a running job, returning the function that gives the right back. Jobs
+//left running while nobody holds the lock belong to a process that died and can never finish, so they are marked
+//failed first
+func (s *syncService) begin() (SyncJob, func(), error) {
+	if err := s.ctx.Err(); err != nil {
+		return SyncJob{}, nil, err
+	}
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return SyncJob{}, nil, syncInProgress[s.kind]
+	}
+	unlock, acquired, err := s.jobs.tryLock()
+	if err != nil || !acquired {
+		atomic.StoreInt32(&s.running, 0)
+		if err == nil {
+			err = syncInProgress[s.kind]
+		}
+		return SyncJob{}, nil, err
+	}
+	s.syncs.Add(1)
+	release := func() {
+		unlock()
+		atomic.StoreInt32(&s.running, 0)
+		s.syncs.Done()
+	}
+	err = s.jobs.failRunning("interrupted before finishing")
+	if err != nil {
+		release()
+		return SyncJob{}, nil, err
+	}
+	job, err := s.jobs.create()
+	if err != nil {
+		release()
+		return SyncJob{}, nil, err
+	}
+	return job, release, nil
+}
+
+//run syncs and records the outcome on job, returning the sync error if it failed. Lines logged during the sync
+//carry the job id, and the sync is traced as a trace of its own whatever started it
+func (s *syncService) run(job SyncJob) (SyncJob, error) {
+	ctx, span := tracing.Start(s.ctx, tracing.Internal, "sync")
+	defer span.End()
+	span.SetAttribute("sync_job", job.Id)
+	span.SetAttribute("sync_kind", string(s.kind))
+	ctx = logging.WithAttrs(ctx, slog.Int64("sync_job", job.Id), slog.String("sync_kind", string(s.kind)))
+	s.logger.InfoContext(ctx, "sync started")
+	summary, err := s.updater.Update(ctx, func(progress SyncProgress) {
+		job.PagesFetched = progress.PagesFetched
+		job.RegionsProcessed = progress.RegionsProcessed
+		job.PropertiesProcessed = progress.PropertiesProcessed
+		s.save(ctx, job)
+	})
+	finishedAt := now()
+	job.Summary = summary
+	job.FinishedAt = &finishedAt
+	job.State = JobSucceeded
+	if err != nil {
+		job.State = JobFailed
+		job.Error = err.Error()
+		s.logger.ErrorContext(ctx, "sync failed", "error", err)
+	} else {
+		lastSyncSuccess.Set(float64(finishedAt.Unix()), string(s.kind))
+		s.logger.InfoContext(ctx, "sync succeeded", "added", summary.Added, "changed", summary.Changed,
+			"removed", summary.Removed, "duration", finishedAt.Sub(job.StartedAt))
+	}
+	syncs.Inc(string(s.kind), job.State)
+	span.SetError(err)
+	s.save(ctx, job)
+	return job, err
+}
+
+//save records the progress of job. A failure is only logged, it must not fail the sync itself
+func (s *syncService) save(ctx context.Context, job SyncJob) {
+	if err := s.jobs.save(job); err != nil {
+		s.logger.WarnContext(ctx, "sync job not saved", "error", err)
+	}
+}
@@ -0,0 +1,90 @@
+package hotel
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"sync/atomic"
+)
+
+type mockRegionUpdater struct {
+	mock.Mock
+	progress      []SyncProgress
+	waitForCancel bool
+}
+
+//Update reports the progress set on the mock before returning what was given to On. With waitForCancel it blocks
+//until ctx is cancelled and returns the context error instead
+func (m *mockRegionUpdater) Update(ctx context.Context, progress func(SyncProgress)) (SyncSummary, error) {
+	args := m.Called()
+	for _, p := range m.progress {
+		progress(p)
+	}
+	if m.waitForCancel {
+		<-ctx.Done()
+		return SyncSummary{}, ctx.Err()
+	}
+	if args[1] != nil {
+		return args[0].(SyncSummary), args[1].(error)
+	}
+	return args[0].(SyncSummary), nil
+}
+
+type mockSyncJobRepository struct {
+	mock.Mock
+	unlocks int32
+}
+
+func (m *mockSyncJobRepository) create() (SyncJob, error) {
+	args := m.Called()
+	if args[1] != nil {
+		return args[0].(SyncJob), args[1].(error)
+	}
+	return args[0].(SyncJob), nil
+}
+
+func (m *mockSyncJobRepository) save(job SyncJob) error {
+	args := m.Called(job)
+	if args[0] != nil {
+		return args[0].(error)
+	}
+	return nil
+}
+
+func (m *mockSyncJobRepository) get(id int64) (SyncJob, error) {
+	args := m.Called(id)
+	if args[1] != nil {
+		return args[0].(SyncJob), args[1].(error)
+	}
+	return args[0].(SyncJob), nil
+}
+
+func (m *mockSyncJobRepository) lastSucceeded(ctx context.Context) (SyncJob, error) {
+	args := m.Called()
+	if args[1] != nil {
+		return args[0].(SyncJob), args[1].(error)
+	}
+	return args[0].(SyncJob), nil
+}
+
+func (m *mockSyncJobRepository) failRunning(reason string) error {
+	args := m.Called(reason)
+	if args[0] != nil {
+		return args[0].(error)
+	}
+	return nil
+}
+
+//tryLock returns an unlock function counting its calls in unlocks
+func (m *mockSyncJobRepository) tryLock() (func(), bool, error) {
+	args := m.Called()
+	unlock := func() {
+		atomic.AddInt32(&m.unlocks, 1)
+	}
+	if args[1] != nil {
+		return nil, false, args[1].(error)
+	}
+	if !args[0].(bool) {
+		return nil, false, nil
+	}
+	return unlock, true, nil
+}
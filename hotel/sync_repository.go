@@ -0,0 +1,114 @@
+package hotel
+
+import (
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+)
+
+type syncJobRepositoryInt interface {
+	create() (SyncJob, error)
+	save(job SyncJob) error
+	get(id int64) (SyncJob, error)
+	lastSucceeded(ctx context.Context) (SyncJob, error)
+	failRunning(reason string) error
+	tryLock() (unlock func(), acquired bool, err error)
+}
+
+//syncJobRepository records the jobs of one kind of sync
+type syncJobRepository struct {
+	db   *sql.DB
+	kind SyncKind
+}
+
+func NewSyncJobRepository(db *sql.DB, kind SyncKind) syncJobRepository {
+	return syncJobRepository{
+		db:   db,
+		kind: kind,
+	}
+}
+
+//syncLockKeys identify the Postgres advisory lock held by the replica running a sync of each kind
+var syncLockKeys = map[SyncKind]int64{
+	SyncRegions:    7295401,
+	SyncProperties: 7295403,
+}
+
+//uniqueViolation is the Postgres error code raised when a second running job hits sync_jobs_running_idx
+const uniqueViolation = "23505"
+
+func (repository syncJobRepository) create() (SyncJob, error) {
+	job := SyncJob{Kind: repository.kind, State: JobRunning}
+	query := `insert into sync_jobs (kind, state) values ($1, $2) returning id, started_at`
+	err := repository.db.QueryRow(query, job.Kind, job.State).Scan(&job.Id, &job.StartedAt)
+	if err, ok := err.(*pq.Error); ok && err.Code == uniqueViolation {
+		return SyncJob{}, syncInProgress[repository.kind]
+	}
+	if err != nil {
+		return SyncJob{}, err
+	}
+	return job, nil
+}
+
+func (repository syncJobRepository) save(job SyncJob) error {
+	query := `update sync_jobs set state = $2, pages_fetched = $3, regions_processed = $4, properties_processed = $5,
+		added = $6, changed = $7, removed = $8, error = nullif($9, ''), finished_at = $10 where id = $1`
+	_, err := repository.db.Exec(query, job.Id, job.State, job.PagesFetched, job.RegionsProcessed,
+		job.PropertiesProcessed, job.Summary.Added, job.Summary.Changed, job.Summary.Removed, job.Error, job.FinishedAt)
+	return err
+}
+
+//jobColumns are the sync_jobs columns scanJob reads
+const jobColumns = `id, kind, state, pages_fetched, regions_processed, properties_processed, added, changed,
+	removed, coalesce(error, ''), started_at, finished_at`
+
+func scanJob(row *sql.Row) (SyncJob, error) {
+	var job SyncJob
+	err := row.Scan(&job.Id, &job.Kind, &job.State, &job.PagesFetched, &job.RegionsProcessed,
+		&job.PropertiesProcessed, &job.Summary.Added, &job.Summary.Changed, &job.Summary.Removed, &job.Error,
+		&job.StartedAt, &job.FinishedAt)
+	if err != nil {
+		return SyncJob{}, err
+	}
+	return job, nil
+}
+
+//get returns any job, whatever its kind, so one status endpoint serves them all
+func (repository syncJobRepository) get(id int64) (SyncJob, error) {
+	return scanJob(repository.db.QueryRow(`select `+jobColumns+` from sync_jobs where id = $1`, id))
+}
+
+//lastSucceeded returns the job of the latest successful sync, sql.ErrNoRows when there was none
+func (repository syncJobRepository) lastSucceeded(ctx context.Context) (SyncJob, error) {
+	query := `select ` + jobColumns + ` from sync_jobs where kind = $1 and state = $2 order by finished_at desc
+		limit 1`
+	return scanJob(repository.db.QueryRowContext(ctx, query, repository.kind, JobSucceeded))
+}
+
+//failRunning marks jobs still recorded as running as failed with reason
+func (repository syncJobRepository) failRunning(reason string) error {
+	query := `update sync_jobs set state = $1, error = $2, finished_at = now() where kind = $3 and state = $4`
+	_, err := repository.db.Exec(query, JobFailed, reason, repository.kind, JobRunning)
+	return err
+}
+
+//tryLock takes the advisory lock of the sync kind without waiting. Advisory locks belong to a session, so the lock
+//is taken on a dedicated connection that is kept out of the pool until unlock releases it
+func (repository syncJobRepository) tryLock() (func(), bool, error) {
+	ctx := context.Background()
+	conn, err := repository.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	var acquired bool
+	err = conn.QueryRowContext(ctx, `select pg_try_advisory_lock($1)`, syncLockKeys[repository.kind]).Scan(&acquired)
+	if err != nil || !acquired {
+		conn.Close()
+		return nil, false, err
+	}
+	unlock := func() {
+		_, _ = conn.ExecContext(ctx, `select pg_advisory_unlock($1)`, syncLockKeys[repository.kind])
+		conn.Close()
+	}
+	return unlock, true, nil
+}
@@ -0,0 +1,155 @@
+package hotel
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCreateSyncJob(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewSyncJobRepository(db, SyncRegions)
+	startedAt := time.Unix(1559215747, 0)
+
+	mock.ExpectQuery("insert into sync_jobs").WithArgs(SyncRegions, JobRunning).
+		WillReturnRows(mock.NewRows([]string{"id", "started_at"}).AddRow(7, startedAt))
+
+	job, err := repo.create()
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, SyncJob{Id: 7, Kind: SyncRegions, State: JobRunning, StartedAt: startedAt}, job)
+}
+
+func TestCreateSyncJobShouldReturnSyncInProgressOnUniqueViolation(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewSyncJobRepository(db, SyncProperties)
+
+	mock.ExpectQuery("insert into sync_jobs").WithArgs(SyncProperties, JobRunning).
+		WillReturnError(&pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"})
+
+	job, err := repo.create()
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, ErrPropertySyncInProgress, err)
+	assert.Equal(t, SyncJob{}, job)
+}
+
+func TestSaveSyncJob(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewSyncJobRepository(db, SyncRegions)
+	finishedAt := time.Unix(1559215747, 0)
+	job := SyncJob{Id: 7, Kind: SyncRegions, State: JobFailed, PagesFetched: 2, RegionsProcessed: 300,
+		Summary: SyncSummary{Added: 1, Changed: 2, Removed: 3}, Error: "client error", FinishedAt: &finishedAt}
+
+	mock.ExpectExec("update sync_jobs set state").
+		WithArgs(int64(7), JobFailed, 2, 300, 0, 1, 2, 3, "client error", &finishedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.save(job)
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSyncJob(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewSyncJobRepository(db, SyncRegions)
+	startedAt := time.Unix(1559215700, 0)
+
+	columns := []string{"id", "kind", "state", "pages_fetched", "regions_processed", "properties_processed", "added",
+		"changed", "removed", "error", "started_at", "finished_at"}
+	mock.ExpectQuery("select id, kind, state, pages_fetched").WithArgs(int64(7)).
+		WillReturnRows(mock.NewRows(columns).AddRow(7, SyncRegions, JobRunning, 2, 300, 0, 1, 0, 0, "", startedAt, nil))
+
+	job, err := repo.get(7)
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, SyncJob{Id: 7, Kind: SyncRegions, State: JobRunning, PagesFetched: 2, RegionsProcessed: 300,
+		Summary: SyncSummary{Added: 1}, StartedAt: startedAt}, job)
+}
+
+func TestGetSyncJobShouldReturnNoRowsError(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewSyncJobRepository(db, SyncRegions)
+
+	mock.ExpectQuery("select id, kind, state, pages_fetched").WithArgs(int64(7)).
+		WillReturnRows(mock.NewRows([]string{"id"}))
+
+	job, err := repo.get(7)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, SyncJob{}, job)
+	assert.EqualError(t, err, "sql: no rows in result set")
+}
+
+func TestFailRunningSyncJobs(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewSyncJobRepository(db, SyncRegions)
+
+	mock.ExpectExec("update sync_jobs set state").WithArgs(JobFailed, "interrupted", SyncRegions, JobRunning).
+		WillReturnError(errors.New("exec error"))
+
+	err := repo.failRunning("interrupted")
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.EqualError(t, err, "exec error")
+}
+
+func TestTryLockShouldHoldTheLockUntilUnlocked(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewSyncJobRepository(db, SyncRegions)
+
+	mock.ExpectQuery("select pg_try_advisory_lock").WithArgs(syncLockKeys[SyncRegions]).
+		WillReturnRows(mock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	mock.ExpectExec("select pg_advisory_unlock").WithArgs(syncLockKeys[SyncRegions]).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	unlock, acquired, err := repo.tryLock()
+	assert.Nil(t, err)
+	assert.True(t, acquired)
+
+	unlock()
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestTryLockShouldReportLockHeldElsewhere(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewSyncJobRepository(db, SyncRegions)
+
+	mock.ExpectQuery("select pg_try_advisory_lock").WithArgs(syncLockKeys[SyncRegions]).
+		WillReturnRows(mock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+
+	unlock, acquired, err := repo.tryLock()
+
+	assert.Nil(t, err)
+	assert.False(t, acquired)
+	assert.Nil(t, unlock)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestLastSucceededSyncJob(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	repo := NewSyncJobRepository(db, SyncRegions)
+	startedAt := time.Unix(1559215700, 0)
+	finishedAt := time.Unix(1559215747, 0)
+
+	columns := []string{"id", "kind", "state", "pages_fetched", "regions_processed", "properties_processed", "added",
+		"changed", "removed", "error", "started_at", "finished_at"}
+	mock.ExpectQuery("select id, kind, state, pages_fetched.* where kind = .* order by finished_at desc").
+		WithArgs(SyncRegions, JobSucceeded).
+		WillReturnRows(mock.NewRows(columns).
+			AddRow(7, SyncRegions, JobSucceeded, 2, 300, 0, 1, 0, 0, "", startedAt, finishedAt))
+
+	job, err := repo.lastSucceeded(context.Background())
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, int64(7), job.Id)
+	assert.Equal(t, &finishedAt, job.FinishedAt)
+}
@@ -0,0 +1,184 @@
+package hotel
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"hotels-service-template/logging"
+	"testing"
+	"time"
+)
+
+type SyncServiceTestSuite struct {
+	suite.Suite
+	regions *mockRegionUpdater
+	jobs    *mockSyncJobRepository
+	service *syncService
+}
+
+func (s *SyncServiceTestSuite) SetupTest() {
+	s.regions = &mockRegionUpdater{}
+	s.jobs = &mockSyncJobRepository{}
+	s.jobs.On("tryLock").Return(true, nil).Maybe()
+	s.service = NewSyncService(SyncRegions, s.regions, s.jobs, logging.Discard())
+	now = func() time.Time {
+		return time.Unix(1559215747, 0)
+	}
+}
+
+func TestSyncServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(SyncServiceTestSuite))
+}
+
+func (s *SyncServiceTestSuite) TestRunShouldRecordProgressAndSummary() {
+	startedAt := time.Unix(1559215700, 0)
+	finishedAt := time.Unix(1559215747, 0)
+	job := SyncJob{Id: 7, State: JobRunning, StartedAt: startedAt}
+	summary := SyncSummary{Added: 2, Changed: 1}
+	s.regions.progress = []SyncProgress{{PagesFetched: 1, RegionsProcessed: 3}}
+	inProgress := SyncJob{Id: 7, State: JobRunning, StartedAt: startedAt, PagesFetched: 1, RegionsProcessed: 3}
+	finished := SyncJob{Id: 7, State: JobSucceeded, StartedAt: startedAt, PagesFetched: 1, RegionsProcessed: 3,
+		Summary: summary, FinishedAt: &finishedAt}
+
+	s.jobs.On("failRunning", "interrupted before finishing").Return(nil)
+	s.jobs.On("create").Return(job, nil)
+	s.jobs.On("save", inProgress).Times(1).Return(nil)
+	s.jobs.On("save", finished).Times(1).Return(nil)
+	s.regions.On("Update").Return(summary, nil)
+
+	obtained, err := s.service.Run()
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), finished, obtained)
+	s.jobs.AssertExpectations(s.T())
+	s.regions.AssertExpectations(s.T())
+	assert.Equal(s.T(), int32(1), s.jobs.unlocks)
+}
+
+func (s *SyncServiceTestSuite) TestRunShouldRecordFailure() {
+	job := SyncJob{Id: 7, State: JobRunning}
+	s.jobs.On("failRunning", mock.Anything).Return(nil)
+	s.jobs.On("create").Return(job, nil)
+	s.jobs.On("save", mock.MatchedBy(func(job SyncJob) bool {
+		return job.State == JobFailed && job.Error == "client error" && job.FinishedAt != nil
+	})).Times(1).Return(nil)
+	s.regions.On("Update").Return(SyncSummary{}, errors.New("client error"))
+
+	obtained, err := s.service.Run()
+
+	assert.EqualError(s.T(), err, "client error")
+	assert.Equal(s.T(), JobFailed, obtained.State)
+	s.jobs.AssertExpectations(s.T())
+}
+
+func (s *SyncServiceTestSuite) TestRunShouldReturnCreateError() {
+	s.jobs.On("failRunning", mock.Anything).Return(nil)
+	s.jobs.On("create").Return(SyncJob{}, ErrSyncInProgress)
+
+	_, err := s.service.Run()
+
+	assert.Equal(s.T(), ErrSyncInProgress, err)
+	s.regions.AssertNotCalled(s.T(), "Update")
+	assert.Equal(s.T(), int32(0), s.service.running, "a failed start should release the sync")
+	assert.Equal(s.T(), int32(1), s.jobs.unlocks, "a failed start should release the lock")
+}
+
+func (s *SyncServiceTestSuite) TestRunShouldNotSyncWhileAnotherReplicaHoldsTheLock() {
+	s.jobs = &mockSyncJobRepository{}
+	s.service = NewSyncService(SyncRegions, s.regions, s.jobs, logging.Discard())
+	s.jobs.On("tryLock").Return(false, nil)
+
+	_, err := s.service.Run()
+
+	assert.Equal(s.T(), ErrSyncInProgress, err)
+	s.jobs.AssertNotCalled(s.T(), "failRunning", mock.Anything)
+	s.regions.AssertNotCalled(s.T(), "Update")
+	assert.Equal(s.T(), int32(0), s.service.running)
+}
+
+func (s *SyncServiceTestSuite) TestRunShouldReportTheSyncOfItsKindInProgress() {
+	s.jobs = &mockSyncJobRepository{}
+	s.service = NewSyncService(SyncProperties, s.regions, s.jobs, logging.Discard())
+	s.jobs.On("tryLock").Return(false, nil)
+
+	_, err := s.service.Run()
+
+	assert.Equal(s.T(), ErrPropertySyncInProgress, err)
+	assert.EqualError(s.T(), err, "a property sync is already running")
+}
+
+func (s *SyncServiceTestSuite) TestRunShouldReturnLockError() {
+	s.jobs = &mockSyncJobRepository{}
+	s.service = NewSyncService(SyncRegions, s.regions, s.jobs, logging.Discard())
+	s.jobs.On("tryLock").Return(false, errors.New("connection refused"))
+
+	_, err := s.service.Run()
+
+	assert.EqualError(s.T(), err, "connection refused")
+	s.jobs.AssertNotCalled(s.T(), "create")
+	assert.Equal(s.T(), int32(0), s.service.running)
+}
+
+func (s *SyncServiceTestSuite) TestStartShouldRejectSecondSync() {
+	release := make(chan time.Time)
+	done := make(chan struct{})
+	job := SyncJob{Id: 7, State: JobRunning}
+	s.jobs.On("failRunning", mock.Anything).Return(nil)
+	s.jobs.On("create").Return(job, nil)
+	s.jobs.On("save", mock.Anything).Return(nil).Run(func(mock.Arguments) {
+		close(done)
+	})
+	s.regions.On("Update").Return(SyncSummary{}, nil).WaitUntil(release)
+
+	started, err := s.service.Start()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), job, started)
+
+	_, err = s.service.Start()
+	assert.Equal(s.T(), ErrSyncInProgress, err)
+
+	close(release)
+	<-done
+}
+
+func (s *SyncServiceTestSuite) TestStopShouldCancelSyncInProgress() {
+	job := SyncJob{Id: 7, State: JobRunning}
+	s.regions.waitForCancel = true
+	s.jobs.On("failRunning", mock.Anything).Return(nil)
+	s.jobs.On("create").Return(job, nil)
+	s.jobs.On("save", mock.MatchedBy(func(job SyncJob) bool {
+		return job.State == JobFailed && job.Error == "context canceled"
+	})).Times(1).Return(nil)
+	s.regions.On("Update").Return(SyncSummary{}, nil)
+
+	_, err := s.service.Start()
+	assert.NoError(s.T(), err)
+
+	s.service.Stop()
+
+	s.jobs.AssertExpectations(s.T())
+	assert.Equal(s.T(), int32(1), s.jobs.unlocks)
+	_, err = s.service.Run()
+	assert.Equal(s.T(), context.Canceled, err)
+}
+
+func (s *SyncServiceTestSuite) TestJob() {
+	job := SyncJob{Id: 7, State: JobSucceeded}
+	s.jobs.On("get", int64(7)).Return(job, nil)
+
+	obtained, err := s.service.Job(7)
+
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), job, obtained)
+}
+
+func (s *SyncServiceTestSuite) TestLastSuccessShouldBeNotFoundWithoutSuccessfulSync() {
+	s.jobs.On("lastSucceeded").Return(SyncJob{}, sql.ErrNoRows)
+
+	_, err := s.service.LastSuccess(context.Background())
+
+	assert.Equal(s.T(), KindNotFound, AsError(err).Kind)
+}
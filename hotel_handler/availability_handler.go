@@ -0,0 +1,61 @@
+package hotel_handler
+
+import (
+	"encoding/json"
+	"hotels-service-template/hotel"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type AvailabilityHandlerInt interface {
+	Availability(w http.ResponseWriter, r *http.Request)
+}
+
+type AvailabilityHandler struct {
+	service hotel.AvailabilityServiceInt
+	logger  *slog.Logger
+}
+
+func NewAvailabilityHandler(availabilityService hotel.AvailabilityServiceInt,
+	logger *slog.Logger) *AvailabilityHandler {
+	return &AvailabilityHandler{
+		service: availabilityService,
+		logger:  logger,
+	}
+}
+
+//Availability returns the rates of the properties in region_id for a stay from checkin to checkout, for one room
+//per occupancy given, cheapest property first
+func (h *AvailabilityHandler) Availability(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if err := validate(query, availabilityParams); err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	var invalid []hotel.InvalidParam
+	date := func(name string) time.Time {
+		parsed, err := time.Parse(hotel.DateLayout, query.Get(name))
+		if err != nil {
+			invalid = append(invalid, hotel.InvalidParam{Name: name, Reason: "must be a valid date"})
+		}
+		return parsed
+	}
+	stay := hotel.Stay{
+		CheckIn:            date("checkin"),
+		CheckOut:           date("checkout"),
+		Occupancy:          query["occupancy"],
+		Currency:           query.Get("currency"),
+		CountryOfResidence: query.Get("country_of_residence"),
+	}
+	if len(invalid) > 0 {
+		handleError(h.logger, hotel.InvalidParams(invalid), w, r)
+		return
+	}
+	availabilities, err := h.service.Availability(r.Context(), query.Get("region_id"), stay)
+	if err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(availabilities)
+}
@@ -0,0 +1,69 @@
+package hotel_handler_test
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	. "hotels-service-template/hotel"
+	"hotels-service-template/hotel_handler"
+	"hotels-service-template/logging"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAvailability(t *testing.T) {
+	stay := Stay{CheckIn: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+		CheckOut: time.Date(2026, 11, 3, 0, 0, 0, 0, time.UTC), Occupancy: []string{"2", "2-9,4"}, Currency: "EUR",
+		CountryOfResidence: "FR"}
+	availabilities := []PropertyAvailability{{PropertyId: "12345", Rates: []Rate{{RoomId: "1", RateId: "2",
+		Total: Amount{Value: "240.00", Currency: "EUR"}, BedGroups: []BedGroup{{Id: "37321", Token: "t"}}}}}}
+	valid := "?region_id=6023&checkin=2026-11-01&checkout=2026-11-03&occupancy=2&occupancy=2-9,4&currency=EUR" +
+		"&country_of_residence=FR"
+
+	tt := []struct {
+		testDescription  string
+		query            string
+		mockCalled       bool
+		mockError        error
+		expectedStatus   int
+		expectedResponse *bytes.Buffer
+	}{
+		{"ShouldReturnRates", valid, true, nil, 200, encode(availabilities)},
+		{"ShouldRequireTheStay", "?region_id=6023&checkin=1st&occupancy=2&occupancy=two&currency=eur", false, nil,
+			400, invalidParams("/availability",
+				InvalidParam{Name: "checkin", Reason: "may only contain a date written as YYYY-MM-DD"},
+				InvalidParam{Name: "checkout", Reason: "is required"},
+				InvalidParam{Name: "occupancy", Reason: "may only contain the number of adults, followed by a dash " +
+					"and the comma separated ages of children if any"},
+				InvalidParam{Name: "currency", Reason: "may only contain a three letter currency code"},
+				InvalidParam{Name: "country_of_residence", Reason: "is required"})},
+		{"ShouldRejectImpossibleDates", "?region_id=6023&checkin=2026-02-30&checkout=2026-13-01&occupancy=2" +
+			"&currency=EUR&country_of_residence=FR", false, nil, 400, invalidParams("/availability",
+			InvalidParam{Name: "checkin", Reason: "must be a valid date"},
+			InvalidParam{Name: "checkout", Reason: "must be a valid date"})},
+		{"ShouldReturnNotFound", valid, true, NotFound("region_not_found", "region 6023 does not exist"), 404,
+			problem(404, "region_not_found", "region 6023 does not exist", "/availability")},
+		{"ShouldReturnBadGateway", valid, true, &APIError{StatusCode: 503}, 502,
+			problem(502, "upstream_unavailable", "EAN is unavailable", "/availability")},
+		{"ShouldHideInternalErrors", valid, true, errors.New("boom"), 500,
+			problem(500, "internal_error", "internal error", "/availability")},
+	}
+	for _, tc := range tt {
+		t.Run(tc.testDescription, func(t *testing.T) {
+			service := &hotel_handler.MockAvailabilityService{}
+			handler := hotel_handler.NewAvailabilityHandler(service, logging.Discard())
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/availability"+tc.query, nil)
+			if tc.mockCalled {
+				service.On("Availability", "6023", stay).Times(1).Return(availabilities, tc.mockError)
+			}
+
+			handler.Availability(rr, req)
+
+			service.AssertExpectations(t)
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, tc.expectedResponse, rr.Body)
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package hotel_handler
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"hotels-service-template/hotel"
+)
+
+type MockAvailabilityService struct {
+	mock.Mock
+}
+
+func (m *MockAvailabilityService) Availability(ctx context.Context, regionId string,
+	stay hotel.Stay) ([]hotel.PropertyAvailability, error) {
+	args := m.Called(regionId, stay)
+	if args[1] != nil {
+		return nil, args[1].(error)
+	}
+	return args[0].([]hotel.PropertyAvailability), nil
+}
@@ -0,0 +1,118 @@
+package hotel_handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"hotels-service-template/auth"
+	"hotels-service-template/hotel"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	//IdempotencyKeyHeader names the header a booking is sent with, the same key booking it once however often it
+	//is sent
+	IdempotencyKeyHeader = "Idempotency-Key"
+	maxIdempotencyKey    = 255
+	//maxBookingBody bounds the body of a booking request
+	maxBookingBody = 64 << 10
+)
+
+type BookingHandlerInt interface {
+	Book(w http.ResponseWriter, r *http.Request)
+	Itinerary(w http.ResponseWriter, r *http.Request)
+	Cancel(w http.ResponseWriter, r *http.Request)
+}
+
+type BookingHandler struct {
+	service hotel.BookingServiceInt
+	logger  *slog.Logger
+}
+
+func NewBookingHandler(bookingService hotel.BookingServiceInt, logger *slog.Logger) *BookingHandler {
+	return &BookingHandler{
+		service: bookingService,
+		logger:  logger,
+	}
+}
+
+//Book books the rate of the request body, answering 201 with the new itinerary, or 200 with the itinerary booked
+//before when the Idempotency-Key was already used for the same request
+func (h *BookingHandler) Book(w http.ResponseWriter, r *http.Request) {
+	key, ok := auth.KeyFrom(r.Context())
+	if !ok {
+		handleError(h.logger, auth.ErrMissingCredentials, w, r)
+		return
+	}
+	idempotencyKey := r.Header.Get(IdempotencyKeyHeader)
+	if strings.TrimSpace(idempotencyKey) == "" || len(idempotencyKey) > maxIdempotencyKey {
+		handleError(h.logger, hotel.InvalidInput("invalid_idempotency_key",
+			"the %s header is required and must be at most %d characters", IdempotencyKeyHeader, maxIdempotencyKey),
+			w, r)
+		return
+	}
+	var request hotel.BookingRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBookingBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		//the decoding error may quote customer details, so it is not passed on
+		handleError(h.logger, hotel.InvalidInput("invalid_body", "the body must be a booking request in JSON"), w, r)
+		return
+	}
+	itinerary, created, err := h.service.Book(r.Context(), key.Id, idempotencyKey, request)
+	if err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/bookings/%d", itinerary.Id))
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	_ = json.NewEncoder(w).Encode(itinerary)
+}
+
+//Itinerary returns the itinerary given in the path, as booked with the key of the request
+func (h *BookingHandler) Itinerary(w http.ResponseWriter, r *http.Request) {
+	key, id, ok := h.itineraryParams(w, r)
+	if !ok {
+		return
+	}
+	itinerary, err := h.service.Itinerary(r.Context(), key.Id, id)
+	if err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(itinerary)
+}
+
+//Cancel cancels the itinerary given in the path and returns it cancelled
+func (h *BookingHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	key, id, ok := h.itineraryParams(w, r)
+	if !ok {
+		return
+	}
+	itinerary, err := h.service.Cancel(r.Context(), key.Id, id)
+	if err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(itinerary)
+}
+
+//itineraryParams returns the key of the request and the itinerary id in the path, or writes why it cannot
+func (h *BookingHandler) itineraryParams(w http.ResponseWriter, r *http.Request) (auth.Key, int64, bool) {
+	key, ok := auth.KeyFrom(r.Context())
+	if !ok {
+		handleError(h.logger, auth.ErrMissingCredentials, w, r)
+		return auth.Key{}, 0, false
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		handleError(h.logger, hotel.InvalidInput("invalid_parameter", "id must be a number"), w, r)
+		return auth.Key{}, 0, false
+	}
+	return key, id, true
+}
@@ -0,0 +1,140 @@
+package hotel_handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"hotels-service-template/auth"
+	. "hotels-service-template/hotel"
+	"hotels-service-template/hotel_handler"
+	"hotels-service-template/logging"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func bookingRequest() BookingRequest {
+	return BookingRequest{PropertyId: "12345", RoomId: "1234501", RateId: "123450101", Token: "sim-token",
+		Total: Amount{Value: "740.00", Currency: "EUR"}, Email: "jane.doe@example.com",
+		Phone: Phone{CountryCode: "1", Number: "5550077"}, Rooms: []Guest{{GivenName: "Jane", FamilyName: "Doe"}},
+		Payment: Payment{Number: "4111111111111111", SecurityCode: "737", ExpirationMonth: "08",
+			ExpirationYear: "2028", BillingContact: BillingContact{GivenName: "Jane", FamilyName: "Doe",
+				Address: Address{Line1: "555 1st St", City: "Seattle", CountryCode: "US"}}}}
+}
+
+//withKey makes request as if authenticated with a book key of id 7
+func withKey(request *http.Request) *http.Request {
+	return request.WithContext(auth.WithKey(request.Context(), auth.Key{Id: 7, Role: auth.RoleBook}))
+}
+
+func TestBook(t *testing.T) {
+	body, _ := json.Marshal(bookingRequest())
+	itinerary := Itinerary{Id: 1, State: ItineraryConfirmed, PropertyId: "12345", EANItineraryId: "8955599932111"}
+
+	tt := []struct {
+		testDescription  string
+		idempotencyKey   string
+		body             string
+		mockCalled       bool
+		mockCreated      bool
+		mockError        error
+		expectedStatus   int
+		expectedResponse *bytes.Buffer
+	}{
+		{"ShouldCreateTheItinerary", "key-1", string(body), true, true, nil, 201, encode(itinerary)},
+		{"ShouldReplayTheItinerary", "key-1", string(body), true, false, nil, 200, encode(itinerary)},
+		{"ShouldRequireAnIdempotencyKey", "", string(body), false, false, nil, 400,
+			problem(400, "invalid_idempotency_key", "the Idempotency-Key header is required and must be at most "+
+				"255 characters", "/bookings")},
+		{"ShouldRejectUnknownFields", "key-1", `{"property_id": "12345", "card": "4111111111111111"}`, false,
+			false, nil, 400, problem(400, "invalid_body", "the body must be a booking request in JSON", "/bookings")},
+		{"ShouldRejectTooLargeBodies", "key-1", `{"email": "` + strings.Repeat("x", 64<<10) + `"}`, false,
+			false, nil, 400, problem(400, "invalid_body", "the body must be a booking request in JSON", "/bookings")},
+		{"ShouldReturnConflict", "key-1", string(body), true, false,
+			Conflict("price_changed", "the total is now 780.00 EUR"), 409,
+			problem(409, "price_changed", "the total is now 780.00 EUR", "/bookings")},
+	}
+	for _, tc := range tt {
+		t.Run(tc.testDescription, func(t *testing.T) {
+			service := &hotel_handler.MockBookingService{}
+			handler := hotel_handler.NewBookingHandler(service, logging.Discard())
+			rr := httptest.NewRecorder()
+			req := withKey(httptest.NewRequest("POST", "/bookings", strings.NewReader(tc.body)))
+			if tc.idempotencyKey != "" {
+				req.Header.Set(hotel_handler.IdempotencyKeyHeader, tc.idempotencyKey)
+			}
+			if tc.mockCalled {
+				service.On("Book", int64(7), "key-1", bookingRequest()).Times(1).
+					Return(itinerary, tc.mockCreated, tc.mockError)
+			}
+
+			handler.Book(rr, req)
+
+			service.AssertExpectations(t)
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, tc.expectedResponse, rr.Body)
+			assert.NotContains(t, rr.Body.String(), "4111111111111111")
+			if tc.expectedStatus < 300 {
+				assert.Equal(t, "/bookings/1", rr.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestBookShouldNeedAKey(t *testing.T) {
+	service := &hotel_handler.MockBookingService{}
+	handler := hotel_handler.NewBookingHandler(service, logging.Discard())
+	rr := httptest.NewRecorder()
+
+	handler.Book(rr, httptest.NewRequest("POST", "/bookings", strings.NewReader(`{}`)))
+
+	assert.Equal(t, 401, rr.Code)
+	service.AssertNotCalled(t, "Book", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestItinerary(t *testing.T) {
+	itinerary := Itinerary{Id: 1, State: ItineraryConfirmed, Rooms: []ItineraryRoom{{Id: "926784314",
+		ConfirmationId: "1234567890", Status: "booked"}}}
+	service := &hotel_handler.MockBookingService{}
+	handler := hotel_handler.NewBookingHandler(service, logging.Discard())
+	service.On("Itinerary", int64(7), int64(1)).Return(itinerary, nil)
+	service.On("Itinerary", int64(7), int64(2)).Return(nil, NotFound("itinerary_not_found",
+		"itinerary 2 does not exist"))
+
+	found := httptest.NewRecorder()
+	handler.Itinerary(found, withKey(mux.SetURLVars(httptest.NewRequest("GET", "/bookings/1", nil),
+		map[string]string{"id": "1"})))
+	missing := httptest.NewRecorder()
+	handler.Itinerary(missing, withKey(mux.SetURLVars(httptest.NewRequest("GET", "/bookings/2", nil),
+		map[string]string{"id": "2"})))
+
+	assert.Equal(t, 200, found.Code)
+	assert.Equal(t, encode(itinerary), found.Body)
+	assert.Equal(t, 404, missing.Code)
+	assert.Equal(t, problem(404, "itinerary_not_found", "itinerary 2 does not exist", "/bookings/2"), missing.Body)
+}
+
+func TestCancel(t *testing.T) {
+	itinerary := Itinerary{Id: 1, State: ItineraryCancelled}
+	service := &hotel_handler.MockBookingService{}
+	handler := hotel_handler.NewBookingHandler(service, logging.Discard())
+	service.On("Cancel", int64(7), int64(1)).Return(itinerary, nil)
+	service.On("Cancel", int64(7), int64(2)).Return(nil, Conflict("booking_in_progress",
+		"itinerary 2 is still being booked"))
+
+	cancelled := httptest.NewRecorder()
+	handler.Cancel(cancelled, withKey(mux.SetURLVars(httptest.NewRequest("DELETE", "/bookings/1", nil),
+		map[string]string{"id": "1"})))
+	held := httptest.NewRecorder()
+	handler.Cancel(held, withKey(mux.SetURLVars(httptest.NewRequest("DELETE", "/bookings/2", nil),
+		map[string]string{"id": "2"})))
+
+	assert.Equal(t, 200, cancelled.Code)
+	assert.Equal(t, encode(itinerary), cancelled.Body)
+	assert.Equal(t, 409, held.Code)
+	assert.Equal(t, problem(409, "booking_in_progress", "itinerary 2 is still being booked", "/bookings/2"),
+		held.Body)
+}
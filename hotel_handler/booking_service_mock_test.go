@@ -0,0 +1,36 @@
+package hotel_handler
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"hotels-service-template/hotel"
+)
+
+type MockBookingService struct {
+	mock.Mock
+}
+
+func (m *MockBookingService) Book(ctx context.Context, keyId int64, idempotencyKey string,
+	request hotel.BookingRequest) (hotel.Itinerary, bool, error) {
+	args := m.Called(keyId, idempotencyKey, request)
+	if args[2] != nil {
+		return hotel.Itinerary{}, false, args[2].(error)
+	}
+	return args[0].(hotel.Itinerary), args[1].(bool), nil
+}
+
+func (m *MockBookingService) Itinerary(ctx context.Context, keyId int64, id int64) (hotel.Itinerary, error) {
+	args := m.Called(keyId, id)
+	if args[1] != nil {
+		return hotel.Itinerary{}, args[1].(error)
+	}
+	return args[0].(hotel.Itinerary), nil
+}
+
+func (m *MockBookingService) Cancel(ctx context.Context, keyId int64, id int64) (hotel.Itinerary, error) {
+	args := m.Called(keyId, id)
+	if args[1] != nil {
+		return hotel.Itinerary{}, args[1].(error)
+	}
+	return args[0].(hotel.Itinerary), nil
+}
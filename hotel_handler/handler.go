@@ -2,53 +2,151 @@ package hotel_handler
 
 import (
 	"encoding/json"
-	"fmt"
+	"github.com/gorilla/mux"
 	"hotels-service-template/hotel"
+	"log/slog"
 	"net/http"
+	"strconv"
 )
 
 type RegionHandlerInt interface {
 	Search(w http.ResponseWriter, r *http.Request)
-	Update(w http.ResponseWriter, r *http.Request)
+	Autocomplete(w http.ResponseWriter, r *http.Request)
+	Region(w http.ResponseWriter, r *http.Request)
+	Ancestors(w http.ResponseWriter, r *http.Request)
+	Descendants(w http.ResponseWriter, r *http.Request)
+	Hierarchy(w http.ResponseWriter, r *http.Request)
+	PropertyRegions(w http.ResponseWriter, r *http.Request)
 }
 
 type RegionHandler struct {
 	service hotel.RegionServiceInt
+	logger  *slog.Logger
 }
 
-type Error struct {
-	HttpStatus int
-	Message    string
-}
-
-func NewRegionHandler(regionService hotel.RegionServiceInt) *RegionHandler {
+func NewRegionHandler(regionService hotel.RegionServiceInt, logger *slog.Logger) *RegionHandler {
 	return &RegionHandler{
 		service: regionService,
+		logger:  logger,
 	}
 }
 
+//Search looks up a destination by its exact name, or returns a ranked list of matches when mode=fuzzy
 func (h *RegionHandler) Search(w http.ResponseWriter, r *http.Request) {
+	if err := validate(r.URL.Query(), searchParams); err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	if r.URL.Query().Get("mode") == "fuzzy" {
+		h.fuzzySearch(w, r)
+		return
+	}
 	destination := r.URL.Query().Get("destination")
-	region, err := h.service.Search(destination)
+	region, err := h.service.Search(r.Context(), destination)
 	if err != nil {
-		handleError(err, w, http.StatusInternalServerError)
+		handleError(h.logger, err, w, r)
 		return
 	}
 	_ = json.NewEncoder(w).Encode(region)
 }
 
-func (h *RegionHandler) Update(w http.ResponseWriter, r *http.Request) {
-	err := h.service.Update()
+func (h *RegionHandler) fuzzySearch(w http.ResponseWriter, r *http.Request) {
+	limit, err := intParam(r, "limit", hotel.DefaultSearchLimit)
+	if err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	regions, err := h.service.FuzzySearch(r.Context(), r.URL.Query().Get("destination"), limit)
+	if err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(regions)
+}
+
+//Autocomplete suggests regions for the prefix typed so far in q
+func (h *RegionHandler) Autocomplete(w http.ResponseWriter, r *http.Request) {
+	if err := validate(r.URL.Query(), autocompleteParams); err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	limit, err := intParam(r, "limit", hotel.DefaultSearchLimit)
 	if err != nil {
-		fmt.Println("***********************************************")
-		handleError(err, w, http.StatusInternalServerError)
+		handleError(h.logger, err, w, r)
 		return
 	}
-	_, _ = fmt.Fprintf(w, "update successful")
+	_ = json.NewEncoder(w).Encode(h.service.Autocomplete(r.Context(), r.URL.Query().Get("q"), limit))
 }
 
-func handleError(err error, writer http.ResponseWriter, httpStatusCode int) {
-	writer.WriteHeader(httpStatusCode)
-	_ = json.NewEncoder(writer).Encode(Error{Message: err.Error(), HttpStatus: httpStatusCode})
+func (h *RegionHandler) Region(w http.ResponseWriter, r *http.Request) {
+	region, err := h.service.Region(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(region)
+}
+
+func (h *RegionHandler) Ancestors(w http.ResponseWriter, r *http.Request) {
+	regions, err := h.service.Ancestors(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(regions)
+}
 
+//Descendants lists the descendants of a region, optionally only those of the region type given in type
+func (h *RegionHandler) Descendants(w http.ResponseWriter, r *http.Request) {
+	if err := validate(r.URL.Query(), descendantsParams); err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	regions, err := h.service.Descendants(r.Context(), mux.Vars(r)["id"], r.URL.Query().Get("type"))
+	if err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(regions)
+}
+
+//Hierarchy returns the region followed by up to depth of its ancestors, all of them when depth is not given
+func (h *RegionHandler) Hierarchy(w http.ResponseWriter, r *http.Request) {
+	if err := validate(r.URL.Query(), hierarchyParams); err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	depth, err := intParam(r, "depth", 0)
+	if err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	regions, err := h.service.Hierarchy(r.Context(), mux.Vars(r)["id"], depth)
+	if err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(regions)
+}
+
+//PropertyRegions lists the regions containing the property given in the path
+func (h *RegionHandler) PropertyRegions(w http.ResponseWriter, r *http.Request) {
+	regions, err := h.service.PropertyRegions(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(regions)
+}
+
+func intParam(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, hotel.InvalidInput("invalid_parameter", "%s must be a number", name)
+	}
+	return parsed, nil
 }
@@ -2,13 +2,18 @@ package hotel_handler_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	. "hotels-service-template/hotel"
 	"hotels-service-template/hotel_handler"
+	"hotels-service-template/logging"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -25,60 +30,274 @@ func TestRegionHandlerTestSuite(t *testing.T) {
 	suite.Run(t, new(RegionHandlerTestSuite))
 }
 
-func (s *RegionHandlerTestSuite) TestUpdate() {
-	req := httptest.NewRequest("GET", "/update", nil)
-	handler := hotel_handler.NewRegionHandler(s.service)
-	expectedErrorResponse := bytes.NewBuffer(nil)
-	_ = json.NewEncoder(expectedErrorResponse).Encode(hotel_handler.Error{HttpStatus: 500,
-		Message: "db error"})
+//problem encodes the problem body the handlers write for an error
+func problem(status int, code string, detail string, instance string) *bytes.Buffer {
+	b := bytes.NewBuffer(nil)
+	_ = json.NewEncoder(b).Encode(hotel_handler.Problem{Type: "/problems/" + code, Title: http.StatusText(status),
+		Status: status, Detail: detail, Instance: instance, Code: code})
+	return b
+}
+
+//invalidParams encodes the problem body the handlers write for a request with invalid parameters
+func invalidParams(instance string, params ...InvalidParam) *bytes.Buffer {
+	b := bytes.NewBuffer(nil)
+	_ = json.NewEncoder(b).Encode(hotel_handler.Problem{Type: "/problems/invalid_parameter", Title: "Bad Request",
+		Status: 400, Detail: "the request has invalid parameters", Instance: instance, Code: "invalid_parameter",
+		InvalidParams: params})
+	return b
+}
+
+func (s *RegionHandlerTestSuite) TestSearch() {
+	req := httptest.NewRequest("GET", "/search?destination=first", nil)
+	handler := hotel_handler.NewRegionHandler(s.service, logging.Discard())
+
+	testRegion := Region{Id: "1", Name: "first"}
+	expectedRegionResponse := bytes.NewBuffer(nil)
+	_ = json.NewEncoder(expectedRegionResponse).Encode(testRegion)
+	expectedErrorResponse := problem(500, "internal_error", "internal error", "/search")
+	expectedNotFoundResponse := problem(404, "destination_not_found", `no region is named "first"`, "/search")
 
 	tt := []struct {
 		testDescription  string
 		mockError        error
+		mockRegion       Region
 		expectedResponse *bytes.Buffer
 	}{
-		{"ShouldNotReturnError", nil, bytes.NewBuffer([]byte("update successful"))},
-		{"ShouldReturnError", errors.New("db error"), expectedErrorResponse},
+		{"ShouldNotReturnError", nil, testRegion, expectedRegionResponse},
+		{"ShouldReturnError", errors.New("error"), Region{}, expectedErrorResponse},
+		{"ShouldReturnNotFound", NotFound("destination_not_found", `no region is named "first"`), Region{},
+			expectedNotFoundResponse},
 	}
-
 	for _, tc := range tt {
 		s.T().Run(tc.testDescription, func(t *testing.T) {
 			rr := httptest.NewRecorder()
-			s.service.On("Update").Times(1).Return(tc.mockError)
-			handler.Update(rr, req)
+			s.service.On("Search", "first").Times(1).Return(tc.mockRegion, tc.mockError)
+			handler.Search(rr, req)
 			s.service.AssertExpectations(t)
 			assert.Equal(t, tc.expectedResponse, rr.Body)
 		})
 	}
 }
 
-func (s *RegionHandlerTestSuite) TestSearch() {
-	req := httptest.NewRequest("GET", "/search?destination=first", nil)
-	handler := hotel_handler.NewRegionHandler(s.service)
+func (s *RegionHandlerTestSuite) TestFuzzySearch() {
+	handler := hotel_handler.NewRegionHandler(s.service, logging.Discard())
 
-	testRegion := Region{Id: "1", Name: "first"}
+	testRegions := []Region{{Id: "2734", Name: "Paris"}, {Id: "6734", Name: "Paris Beach"}}
+	expectedRegionsResponse := bytes.NewBuffer(nil)
+	_ = json.NewEncoder(expectedRegionsResponse).Encode(testRegions)
+	expectedErrorResponse := problem(500, "internal_error", "internal error", "/search")
+	expectedLimitErrorResponse := invalidParams("/search", InvalidParam{Name: "limit", Reason: "must be a number"})
+
+	tt := []struct {
+		testDescription  string
+		target           string
+		callsService     bool
+		mockError        error
+		mockRegions      []Region
+		expectedStatus   int
+		expectedResponse *bytes.Buffer
+	}{
+		{"ShouldReturnRegions", "/search?mode=fuzzy&destination=pari&limit=2", true, nil, testRegions,
+			200, expectedRegionsResponse},
+		{"ShouldReturnError", "/search?mode=fuzzy&destination=pari&limit=2", true, errors.New("error"), nil,
+			500, expectedErrorResponse},
+		{"ShouldRejectInvalidLimit", "/search?mode=fuzzy&destination=pari&limit=two", false, nil, nil,
+			400, expectedLimitErrorResponse},
+	}
+	for _, tc := range tt {
+		s.T().Run(tc.testDescription, func(t *testing.T) {
+			service := &hotel_handler.MockRegionService{}
+			handler = hotel_handler.NewRegionHandler(service, logging.Discard())
+			rr := httptest.NewRecorder()
+			if tc.callsService {
+				service.On("FuzzySearch", "pari", 2).Times(1).Return(tc.mockRegions, tc.mockError)
+			}
+			handler.Search(rr, httptest.NewRequest("GET", tc.target, nil))
+			service.AssertExpectations(t)
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, tc.expectedResponse, rr.Body)
+		})
+	}
+}
+
+func (s *RegionHandlerTestSuite) TestAutocomplete() {
+	service := &hotel_handler.MockRegionService{}
+	handler := hotel_handler.NewRegionHandler(service, logging.Discard())
+	suggestions := []RegionSuggestion{{Id: "2734", Name: "Paris", NameFull: "Paris, France", Type: "city"}}
+	expectedResponse := bytes.NewBuffer(nil)
+	_ = json.NewEncoder(expectedResponse).Encode(suggestions)
+	rr := httptest.NewRecorder()
+	service.On("Autocomplete", "par", 5).Times(1).Return(suggestions)
+
+	handler.Autocomplete(rr, httptest.NewRequest("GET", "/autocomplete?q=par&limit=5", nil))
+
+	service.AssertExpectations(s.T())
+	assert.Equal(s.T(), 200, rr.Code)
+	assert.Equal(s.T(), expectedResponse, rr.Body)
+}
+
+func (s *RegionHandlerTestSuite) TestRegionLinks() {
+	regions := []Region{{Id: "11", Name: "Île-de-France"}, {Id: "73", Name: "France"}}
+	expectedRegionsResponse := bytes.NewBuffer(nil)
+	_ = json.NewEncoder(expectedRegionsResponse).Encode(regions)
 	expectedRegionResponse := bytes.NewBuffer(nil)
-	_ = json.NewEncoder(expectedRegionResponse).Encode(testRegion)
-	expectedErrorResponse := bytes.NewBuffer(nil)
-	_ = json.NewEncoder(expectedErrorResponse).Encode(hotel_handler.Error{HttpStatus: 500,
-		Message: "error"})
+	_ = json.NewEncoder(expectedRegionResponse).Encode(regions[0])
+	expectedErrorResponse := problem(500, "internal_error", "internal error", "/regions/11/ancestors")
+	expectedNotFoundResponse := problem(404, "region_not_found", "region 11 does not exist", "/regions/11")
 
 	tt := []struct {
 		testDescription  string
+		target           string
+		serviceMethod    string
+		serviceArgs      []interface{}
+		mockResult       interface{}
 		mockError        error
-		mockRegion       Region
+		call             func(handler *hotel_handler.RegionHandler) func(http.ResponseWriter, *http.Request)
 		expectedResponse *bytes.Buffer
 	}{
-		{"ShouldNotReturnError", nil, testRegion, expectedRegionResponse},
-		{"ShouldReturnError", errors.New("error"), Region{}, expectedErrorResponse},
+		{"RegionShouldReturnRegion", "/regions/11", "Region", []interface{}{"11"}, regions[0], nil,
+			func(h *hotel_handler.RegionHandler) func(http.ResponseWriter, *http.Request) { return h.Region },
+			expectedRegionResponse},
+		{"AncestorsShouldReturnRegions", "/regions/11/ancestors", "Ancestors", []interface{}{"11"}, regions, nil,
+			func(h *hotel_handler.RegionHandler) func(http.ResponseWriter, *http.Request) { return h.Ancestors },
+			expectedRegionsResponse},
+		{"DescendantsShouldPassType", "/regions/11/descendants?type=city", "Descendants", []interface{}{"11", "city"},
+			regions, nil,
+			func(h *hotel_handler.RegionHandler) func(http.ResponseWriter, *http.Request) { return h.Descendants },
+			expectedRegionsResponse},
+		{"HierarchyShouldPassDepth", "/regions/11/hierarchy?depth=3", "Hierarchy", []interface{}{"11", 3}, regions, nil,
+			func(h *hotel_handler.RegionHandler) func(http.ResponseWriter, *http.Request) { return h.Hierarchy },
+			expectedRegionsResponse},
+		{"PropertyRegionsShouldReturnRegions", "/properties/11/regions", "PropertyRegions", []interface{}{"11"},
+			regions, nil,
+			func(h *hotel_handler.RegionHandler) func(http.ResponseWriter, *http.Request) { return h.PropertyRegions },
+			expectedRegionsResponse},
+		{"AncestorsShouldReturnError", "/regions/11/ancestors", "Ancestors", []interface{}{"11"}, []Region(nil),
+			errors.New("error"),
+			func(h *hotel_handler.RegionHandler) func(http.ResponseWriter, *http.Request) { return h.Ancestors },
+			expectedErrorResponse},
+		{"RegionShouldReturnNotFound", "/regions/11", "Region", []interface{}{"11"}, Region{},
+			NotFound("region_not_found", "region 11 does not exist"),
+			func(h *hotel_handler.RegionHandler) func(http.ResponseWriter, *http.Request) { return h.Region },
+			expectedNotFoundResponse},
 	}
 	for _, tc := range tt {
 		s.T().Run(tc.testDescription, func(t *testing.T) {
+			service := &hotel_handler.MockRegionService{}
+			handler := hotel_handler.NewRegionHandler(service, logging.Discard())
 			rr := httptest.NewRecorder()
-			s.service.On("Search", "first").Times(1).Return(tc.mockRegion, tc.mockError)
-			handler.Search(rr, req)
-			s.service.AssertExpectations(t)
+			req := mux.SetURLVars(httptest.NewRequest("GET", tc.target, nil), map[string]string{"id": "11"})
+			service.On(tc.serviceMethod, tc.serviceArgs...).Times(1).Return(tc.mockResult, tc.mockError)
+
+			tc.call(handler)(rr, req)
+
+			service.AssertExpectations(t)
 			assert.Equal(t, tc.expectedResponse, rr.Body)
 		})
 	}
 }
+
+func (s *RegionHandlerTestSuite) TestErrorsShouldCarryRequestIdAndStatus() {
+	tt := []struct {
+		testDescription string
+		mockError       error
+		expectedStatus  int
+		expectedCode    string
+		expectedLog     string
+	}{
+		{"UpstreamError", &APIError{StatusCode: 503}, 502, "upstream_unavailable", ""},
+		{"InvalidInput", InvalidInput("invalid_parameter", "bad"), 400, "invalid_parameter", ""},
+		{"Conflict", ErrSyncInProgress, 409, "sync_in_progress", ""},
+		{"DeadlineExceeded", context.DeadlineExceeded, 504, "timeout", "level=WARN"},
+		{"Canceled", context.Canceled, 499, "canceled", ""},
+		{"Internal", errors.New("db error"), 500, "internal_error", "level=ERROR"},
+	}
+	for _, tc := range tt {
+		s.T().Run(tc.testDescription, func(t *testing.T) {
+			service := &hotel_handler.MockRegionService{}
+			logs := bytes.NewBuffer(nil)
+			logger, _ := logging.New(logs, "text", "info")
+			handler := hotel_handler.NewRegionHandler(service, logger)
+			service.On("Region", "11").Return(Region{}, tc.mockError)
+			req := mux.SetURLVars(httptest.NewRequest("GET", "/regions/11", nil), map[string]string{"id": "11"})
+			req = req.WithContext(logging.WithRequestId(req.Context(), "req-1"))
+			rr := httptest.NewRecorder()
+
+			handler.Region(rr, req)
+
+			var obtained hotel_handler.Problem
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&obtained))
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, tc.expectedStatus, obtained.Status)
+			assert.Equal(t, tc.expectedCode, obtained.Code)
+			assert.Equal(t, "req-1", obtained.RequestId)
+			assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+			if tc.expectedLog == "" {
+				assert.Empty(t, logs.String())
+			} else {
+				assert.Contains(t, logs.String(), tc.expectedLog)
+			}
+		})
+	}
+}
+
+func (s *RegionHandlerTestSuite) TestValidation() {
+	tt := []struct {
+		testDescription  string
+		target           string
+		handle           func(h *hotel_handler.RegionHandler, w http.ResponseWriter, r *http.Request)
+		expectedResponse *bytes.Buffer
+	}{
+		{"SearchShouldRequireDestination", "/search?destination=%20", (*hotel_handler.RegionHandler).Search,
+			invalidParams("/search", InvalidParam{Name: "destination", Reason: "is required"})},
+		{"SearchShouldRejectDisallowedCharacters", "/search?destination=Paris%3Bdrop", (*hotel_handler.RegionHandler).Search,
+			invalidParams("/search", InvalidParam{Name: "destination",
+				Reason: "may only contain letters, digits, spaces and .,'()&/-"})},
+		{"SearchShouldRejectLongDestination", "/search?destination=" + strings.Repeat("a", 201),
+			(*hotel_handler.RegionHandler).Search,
+			invalidParams("/search", InvalidParam{Name: "destination", Reason: "must be at most 200 characters"})},
+		{"SearchShouldReportEveryInvalidParameter", "/search?mode=loose&limit=0", (*hotel_handler.RegionHandler).Search,
+			invalidParams("/search", InvalidParam{Name: "destination", Reason: "is required"},
+				InvalidParam{Name: "mode", Reason: "must be one of exact, fuzzy"},
+				InvalidParam{Name: "limit", Reason: "must be at least 1"})},
+		{"AutocompleteShouldRequireQuery", "/autocomplete", (*hotel_handler.RegionHandler).Autocomplete,
+			invalidParams("/autocomplete", InvalidParam{Name: "q", Reason: "is required"})},
+		{"DescendantsShouldRejectUnknownType", "/regions/11/descendants?type=planet",
+			(*hotel_handler.RegionHandler).Descendants,
+			invalidParams("/regions/11/descendants", InvalidParam{Name: "type",
+				Reason: "must be one of " + strings.Join(RegionTypes, ", ")})},
+		{"HierarchyShouldRejectNegativeDepth", "/regions/11/hierarchy?depth=-1", (*hotel_handler.RegionHandler).Hierarchy,
+			invalidParams("/regions/11/hierarchy", InvalidParam{Name: "depth", Reason: "must be at least 0"})},
+	}
+	for _, tc := range tt {
+		s.T().Run(tc.testDescription, func(t *testing.T) {
+			service := &hotel_handler.MockRegionService{}
+			rr := httptest.NewRecorder()
+			req := mux.SetURLVars(httptest.NewRequest("GET", tc.target, nil), map[string]string{"id": "11"})
+
+			tc.handle(hotel_handler.NewRegionHandler(service, logging.Discard()), rr, req)
+
+			service.AssertExpectations(t)
+			assert.Equal(t, 400, rr.Code)
+			assert.Equal(t, tc.expectedResponse, rr.Body)
+		})
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	hotel_handler.MethodNotAllowed(rr, httptest.NewRequest("GET", "/update", nil))
+
+	assert.Equal(t, 405, rr.Code)
+	assert.Equal(t, problem(405, "method_not_allowed", "/update does not accept GET", "/update"), rr.Body)
+}
+
+func TestNotFound(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	hotel_handler.NotFound(rr, httptest.NewRequest("GET", "/nowhere", nil))
+
+	assert.Equal(t, 404, rr.Code)
+	assert.Equal(t, problem(404, "route_not_found", "no route matches /nowhere", "/nowhere"), rr.Body)
+}
@@ -0,0 +1,106 @@
+package hotel_handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+//checkTimeout bounds how long a readiness probe waits for the checks, orchestrators giving up after a few seconds
+const checkTimeout = 2 * time.Second
+
+//Check is a dependency the service needs to answer requests, such as the database. Run returns why it is unusable
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+type HealthHandlerInt interface {
+	Live(w http.ResponseWriter, r *http.Request)
+	Ready(w http.ResponseWriter, r *http.Request)
+}
+
+//HealthHandler answers the liveness and readiness probes of the orchestrator
+type HealthHandler struct {
+	checks   []Check
+	draining int32
+	logger   *slog.Logger
+}
+
+type health struct {
+	Status string `json:"status"`
+	//Checks is ok, failing or timed_out by check name. Why a check fails is logged rather than told to anyone asking
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func NewHealthHandler(checks []Check, logger *slog.Logger) *HealthHandler {
+	return &HealthHandler{
+		checks: checks,
+		logger: logger,
+	}
+}
+
+//Drain makes the service report not ready from now on, so load balancers stop sending it requests before it stops
+func (h *HealthHandler) Drain() {
+	atomic.StoreInt32(&h.draining, 1)
+}
+
+//Live tells the process is up and serving. It checks no dependency, so an unreachable database does not get every
+//replica restarted
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(health{Status: "ok"})
+}
+
+//Ready tells whether the service can answer requests: it is not draining and every check passes. The checks run
+//concurrently, those not done within checkTimeout counting as failed
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	if atomic.LoadInt32(&h.draining) == 1 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(health{Status: "draining"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+	type result struct {
+		name string
+		err  error
+	}
+	//buffered so checks finishing after the timeout do not block forever
+	results := make(chan result, len(h.checks))
+	for _, check := range h.checks {
+		go func(check Check) {
+			results <- result{check.Name, check.Run(ctx)}
+		}(check)
+	}
+
+	report := health{Status: "ready", Checks: map[string]string{}}
+	for _, check := range h.checks {
+		report.Checks[check.Name] = "timed_out"
+	}
+	for range h.checks {
+		select {
+		case res := <-results:
+			report.Checks[res.name] = "ok"
+			if res.err != nil {
+				report.Checks[res.name] = "failing"
+				h.logger.WarnContext(r.Context(), "readiness check failing", "check", res.name, "error", res.err)
+			}
+		case <-ctx.Done():
+		}
+	}
+	for _, state := range report.Checks {
+		if state != "ok" {
+			report.Status = "not_ready"
+		}
+	}
+	if report.Status != "ready" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,86 @@
+package hotel_handler_test
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"hotels-service-template/hotel_handler"
+	"hotels-service-template/logging"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func check(name string, err error) hotel_handler.Check {
+	return hotel_handler.Check{Name: name, Run: func(ctx context.Context) error {
+		return err
+	}}
+}
+
+func TestLive(t *testing.T) {
+	handler := hotel_handler.NewHealthHandler([]hotel_handler.Check{check("database", errors.New("down"))},
+		logging.Discard())
+	rr := httptest.NewRecorder()
+
+	handler.Live(rr, httptest.NewRequest("GET", "/healthz", nil))
+
+	assert.Equal(t, 200, rr.Code)
+	assert.JSONEq(t, `{"status": "ok"}`, rr.Body.String())
+}
+
+func TestReady(t *testing.T) {
+	tt := []struct {
+		testDescription  string
+		checks           []hotel_handler.Check
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{"ShouldBeReadyWhenEveryCheckPasses", []hotel_handler.Check{check("database", nil), check("region_sync", nil)},
+			200, `{"status": "ready", "checks": {"database": "ok", "region_sync": "ok"}}`},
+		{"ShouldNotBeReadyWhenACheckFails",
+			[]hotel_handler.Check{check("database", nil), check("region_sync", errors.New("never synced"))},
+			503, `{"status": "not_ready", "checks": {"database": "ok", "region_sync": "failing"}}`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.testDescription, func(t *testing.T) {
+			handler := hotel_handler.NewHealthHandler(tc.checks, logging.Discard())
+			rr := httptest.NewRecorder()
+
+			handler.Ready(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.JSONEq(t, tc.expectedResponse, rr.Body.String())
+		})
+	}
+}
+
+func TestReadyShouldNotWaitForChecksPastTheRequestDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	handler := hotel_handler.NewHealthHandler([]hotel_handler.Check{check("database", nil), {Name: "migrations",
+		Run: func(ctx context.Context) error {
+			<-release
+			return nil
+		}}}, logging.Discard())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	rr := httptest.NewRecorder()
+
+	handler.Ready(rr, httptest.NewRequest("GET", "/readyz", nil).WithContext(ctx))
+
+	assert.Equal(t, 503, rr.Code)
+	assert.JSONEq(t, `{"status": "not_ready", "checks": {"database": "ok", "migrations": "timed_out"}}`,
+		rr.Body.String())
+}
+
+func TestReadyShouldNotBeReadyOnceDraining(t *testing.T) {
+	handler := hotel_handler.NewHealthHandler([]hotel_handler.Check{check("database", nil)}, logging.Discard())
+	handler.Drain()
+	rr := httptest.NewRecorder()
+
+	handler.Ready(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+	assert.Equal(t, 503, rr.Code)
+	assert.JSONEq(t, `{"status": "draining"}`, rr.Body.String())
+}
@@ -0,0 +1,110 @@
+package hotel_handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"hotels-service-template/hotel"
+	"hotels-service-template/logging"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	ProblemContentType = "application/problem+json"
+	RequestIdHeader    = "X-Request-Id"
+	//StatusClientClosedRequest is the nginx status of a request its client gave up on before it was answered
+	StatusClientClosedRequest = 499
+)
+
+//Problem is an RFC 7807 problem details body. Code is the stable machine readable error code
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestId string `json:"request_id,omitempty"`
+	//InvalidParams says what is wrong with each parameter of a 400 response
+	InvalidParams []hotel.InvalidParam `json:"invalid_params,omitempty"`
+}
+
+var statuses = map[hotel.ErrorKind]int{
+	hotel.KindNotFound:            http.StatusNotFound,
+	hotel.KindInvalidInput:        http.StatusBadRequest,
+	hotel.KindConflict:            http.StatusConflict,
+	hotel.KindUnauthenticated:     http.StatusUnauthorized,
+	hotel.KindForbidden:           http.StatusForbidden,
+	hotel.KindUpstreamUnavailable: http.StatusBadGateway,
+	hotel.KindTimeout:             http.StatusGatewayTimeout,
+	hotel.KindCanceled:            StatusClientClosedRequest,
+	hotel.KindInternal:            http.StatusInternalServerError,
+}
+
+//handleError writes err as a problem, with the status its kind maps to. Internal errors are logged rather than
+//shown to clients, while requests their client gave up on are expected and only logged at debug level
+func handleError(logger *slog.Logger, err error, w http.ResponseWriter, r *http.Request) {
+	domainErr := hotel.AsError(err)
+	detail := domainErr.Message
+	switch domainErr.Kind {
+	case hotel.KindInternal:
+		logger.ErrorContext(r.Context(), "internal error", "error", err, "method", r.Method, "path", r.URL.Path)
+	case hotel.KindTimeout:
+		logger.WarnContext(r.Context(), "request timed out", "error", err, "method", r.Method, "path", r.URL.Path)
+	case hotel.KindCanceled:
+		logger.DebugContext(r.Context(), "request canceled", "error", err, "method", r.Method, "path", r.URL.Path)
+	}
+	problem := newProblem(r, statuses[domainErr.Kind], domainErr.Code, detail)
+	problem.InvalidParams = domainErr.Params
+	writeJSONProblem(w, problem)
+}
+
+//WriteError writes err as a problem, for middleware answering before any handler runs
+func WriteError(logger *slog.Logger, w http.ResponseWriter, r *http.Request, err error) {
+	handleError(logger, err, w, r)
+}
+
+func newProblem(r *http.Request, status int, code string, detail string) Problem {
+	return Problem{
+		Type:      "/problems/" + code,
+		Title:     statusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestId: logging.RequestId(r.Context()),
+	}
+}
+
+func statusText(status int) string {
+	if status == StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	return http.StatusText(status)
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code string, detail string) {
+	writeJSONProblem(w, newProblem(r, status, code, detail))
+}
+
+func writeJSONProblem(w http.ResponseWriter, problem Problem) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(problem.Status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
+
+//NotFound answers requests to paths no route matches
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusNotFound, "route_not_found", fmt.Sprintf("no route matches %s", r.URL.Path))
+}
+
+//MethodNotAllowed answers requests to a known path with a method its route does not accept
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusMethodNotAllowed, "method_not_allowed",
+		fmt.Sprintf("%s does not accept %s", r.URL.Path, r.Method))
+}
+
+//TooManyRequests answers requests over their client's rate limit
+func TooManyRequests(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusTooManyRequests, "rate_limited", "too many requests, retry later")
+}
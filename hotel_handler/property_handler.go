@@ -0,0 +1,79 @@
+package hotel_handler
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"hotels-service-template/hotel"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type PropertyHandlerInt interface {
+	Property(w http.ResponseWriter, r *http.Request)
+	RegionProperties(w http.ResponseWriter, r *http.Request)
+}
+
+type PropertyHandler struct {
+	service hotel.PropertyServiceInt
+	logger  *slog.Logger
+}
+
+func NewPropertyHandler(propertyService hotel.PropertyServiceInt, logger *slog.Logger) *PropertyHandler {
+	return &PropertyHandler{
+		service: propertyService,
+		logger:  logger,
+	}
+}
+
+//Property returns the content of the property given in the path
+func (h *PropertyHandler) Property(w http.ResponseWriter, r *http.Request) {
+	property, err := h.service.Property(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(property)
+}
+
+//RegionProperties lists a page of the properties in the region given in the path. They can be narrowed down to a
+//star rating range, to one of the categories and to all of the amenities given as comma separated ids, and sorted
+//by name or rating
+func (h *PropertyHandler) RegionProperties(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if err := validate(query, regionPropertiesParams); err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	filter := hotel.PropertyFilter{
+		Categories: idList(query.Get("category")),
+		Amenities:  idList(query.Get("amenity")),
+		Sort:       hotel.PropertySort(query.Get("sort")),
+	}
+	//the ratings, limit and offset were validated so they parse
+	filter.MinStarRating, _ = strconv.ParseFloat(query.Get("min_star_rating"), 64)
+	filter.MaxStarRating, _ = strconv.ParseFloat(query.Get("max_star_rating"), 64)
+	filter.Limit, _ = intParam(r, "limit", hotel.DefaultSearchLimit)
+	filter.Offset, _ = intParam(r, "offset", 0)
+	page, err := h.service.RegionProperties(r.Context(), mux.Vars(r)["id"], filter)
+	if err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(page)
+}
+
+//idList splits comma separated ids, leaving out repeated ones
+func idList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	var ids []string
+	for _, id := range strings.Split(value, ",") {
+		if !contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
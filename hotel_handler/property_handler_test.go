@@ -0,0 +1,92 @@
+package hotel_handler_test
+
+import (
+	"bytes"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	. "hotels-service-template/hotel"
+	"hotels-service-template/hotel_handler"
+	"hotels-service-template/logging"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProperty(t *testing.T) {
+	property := Property{Id: "12345", Name: "Tirana International Hotel", StarRating: 4,
+		Category: Category{Id: "1", Name: "Hotel"}, Amenities: []Amenity{{Id: "2403", Name: "Free WiFi"}}}
+
+	tt := []struct {
+		testDescription  string
+		id               string
+		mockProperty     Property
+		mockError        error
+		expectedStatus   int
+		expectedResponse *bytes.Buffer
+	}{
+		{"ShouldReturnProperty", "12345", property, nil, 200, encode(property)},
+		{"ShouldReturnNotFound", "1", Property{}, NotFound("property_not_found", "property 1 does not exist"), 404,
+			problem(404, "property_not_found", "property 1 does not exist", "/properties/1")},
+	}
+	for _, tc := range tt {
+		t.Run(tc.testDescription, func(t *testing.T) {
+			service := &hotel_handler.MockPropertyService{}
+			handler := hotel_handler.NewPropertyHandler(service, logging.Discard())
+			rr := httptest.NewRecorder()
+			req := mux.SetURLVars(httptest.NewRequest("GET", "/properties/"+tc.id, nil), map[string]string{"id": tc.id})
+			service.On("Property", tc.id).Times(1).Return(tc.mockProperty, tc.mockError)
+
+			handler.Property(rr, req)
+
+			service.AssertExpectations(t)
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, tc.expectedResponse, rr.Body)
+		})
+	}
+}
+
+func TestRegionProperties(t *testing.T) {
+	page := PropertyPage{Properties: []PropertySummary{{Id: "34567", Name: "Grand Hotel Buenos Aires", StarRating: 5}},
+		Total: 1, Limit: 10}
+
+	tt := []struct {
+		testDescription  string
+		query            string
+		mockFilter       *PropertyFilter
+		mockError        error
+		expectedStatus   int
+		expectedResponse *bytes.Buffer
+	}{
+		{"ShouldPassDefaultFilter", "", &PropertyFilter{Limit: DefaultSearchLimit}, nil, 200, encode(page)},
+		{"ShouldPassEveryFilter", "?min_star_rating=3.5&max_star_rating=5&category=1,2&amenity=2403,9,2403" +
+			"&sort=rating&limit=5&offset=10", &PropertyFilter{MinStarRating: 3.5, MaxStarRating: 5,
+			Categories: []string{"1", "2"}, Amenities: []string{"2403", "9"}, Sort: SortByRating, Limit: 5, Offset: 10},
+			nil, 200, encode(page)},
+		{"ShouldRejectInvalidParameters", "?min_star_rating=4.2&amenity=wifi&sort=price&offset=-1", nil, nil, 400,
+			invalidParams("/regions/6023/properties",
+				InvalidParam{Name: "min_star_rating", Reason: "may only contain whole or half stars from 0 to 5"},
+				InvalidParam{Name: "amenity", Reason: "may only contain digits separated by commas"},
+				InvalidParam{Name: "sort", Reason: "must be one of name, rating"},
+				InvalidParam{Name: "offset", Reason: "must be at least 0"})},
+		{"ShouldReturnNotFound", "", &PropertyFilter{Limit: DefaultSearchLimit},
+			NotFound("region_not_found", "region 6023 does not exist"), 404,
+			problem(404, "region_not_found", "region 6023 does not exist", "/regions/6023/properties")},
+	}
+	for _, tc := range tt {
+		t.Run(tc.testDescription, func(t *testing.T) {
+			service := &hotel_handler.MockPropertyService{}
+			handler := hotel_handler.NewPropertyHandler(service, logging.Discard())
+			rr := httptest.NewRecorder()
+			req := mux.SetURLVars(httptest.NewRequest("GET", "/regions/6023/properties"+tc.query, nil),
+				map[string]string{"id": "6023"})
+			if tc.mockFilter != nil {
+				service.On("RegionProperties", "6023", *tc.mockFilter).Times(1).Return(page, tc.mockError)
+			}
+
+			handler.RegionProperties(rr, req)
+
+			service.AssertExpectations(t)
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, tc.expectedResponse, rr.Body)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package hotel_handler
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"hotels-service-template/hotel"
+)
+
+type MockPropertyService struct {
+	mock.Mock
+}
+
+func (m *MockPropertyService) Update(ctx context.Context, progress func(hotel.SyncProgress)) (hotel.SyncSummary,
+	error) {
+	args := m.Called()
+	if args[1] != nil {
+		return args[0].(hotel.SyncSummary), args[1].(error)
+	}
+	return args[0].(hotel.SyncSummary), nil
+}
+
+func (m *MockPropertyService) Property(ctx context.Context, id string) (hotel.Property, error) {
+	args := m.Called(id)
+	if args[1] != nil {
+		return args[0].(hotel.Property), args[1].(error)
+	}
+	return args[0].(hotel.Property), nil
+}
+
+func (m *MockPropertyService) RegionProperties(ctx context.Context, regionId string,
+	filter hotel.PropertyFilter) (hotel.PropertyPage, error) {
+	args := m.Called(regionId, filter)
+	if args[1] != nil {
+		return args[0].(hotel.PropertyPage), args[1].(error)
+	}
+	return args[0].(hotel.PropertyPage), nil
+}
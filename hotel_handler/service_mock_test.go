@@ -1,7 +1,7 @@
 package hotel_handler
 
 import (
-	"fmt"
+	"context"
 	"github.com/stretchr/testify/mock"
 	"hotels-service-template/hotel"
 )
@@ -10,22 +10,87 @@ type MockRegionService struct {
 	mock.Mock
 }
 
-func (m *MockRegionService) Update() error {
-	fmt.Println("MockRegionService Update method called")
+func (m *MockRegionService) Update(ctx context.Context, progress func(hotel.SyncProgress)) (hotel.SyncSummary, error) {
+	args := m.Called()
+	if args[1] != nil {
+		return args[0].(hotel.SyncSummary), args[1].(error)
+	}
+	return args[0].(hotel.SyncSummary), nil
+}
+
+func (m *MockRegionService) Search(ctx context.Context, destination string) (hotel.Region, error) {
+	args := m.Called(destination)
+	if args[1] != nil {
+		return args[0].(hotel.Region), args[1].(error)
+	}
+	return args[0].(hotel.Region), nil
+}
+
+func (m *MockRegionService) FuzzySearch(ctx context.Context, query string, limit int) ([]hotel.Region, error) {
+	args := m.Called(query, limit)
+	if args[1] != nil {
+		return args[0].([]hotel.Region), args[1].(error)
+	}
+	return args[0].([]hotel.Region), nil
+}
+
+func (m *MockRegionService) Autocomplete(ctx context.Context, prefix string, limit int) []hotel.RegionSuggestion {
+	args := m.Called(prefix, limit)
+	return args[0].([]hotel.RegionSuggestion)
+}
+
+func (m *MockRegionService) RefreshIndex(ctx context.Context) error {
 	args := m.Called()
-	fmt.Println("args extracted are : ", args)
 	if args[0] != nil {
 		return args[0].(error)
 	}
 	return nil
 }
 
-func (m *MockRegionService) Search(destination string) (hotel.Region, error) {
-	fmt.Println("MockRegionService Search method called")
-	args := m.Called(destination)
-	fmt.Println("args extracted are : ", args[0])
+func (m *MockRegionService) Region(ctx context.Context, id string) (hotel.Region, error) {
+	args := m.Called(id)
 	if args[1] != nil {
 		return args[0].(hotel.Region), args[1].(error)
 	}
 	return args[0].(hotel.Region), nil
 }
+
+func (m *MockRegionService) Ancestors(ctx context.Context, id string) ([]hotel.Region, error) {
+	args := m.Called(id)
+	if args[1] != nil {
+		return args[0].([]hotel.Region), args[1].(error)
+	}
+	return args[0].([]hotel.Region), nil
+}
+
+func (m *MockRegionService) Descendants(ctx context.Context, id string, regionType string) ([]hotel.Region, error) {
+	args := m.Called(id, regionType)
+	if args[1] != nil {
+		return args[0].([]hotel.Region), args[1].(error)
+	}
+	return args[0].([]hotel.Region), nil
+}
+
+func (m *MockRegionService) Hierarchy(ctx context.Context, id string, depth int) ([]hotel.Region, error) {
+	args := m.Called(id, depth)
+	if args[1] != nil {
+		return args[0].([]hotel.Region), args[1].(error)
+	}
+	return args[0].([]hotel.Region), nil
+}
+
+func (m *MockRegionService) PropertyRegions(ctx context.Context, propertyId string) ([]hotel.Region, error) {
+	args := m.Called(propertyId)
+	if args[1] != nil {
+		return args[0].([]hotel.Region), args[1].(error)
+	}
+	return args[0].([]hotel.Region), nil
+}
+
+func (m *MockRegionService) Export(ctx context.Context, handle func(hotel.Region) error) error {
+	args := m.Called()
+	if args[0] != nil {
+		return args[0].(error)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package hotel_handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"hotels-service-template/hotel"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+type SyncHandlerInt interface {
+	Update(w http.ResponseWriter, r *http.Request)
+	Start(w http.ResponseWriter, r *http.Request)
+	Status(w http.ResponseWriter, r *http.Request)
+	StartProperties(w http.ResponseWriter, r *http.Request)
+}
+
+type SyncHandler struct {
+	service    hotel.SyncServiceInt
+	properties hotel.SyncServiceInt
+	logger     *slog.Logger
+}
+
+//NewSyncHandler creates the handler of the region syncs run by syncService and the property syncs run by
+//propertySyncService
+func NewSyncHandler(syncService hotel.SyncServiceInt, propertySyncService hotel.SyncServiceInt,
+	logger *slog.Logger) *SyncHandler {
+	return &SyncHandler{
+		service:    syncService,
+		properties: propertySyncService,
+		logger:     logger,
+	}
+}
+
+//Update syncs the regions while the request waits and reports how many were added, changed and removed
+func (h *SyncHandler) Update(w http.ResponseWriter, r *http.Request) {
+	job, err := h.service.Run()
+	if err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(job.Summary)
+}
+
+//Start begins a region sync in the background and responds with its job, whose id can be polled on /sync/{id}
+func (h *SyncHandler) Start(w http.ResponseWriter, r *http.Request) {
+	h.start(h.service, w, r)
+}
+
+//StartProperties begins a property content sync in the background, responding like Start
+func (h *SyncHandler) StartProperties(w http.ResponseWriter, r *http.Request) {
+	h.start(h.properties, w, r)
+}
+
+func (h *SyncHandler) start(service hotel.SyncServiceInt, w http.ResponseWriter, r *http.Request) {
+	job, err := service.Start()
+	if err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/sync/%d", job.Id))
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(job)
+}
+
+//Status reports the progress of the sync job given in the path, whatever it syncs
+func (h *SyncHandler) Status(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		handleError(h.logger, hotel.InvalidInput("invalid_parameter", "id must be a number"), w, r)
+		return
+	}
+	job, err := h.service.Job(id)
+	if err != nil {
+		handleError(h.logger, err, w, r)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(job)
+}
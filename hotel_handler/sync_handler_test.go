@@ -0,0 +1,130 @@
+package hotel_handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	. "hotels-service-template/hotel"
+	"hotels-service-template/hotel_handler"
+	"hotels-service-template/logging"
+	"net/http/httptest"
+	"testing"
+)
+
+type SyncHandlerTestSuite struct {
+	suite.Suite
+	service    *hotel_handler.MockSyncService
+	properties *hotel_handler.MockSyncService
+	handler    *hotel_handler.SyncHandler
+}
+
+func (s *SyncHandlerTestSuite) SetupTest() {
+	s.service = &hotel_handler.MockSyncService{}
+	s.properties = &hotel_handler.MockSyncService{}
+	s.handler = hotel_handler.NewSyncHandler(s.service, s.properties, logging.Discard())
+}
+
+func TestSyncHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(SyncHandlerTestSuite))
+}
+
+func encode(v interface{}) *bytes.Buffer {
+	b := bytes.NewBuffer(nil)
+	_ = json.NewEncoder(b).Encode(v)
+	return b
+}
+
+func (s *SyncHandlerTestSuite) TestUpdate() {
+	summary := SyncSummary{Added: 2, Changed: 1, Removed: 3}
+
+	tt := []struct {
+		testDescription  string
+		mockJob          SyncJob
+		mockError        error
+		expectedStatus   int
+		expectedResponse *bytes.Buffer
+	}{
+		{"ShouldReturnSummary", SyncJob{Id: 1, State: JobSucceeded, Summary: summary}, nil, 200, encode(summary)},
+		{"ShouldReturnError", SyncJob{Id: 1, State: JobFailed}, errors.New("db error"), 500,
+			problem(500, "internal_error", "internal error", "/update")},
+		{"ShouldReturnConflict", SyncJob{}, ErrSyncInProgress, 409,
+			problem(409, "sync_in_progress", "a region sync is already running", "/update")},
+	}
+
+	for _, tc := range tt {
+		s.T().Run(tc.testDescription, func(t *testing.T) {
+			s.SetupTest()
+			rr := httptest.NewRecorder()
+			s.service.On("Run").Times(1).Return(tc.mockJob, tc.mockError)
+
+			s.handler.Update(rr, httptest.NewRequest("POST", "/update", nil))
+
+			s.service.AssertExpectations(t)
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, tc.expectedResponse, rr.Body)
+		})
+	}
+}
+
+func (s *SyncHandlerTestSuite) TestStart() {
+	job := SyncJob{Id: 7, State: JobRunning}
+	rr := httptest.NewRecorder()
+	s.service.On("Start").Times(1).Return(job, nil)
+
+	s.handler.Start(rr, httptest.NewRequest("POST", "/sync", nil))
+
+	s.service.AssertExpectations(s.T())
+	assert.Equal(s.T(), 202, rr.Code)
+	assert.Equal(s.T(), "/sync/7", rr.Header().Get("Location"))
+	assert.Equal(s.T(), encode(job), rr.Body)
+}
+
+func (s *SyncHandlerTestSuite) TestStartShouldReturnConflict() {
+	rr := httptest.NewRecorder()
+	s.service.On("Start").Times(1).Return(SyncJob{}, ErrSyncInProgress)
+
+	s.handler.Start(rr, httptest.NewRequest("POST", "/sync", nil))
+
+	assert.Equal(s.T(), 409, rr.Code)
+	assert.Equal(s.T(), problem(409, "sync_in_progress", "a region sync is already running", "/sync"),
+		rr.Body)
+	assert.Equal(s.T(), "application/problem+json", rr.Header().Get("Content-Type"))
+}
+
+func (s *SyncHandlerTestSuite) TestStartProperties() {
+	job := SyncJob{Id: 8, Kind: SyncProperties, State: JobRunning}
+	rr := httptest.NewRecorder()
+	s.properties.On("Start").Times(1).Return(job, nil)
+
+	s.handler.StartProperties(rr, httptest.NewRequest("POST", "/properties/sync", nil))
+
+	s.properties.AssertExpectations(s.T())
+	s.service.AssertNotCalled(s.T(), "Start")
+	assert.Equal(s.T(), 202, rr.Code)
+	assert.Equal(s.T(), "/sync/8", rr.Header().Get("Location"))
+	assert.Equal(s.T(), encode(job), rr.Body)
+}
+
+func (s *SyncHandlerTestSuite) TestStatus() {
+	job := SyncJob{Id: 7, State: JobRunning, PagesFetched: 3, RegionsProcessed: 1500}
+	rr := httptest.NewRecorder()
+	s.service.On("Job", int64(7)).Times(1).Return(job, nil)
+
+	s.handler.Status(rr, mux.SetURLVars(httptest.NewRequest("GET", "/sync/7", nil), map[string]string{"id": "7"}))
+
+	s.service.AssertExpectations(s.T())
+	assert.Equal(s.T(), 200, rr.Code)
+	assert.Equal(s.T(), encode(job), rr.Body)
+}
+
+func (s *SyncHandlerTestSuite) TestStatusShouldRejectInvalidId() {
+	rr := httptest.NewRecorder()
+
+	s.handler.Status(rr, mux.SetURLVars(httptest.NewRequest("GET", "/sync/x", nil), map[string]string{"id": "x"}))
+
+	assert.Equal(s.T(), 400, rr.Code)
+	assert.Equal(s.T(), problem(400, "invalid_parameter", "id must be a number", "/sync/x"), rr.Body)
+}
@@ -0,0 +1,43 @@
+package hotel_handler
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"hotels-service-template/hotel"
+)
+
+type MockSyncService struct {
+	mock.Mock
+}
+
+func (m *MockSyncService) Start() (hotel.SyncJob, error) {
+	args := m.Called()
+	if args[1] != nil {
+		return args[0].(hotel.SyncJob), args[1].(error)
+	}
+	return args[0].(hotel.SyncJob), nil
+}
+
+func (m *MockSyncService) Run() (hotel.SyncJob, error) {
+	args := m.Called()
+	if args[1] != nil {
+		return args[0].(hotel.SyncJob), args[1].(error)
+	}
+	return args[0].(hotel.SyncJob), nil
+}
+
+func (m *MockSyncService) Job(id int64) (hotel.SyncJob, error) {
+	args := m.Called(id)
+	if args[1] != nil {
+		return args[0].(hotel.SyncJob), args[1].(error)
+	}
+	return args[0].(hotel.SyncJob), nil
+}
+
+func (m *MockSyncService) LastSuccess(ctx context.Context) (hotel.SyncJob, error) {
+	args := m.Called()
+	if args[1] != nil {
+		return args[0].(hotel.SyncJob), args[1].(error)
+	}
+	return args[0].(hotel.SyncJob), nil
+}
@@ -0,0 +1,153 @@
+package hotel_handler
+
+import (
+	"fmt"
+	"hotels-service-template/hotel"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+//param describes what a query or path parameter may hold
+type param struct {
+	name      string
+	required  bool
+	maxLength int
+	//pattern restricts the characters of the value, described to clients by patternHelp
+	pattern     *regexp.Regexp
+	patternHelp string
+	oneOf       []string
+	integer     bool
+	min         int
+}
+
+const destinationHelp = "letters, digits, spaces and .,'()&/-"
+
+//destinationPattern allows letters and marks of any script, digits, spaces and the punctuation of place names
+var destinationPattern = regexp.MustCompile(`^[\pL\pM\pN .,'()&/-]*$`)
+
+const ratingHelp = "whole or half stars from 0 to 5"
+
+//ratingPattern matches the star ratings EAN gives properties
+var ratingPattern = regexp.MustCompile(`^([0-4](\.[05])?|5(\.0)?)$`)
+
+const idListHelp = "digits separated by commas"
+
+var (
+	idPattern     = regexp.MustCompile(`^[0-9]+$`)
+	idListPattern = regexp.MustCompile(`^[0-9]+(,[0-9]+)*$`)
+)
+
+const dateHelp = "a date written as YYYY-MM-DD"
+
+var datePattern = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}$`)
+
+const occupancyHelp = "the number of adults, followed by a dash and the comma separated ages of children if any"
+
+//occupancyPattern matches the occupancy of a room the way EAN takes it, such as 2 or 2-9,4
+var occupancyPattern = regexp.MustCompile(`^[1-9][0-9]?(-[0-9]{1,2}(,[0-9]{1,2})*)?$`)
+
+var (
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	countryPattern  = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+const (
+	maxDestinationLength = 200
+	maxQueryLength       = 100
+)
+
+var (
+	searchParams = []param{
+		{name: "destination", required: true, maxLength: maxDestinationLength, pattern: destinationPattern,
+			patternHelp: destinationHelp},
+		{name: "mode", oneOf: []string{"exact", "fuzzy"}},
+		{name: "limit", integer: true, min: 1},
+	}
+	autocompleteParams = []param{
+		{name: "q", required: true, maxLength: maxQueryLength, pattern: destinationPattern, patternHelp: destinationHelp},
+		{name: "limit", integer: true, min: 1},
+	}
+	descendantsParams      = []param{{name: "type", oneOf: hotel.RegionTypes}}
+	hierarchyParams        = []param{{name: "depth", integer: true, min: 0}}
+	regionPropertiesParams = []param{
+		{name: "min_star_rating", pattern: ratingPattern, patternHelp: ratingHelp},
+		{name: "max_star_rating", pattern: ratingPattern, patternHelp: ratingHelp},
+		{name: "category", maxLength: maxQueryLength, pattern: idListPattern, patternHelp: idListHelp},
+		{name: "amenity", maxLength: maxQueryLength, pattern: idListPattern, patternHelp: idListHelp},
+		{name: "sort", oneOf: []string{string(hotel.SortByName), string(hotel.SortByRating)}},
+		{name: "limit", integer: true, min: 1},
+		{name: "offset", integer: true, min: 0},
+	}
+	availabilityParams = []param{
+		{name: "region_id", required: true, maxLength: 20, pattern: idPattern, patternHelp: "digits"},
+		{name: "checkin", required: true, pattern: datePattern, patternHelp: dateHelp},
+		{name: "checkout", required: true, pattern: datePattern, patternHelp: dateHelp},
+		{name: "occupancy", required: true, maxLength: maxQueryLength, pattern: occupancyPattern,
+			patternHelp: occupancyHelp},
+		{name: "currency", required: true, pattern: currencyPattern, patternHelp: "a three letter currency code"},
+		{name: "country_of_residence", required: true, pattern: countryPattern,
+			patternHelp: "a two letter country code"},
+	}
+)
+
+//validate checks values against params and reports every invalid parameter at once. Every value of a parameter
+//given more than once is checked
+func validate(values url.Values, params []param) error {
+	var invalid []hotel.InvalidParam
+	for _, p := range params {
+		given := values[p.name]
+		if len(given) == 0 {
+			given = []string{""}
+		}
+		for _, value := range given {
+			if reason := p.check(value); reason != "" {
+				invalid = append(invalid, hotel.InvalidParam{Name: p.name, Reason: reason})
+				break
+			}
+		}
+	}
+	if len(invalid) > 0 {
+		return hotel.InvalidParams(invalid)
+	}
+	return nil
+}
+
+func (p param) check(value string) string {
+	if strings.TrimSpace(value) == "" {
+		if p.required {
+			return "is required"
+		}
+		return ""
+	}
+	if p.maxLength > 0 && utf8.RuneCountInString(value) > p.maxLength {
+		return fmt.Sprintf("must be at most %d characters", p.maxLength)
+	}
+	if !utf8.ValidString(value) || p.pattern != nil && !p.pattern.MatchString(value) {
+		return "may only contain " + p.patternHelp
+	}
+	if len(p.oneOf) > 0 && !contains(p.oneOf, value) {
+		return "must be one of " + strings.Join(p.oneOf, ", ")
+	}
+	if p.integer {
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return "must be a number"
+		}
+		if number < p.min {
+			return fmt.Sprintf("must be at least %d", p.min)
+		}
+	}
+	return ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
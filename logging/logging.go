@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"hotels-service-template/tracing"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+//Formats are the log formats New accepts
+var Formats = []string{"json", "text"}
+
+//New creates a logger writing lines in format, json or text, at level (debug, info, warn or error) and above to
+//w. Every line logged with a context carries the request id, trace and attributes stored in it
+func New(w io.Writer, format string, level string) (*slog.Logger, error) {
+	var minLevel slog.Level
+	if err := minLevel.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("log level %q is not one of debug, info, warn, error", level)
+	}
+	options := &slog.HandlerOptions{Level: minLevel}
+	switch strings.ToLower(format) {
+	case "json":
+		return slog.New(contextHandler{slog.NewJSONHandler(w, options)}), nil
+	case "text":
+		return slog.New(contextHandler{slog.NewTextHandler(w, options)}), nil
+	}
+	return nil, fmt.Errorf("log format %q is not one of %s", format, strings.Join(Formats, ", "))
+}
+
+//Discard is a logger dropping everything, for tests and tools that do not log
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+type requestIdKey struct{}
+
+type attrsKey struct{}
+
+//WithRequestId stores the id of the request being served in ctx
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+//RequestId returns the id stored by WithRequestId, or an empty string
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+//WithAttrs stores attributes in ctx that every line logged with it carries, such as the id of a sync job
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	stored, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return context.WithValue(ctx, attrsKey{}, append(stored[:len(stored):len(stored)], attrs...))
+}
+
+//contextHandler adds the request id, trace and attributes stored in the context of a record to it
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestId(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if sc, ok := tracing.SpanContextFrom(ctx); ok && sc.IsValid() {
+		record.AddAttrs(slog.String("trace_id", sc.TraceId.String()), slog.String("span_id", sc.SpanId.String()))
+	}
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"hotels-service-template/tracing"
+	"log/slog"
+	"testing"
+)
+
+func TestNewShouldAddContextToLines(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, "json", "info")
+	assert.NoError(t, err)
+	ctx := WithAttrs(WithRequestId(context.Background(), "req-1"), slog.Int64("sync_job", 7))
+
+	logger.InfoContext(ctx, "region sync finished", "added", 2)
+	logger.DebugContext(ctx, "not logged below info")
+
+	assert.JSONEq(t, `{"level":"INFO","msg":"region sync finished","added":2,"request_id":"req-1","sync_job":7}`,
+		dropTime(t, out.Bytes()))
+}
+
+func TestNewShouldAddTraceToLines(t *testing.T) {
+	var out bytes.Buffer
+	logger, _ := New(&out, "json", "info")
+	sc, _ := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	logger.InfoContext(tracing.WithRemote(context.Background(), sc), "request served")
+
+	assert.JSONEq(t, `{"level":"INFO","msg":"request served","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736",
+		"span_id":"00f067aa0ba902b7"}`, dropTime(t, out.Bytes()))
+}
+
+func TestNewShouldWriteText(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, "text", "debug")
+	assert.NoError(t, err)
+
+	logger.With("component", "client").DebugContext(WithRequestId(context.Background(), "req-1"), "retrying")
+
+	assert.Regexp(t, `^time=\S+ level=DEBUG msg=retrying component=client request_id=req-1\n$`, out.String())
+}
+
+func TestNewShouldRejectUnknownSettings(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, "xml", "info")
+	assert.EqualError(t, err, `log format "xml" is not one of json, text`)
+
+	_, err = New(&bytes.Buffer{}, "json", "verbose")
+	assert.EqualError(t, err, `log level "verbose" is not one of debug, info, warn, error`)
+}
+
+func TestWithAttrsShouldNotShareAttributes(t *testing.T) {
+	parent := WithAttrs(context.Background(), slog.Int("a", 1), slog.Int("b", 2))
+	first := WithAttrs(parent, slog.Int("c", 3))
+	second := WithAttrs(parent, slog.Int("d", 4))
+
+	assert.Equal(t, []slog.Attr{slog.Int("a", 1), slog.Int("b", 2), slog.Int("c", 3)}, first.Value(attrsKey{}))
+	assert.Equal(t, []slog.Attr{slog.Int("a", 1), slog.Int("b", 2), slog.Int("d", 4)}, second.Value(attrsKey{}))
+}
+
+//dropTime removes the time, which changes on every run, from a json line
+func dropTime(t *testing.T, line []byte) string {
+	var fields map[string]interface{}
+	assert.NoError(t, json.Unmarshal(line, &fields))
+	delete(fields, "time")
+	withoutTime, _ := json.Marshal(fields)
+	return string(withoutTime)
+}
@@ -5,71 +5,299 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
 	_ "github.com/lib/pq"
+	"github.com/spf13/pflag"
+	"hotels-service-template/auth"
+	"hotels-service-template/db/migrations"
 	"hotels-service-template/hotel"
 	"hotels-service-template/hotel_handler"
+	"hotels-service-template/ratelimit"
 	"hotels-service-template/route"
+	"hotels-service-template/scheduler"
+	"hotels-service-template/tracing"
+	"log/slog"
 
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 )
 
-const expediaClientUrl = "https://test.ean.com/2.2"
+const usage = `Usage: hotels-service-template [command] [flags]
+
+Commands:
+  serve                   run the HTTP server, the default when no command is given
+  sync                    refresh the regions, or with --properties the property content, from EAN once
+  migrate up|down|status  apply, revert or list the schema migrations
+  export                  write the stored regions as json
+  keys issue|revoke|list  manage the api keys clients authenticate with
+
+Run a command with --help to list its flags.
+`
+
+var commands = map[string]func(args []string) int{
+	"serve":   serve,
+	"sync":    syncRegions,
+	"migrate": migrate,
+	"export":  export,
+	"keys":    keys,
+}
 
 func main() {
-	repo := hotel.NewRepository(getDb())
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	if command == "help" {
+		fmt.Print(usage)
+		return
+	}
+	run, ok := commands[command]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	os.Exit(run(args))
+}
+
+//serve runs the HTTP server until it is signalled to stop
+func serve(args []string) int {
+	config, code, ok := configure(pflag.NewFlagSet("serve", pflag.ContinueOnError), args, true)
+	if !ok {
+		return code
+	}
+
+	logger := config.Log.logger(os.Stdout)
+	tracing.Default = config.Tracing.tracer(logger)
+	defer shutdownTracer(logger)
+	db := getDb(config.Database, logger)
+	if err := prepareSchema(db, config.Database.MigrateOnStart, logger); err != nil {
+		logger.Error("schema is not ready", "error", err)
+		return 1
+	}
+	repo := hotel.NewRepository(db, logger)
 
-	expediaClient := hotel.NewClient(expediaClientUrl)
-	regionService := hotel.NewRegionService(repo, expediaClient)
-	regionHandler := hotel_handler.NewRegionHandler(regionService)
+	expediaClient := hotel.NewClient(config.EAN.client(), logger)
+	regionService := hotel.NewRegionService(repo, expediaClient, logger)
+	if err := regionService.RefreshIndex(context.Background()); err != nil {
+		logger.Error("autocomplete index not built", "error", err)
+	}
+	regionHandler := hotel_handler.NewRegionHandler(regionService, logger)
+	propertyService := hotel.NewPropertyService(hotel.NewPropertyRepository(db, logger), expediaClient, logger)
+	propertyHandler := hotel_handler.NewPropertyHandler(propertyService, logger)
+	availabilityHandler := hotel_handler.NewAvailabilityHandler(hotel.NewAvailabilityService(repo, expediaClient,
+		logger), logger)
+	bookingHandler := hotel_handler.NewBookingHandler(hotel.NewBookingService(hotel.NewItineraryRepository(db, logger),
+		expediaClient, logger), logger)
+	syncService := hotel.NewSyncService(hotel.SyncRegions, regionService,
+		hotel.NewSyncJobRepository(db, hotel.SyncRegions), logger)
+	propertySyncService := hotel.NewSyncService(hotel.SyncProperties, propertyService,
+		hotel.NewSyncJobRepository(db, hotel.SyncProperties), logger)
+	syncHandler := hotel_handler.NewSyncHandler(syncService, propertySyncService, logger)
+	healthHandler := hotel_handler.NewHealthHandler(readinessChecks(db, syncService), logger)
 	router := route.New(mux.NewRouter())
-	router.Configure(regionHandler)
+	router.Configure(regionHandler, propertyHandler, availabilityHandler, bookingHandler, syncHandler,
+		healthHandler)
+	keyService := auth.NewKeyService(auth.NewKeyRepository(db), config.Auth.SigningSecret)
+	//Wrap runs the last middleware first, so the ip limit put after Authenticate also counts the requests it rejects,
+	//the rate limit put first sees the key Authenticate found, the access log lines carry the request id and trace,
+	//and the metrics count rejected requests too
+	limiter, ipLimiter, sweep := rateLimiter(db, config.RateLimit, logger)
+	middlewares := []func(http.Handler) http.Handler{
+		router.Authenticate(keyService, config.Auth.AnonymousSearch, logger)}
+	if ipLimiter != nil {
+		middlewares = append(middlewares, router.LimitIP(ipLimiter, config.RateLimit.TrustForwardedFor, logger))
+	}
+	middlewares = append(middlewares, route.CustomerIp(config.RateLimit.TrustForwardedFor),
+		route.SetContentTypeHeader, router.Metrics(), route.AccessLog(logger), router.Trace(), route.RequestId)
+	var stops []func()
+	if config.Auth.SigningSecret != "" {
+		signatureSweep := scheduler.New("signature sweep", scheduler.Every(time.Hour), time.Minute, keyService.Sweep,
+			logger)
+		signatureSweep.Start()
+		stops = append(stops, signatureSweep.Stop)
+	}
+	if limiter != nil {
+		middlewares = append([]func(http.Handler) http.Handler{
+			router.RateLimit(limiter, config.RateLimit.TrustForwardedFor, logger)}, middlewares...)
+	}
+	if sweep != nil {
+		sweep.Start()
+		stops = append(stops, sweep.Stop)
+	}
 
 	server := &http.Server{
-		Addr:    ":8080",
-		Handler: router.Wrap(route.SetContentTypeHeader),
+		Addr:         config.HTTP.Addr,
+		Handler:      router.Wrap(middlewares...),
+		ReadTimeout:  config.HTTP.ReadTimeout,
+		WriteTimeout: config.HTTP.WriteTimeout,
+		IdleTimeout:  config.HTTP.IdleTimeout,
+	}
+	stops = append(stops, syncService.Stop, propertySyncService.Stop)
+	if refresh := scheduleSync("region sync", syncService, config.Sync.Schedule, config.Sync, logger); refresh != nil {
+		refresh.Start()
+		stops = append(stops, refresh.Stop)
+	}
+	refresh := scheduleSync("property sync", propertySyncService, config.Sync.PropertySchedule, config.Sync, logger)
+	if refresh != nil {
+		refresh.Start()
+		stops = append(stops, refresh.Stop)
+	}
+	start(server, config.HTTP, healthHandler.Drain, logger, stops...)
+	return 0
+}
+
+//readinessChecks are what the service needs to answer requests: a reachable database, whose schema is the one the
+//embedded migrations describe, holding regions from at least one successful sync
+func readinessChecks(db *sql.DB, syncService hotel.SyncServiceInt) []hotel_handler.Check {
+	embedded, loadErr := migrations.Embedded()
+	runner := migrations.NewRunner(db, embedded)
+	return []hotel_handler.Check{
+		{Name: "database", Run: db.PingContext},
+		{Name: "migrations", Run: func(ctx context.Context) error {
+			if loadErr != nil {
+				return loadErr
+			}
+			return runner.Check(ctx)
+		}},
+		{Name: "region_sync", Run: func(ctx context.Context) error {
+			_, err := syncService.LastSuccess(ctx)
+			return err
+		}},
 	}
-	start(server)
 }
 
-func getDb() *sql.DB {
-	db, err := sql.Open("postgres", "dbname=hotels-poc user=postgres password='password' host=localhost port=5432 sslmode=disable")
+//shutdownTracer sends the spans the tracer still holds, once the server stopped and no more spans are started
+func shutdownTracer(logger *slog.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracing.Default.Shutdown(ctx); err != nil {
+		logger.Warn("spans not exported before exiting", "error", err)
+	}
+}
+
+//scheduleSync creates the scheduler named name running syncService on schedule, or nil when there is none. A run
+//finding a sync already in progress, here or on another replica, is skipped
+func scheduleSync(name string, syncService hotel.SyncServiceInt, schedule string, config SyncConfig,
+	logger *slog.Logger) *scheduler.Scheduler {
+	if schedule == "" {
+		return nil
+	}
+	//the schedule was checked when the config was validated
+	parsed, err := scheduler.Parse(schedule)
 	if err != nil {
-		fmt.Println("db open error", err)
 		panic(err)
 	}
+	return scheduler.New(name, parsed, config.Jitter, func() error {
+		_, err := syncService.Run()
+		if err == hotel.ErrSyncInProgress || err == hotel.ErrPropertySyncInProgress {
+			logger.Info(name+" skipped, a sync is already in progress")
+			return nil
+		}
+		return err
+	}, logger)
+}
+
+//rateLimiter creates the rate limiters of the configured store, per client and route and per ip, nil when rate
+//limiting or the ip limit is disabled. The postgres store comes with a scheduler deleting unused buckets
+func rateLimiter(db *sql.DB, config RateLimitConfig,
+	logger *slog.Logger) (*ratelimit.Limiter, *ratelimit.Limiter, *scheduler.Scheduler) {
+	//they were checked when the config was validated
+	fallback, _ := ratelimit.ParseLimit(config.Default)
+	routes, _ := ratelimit.ParseLimits(config.Routes)
+	var store ratelimit.Store
+	var sweep *scheduler.Scheduler
+	switch config.Store {
+	case "memory":
+		store = ratelimit.NewMemoryStore()
+	case "postgres":
+		postgresStore := ratelimit.NewPostgresStore(db)
+		store = postgresStore
+		sweep = scheduler.New("rate limit sweep", scheduler.Every(time.Hour), time.Minute, postgresStore.Sweep,
+			logger)
+	default:
+		return nil, nil, nil
+	}
+	var ipLimiter *ratelimit.Limiter
+	if config.IP != "" {
+		ipLimit, _ := ratelimit.ParseLimit(config.IP)
+		ipLimiter = ratelimit.New(store, ipLimit, nil)
+	}
+	return ratelimit.New(store, fallback, routes), ipLimiter, sweep
+}
+
+//prepareSchema applies the embedded migrations when migrate is set and otherwise checks none is pending
+func prepareSchema(db *sql.DB, migrate bool, logger *slog.Logger) error {
+	embedded, err := migrations.Embedded()
+	if err != nil {
+		return err
+	}
+	runner := migrations.NewRunner(db, embedded)
+	if !migrate {
+		return errors.Wrap(runner.Check(context.Background()), "run migrate up or set DATABASE_MIGRATE_ON_START")
+	}
+	applied, err := runner.Up()
+	for _, migration := range applied {
+		logger.Info("migration applied", "version", migration.Version, "name", migration.Name)
+	}
+	return err
+}
+
+//getDb opens the database pool, exiting when the database cannot be reached
+func getDb(config DatabaseConfig, logger *slog.Logger) *sql.DB {
+	db, err := sql.Open("postgres", config.DSN)
+	if err != nil {
+		logger.Error("database not opened", "error", err)
+		os.Exit(1)
+	}
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
 	if err := db.Ping(); err != nil {
-		fmt.Println("Ping failed", err)
-		panic(err)
+		logger.Error("database not reachable", "error", err)
+		os.Exit(1)
 	}
 	return db
 }
 
-func start(server *http.Server) {
+func start(server *http.Server, config HTTPConfig, drain func(), logger *slog.Logger, stops ...func()) {
 	go func() {
-		fmt.Printf("Starting server on Port: %v", server.Addr)
+		logger.Info("server starting", "addr", server.Addr)
 		if err := server.ListenAndServe(); err != http.ErrServerClosed {
-			panic(err)
+			logger.Error("server failed", "error", err)
+			os.Exit(1)
 		}
 	}()
 
-	gracefulStop(server)
+	gracefulStop(server, config, drain, logger, stops...)
 }
 
-//listens for quit, terminate and interrupt signals and shuts the server gracefully without interrupting any active connections
-func gracefulStop(server *http.Server) {
+//listens for quit, terminate and interrupt signals and shuts the server gracefully without interrupting any active connections.
+//drain is called first, so the service reports not ready while it keeps serving for the drain delay, then stops are
+//called in order, so background work such as an in-flight region sync does not hold the shutdown up
+func gracefulStop(server *http.Server, config HTTPConfig, drain func(), logger *slog.Logger, stops ...func()) {
 	stop := make(chan os.Signal, 1)
 
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
 
 	<-stop
 
-	fmt.Printf("Shutting the server down...")
-	if err := server.Shutdown(context.Background()); err != nil {
-		fmt.Printf("Error: %v\n", err)
+	logger.Info("server draining", "delay", config.DrainDelay)
+	drain()
+	time.Sleep(config.DrainDelay)
+
+	logger.Info("server shutting down")
+	for _, stopWork := range stops {
+		stopWork()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error("server shutdown failed", "error", err)
 	} else {
-		fmt.Printf("Server stopped")
+		logger.Info("server stopped")
 	}
 }
@@ -0,0 +1,289 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//ContentType is the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+//DefaultBuckets suit latencies from a few milliseconds to ten seconds, as in the Prometheus client
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+//Default is the registry the packages of the service register their metrics with, served on /metrics
+var Default = NewRegistry()
+
+//Registry holds metrics and writes them in the Prometheus text format
+type Registry struct {
+	lock    sync.Mutex
+	metrics map[string]metric
+}
+
+type metric interface {
+	write(w io.Writer, name string) error
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: map[string]metric{},
+	}
+}
+
+//vec holds the series of a metric by label values
+type vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	//buckets is how many buckets the series of a histogram have
+	buckets int
+	lock    sync.Mutex
+	series  map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	//buckets, sum and count are only used by histograms
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+func (r *Registry) register(name string, m metric) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.metrics[name]; ok {
+		panic(fmt.Sprintf("metric %s is registered twice", name))
+	}
+	r.metrics[name] = m
+}
+
+func newVec(name, help, kind string, labels []string) *vec {
+	return &vec{name: name, help: help, kind: kind, labels: labels, series: map[string]*series{}}
+}
+
+//get returns the series for labelValues, creating it when needed, with v locked
+func (v *vec) get(labelValues []string) *series {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s takes %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...), buckets: make([]uint64, v.buckets)}
+		v.series[key] = s
+	}
+	return s
+}
+
+//sorted returns the series ordered by label values so the output is stable
+func (v *vec) sorted() []*series {
+	all := make([]*series, 0, len(v.series))
+	for _, s := range v.series {
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return strings.Join(all[i].labelValues, "\xff") < strings.Join(all[j].labelValues, "\xff")
+	})
+	return all
+}
+
+//Counter only goes up, such as a number of requests
+type Counter struct {
+	*vec
+}
+
+//Counter registers a counter with the given label names
+func (r *Registry) Counter(name, help string, labels ...string) Counter {
+	c := Counter{newVec(name, help, "counter", labels)}
+	r.register(name, c)
+	return c
+}
+
+func (c Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c Counter) Add(delta float64, labelValues ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.get(labelValues).value += delta
+}
+
+//Value returns the current value of the series with labelValues
+func (c Counter) Value(labelValues ...string) float64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.get(labelValues).value
+}
+
+//Gauge is a value that goes up and down, such as a number of stored regions
+type Gauge struct {
+	*vec
+}
+
+//Gauge registers a gauge with the given label names
+func (r *Registry) Gauge(name, help string, labels ...string) Gauge {
+	g := Gauge{newVec(name, help, "gauge", labels)}
+	r.register(name, g)
+	return g
+}
+
+func (g Gauge) Set(value float64, labelValues ...string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.get(labelValues).value = value
+}
+
+//Value returns the current value of the series with labelValues
+func (g Gauge) Value(labelValues ...string) float64 {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.get(labelValues).value
+}
+
+//Histogram counts observations, such as latencies, in buckets
+type Histogram struct {
+	*vec
+	upperBounds []float64
+}
+
+//Histogram registers a histogram with the given bucket upper bounds, in increasing order, and label names
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) Histogram {
+	h := Histogram{newVec(name, help, "histogram", labels), buckets}
+	h.vec.buckets = len(buckets)
+	r.register(name, h)
+	return h
+}
+
+func (h Histogram) Observe(value float64, labelValues ...string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	s := h.get(labelValues)
+	for i, bound := range h.upperBounds {
+		if value <= bound {
+			s.buckets[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+//Count returns how many values were observed in the series with labelValues
+func (h Histogram) Count(labelValues ...string) uint64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.get(labelValues).count
+}
+
+//Write writes every metric in the Prometheus text format, ordered by name
+func (r *Registry) Write(w io.Writer) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := r.metrics[name].write(w, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//ServeHTTP serves the metrics for Prometheus to scrape
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_ = r.Write(w)
+}
+
+func (v *vec) header(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, escapeHelp(v.help), v.name, v.kind)
+	return err
+}
+
+func (v *vec) write(w io.Writer, name string) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if err := v.header(w); err != nil {
+		return err
+	}
+	for _, s := range v.sorted() {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", name, v.labelSet(s.labelValues), formatValue(s.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h Histogram) write(w io.Writer, name string) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if err := h.header(w); err != nil {
+		return err
+	}
+	for _, s := range h.sorted() {
+		for i, bound := range h.upperBounds {
+			_, err := fmt.Fprintf(w, "%s_bucket%s %d\n", name,
+				h.labelSet(s.labelValues, "le", formatValue(bound)), s.buckets[i])
+			if err != nil {
+				return err
+			}
+		}
+		_, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+			name, h.labelSet(s.labelValues, "le", "+Inf"), s.count,
+			name, h.labelSet(s.labelValues), formatValue(s.sum),
+			name, h.labelSet(s.labelValues), s.count)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//labelSet formats the labels of a series, followed by extra name and value pairs, as {name="value",...}
+func (v *vec) labelSet(labelValues []string, extra ...string) string {
+	var pairs []string
+	for i, label := range v.labels {
+		pairs = append(pairs, label+`="`+escapeLabel(labelValues[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
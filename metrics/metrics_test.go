@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.Counter("requests_total", "Requests served.", "route", "status")
+	stored := registry.Gauge("regions_stored", "Regions stored.")
+	latency := registry.Histogram("query_duration_seconds", "Query latency.", []float64{0.1, 1}, "query")
+
+	requests.Inc("search", "200")
+	requests.Add(2, "search", "200")
+	requests.Inc("region", `a"b\c`)
+	stored.Set(42)
+	latency.Observe(0.05, "get")
+	latency.Observe(0.5, "get")
+	latency.Observe(5, "get")
+
+	var out bytes.Buffer
+	assert.NoError(t, registry.Write(&out))
+
+	assert.Equal(t, `# HELP query_duration_seconds Query latency.
+# TYPE query_duration_seconds histogram
+query_duration_seconds_bucket{query="get",le="0.1"} 1
+query_duration_seconds_bucket{query="get",le="1"} 2
+query_duration_seconds_bucket{query="get",le="+Inf"} 3
+query_duration_seconds_sum{query="get"} 5.55
+query_duration_seconds_count{query="get"} 3
+# HELP regions_stored Regions stored.
+# TYPE regions_stored gauge
+regions_stored 42
+# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{route="region",status="a\"b\\c"} 1
+requests_total{route="search",status="200"} 3
+`, out.String())
+	assert.Equal(t, float64(3), requests.Value("search", "200"))
+	assert.Equal(t, uint64(3), latency.Count("get"))
+}
+
+func TestRegisterShouldRejectDuplicates(t *testing.T) {
+	registry := NewRegistry()
+	registry.Counter("requests_total", "Requests served.")
+
+	assert.Panics(t, func() { registry.Gauge("requests_total", "Requests served.") })
+}
+
+func TestServeHTTP(t *testing.T) {
+	registry := NewRegistry()
+	registry.Gauge("regions_stored", "Regions stored.").Set(1)
+	rr := httptest.NewRecorder()
+
+	registry.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, ContentType, rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), "regions_stored 1\n")
+}
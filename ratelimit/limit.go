@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Limit is a token bucket: it holds up to Burst requests and refills at Rate requests per second
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+var periods = map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+
+//ParseLimit reads a limit written "count/period", period being s, m or h, optionally followed by ":burst". The
+//burst defaults to count, so "10/s" allows 10 requests at once and then 10 more every second
+func ParseLimit(spec string) (Limit, error) {
+	spec = strings.TrimSpace(spec)
+	rate, burst := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		rate, burst = spec[:i], spec[i+1:]
+	}
+	parts := strings.Split(rate, "/")
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("invalid limit %q: expected count/period such as 10/s", spec)
+	}
+	count, err := strconv.Atoi(parts[0])
+	if err != nil || count < 1 {
+		return Limit{}, fmt.Errorf("invalid limit %q: count must be a positive number", spec)
+	}
+	period, ok := periods[parts[1]]
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid limit %q: period must be s, m or h", spec)
+	}
+	limit := Limit{Rate: float64(count) / period.Seconds(), Burst: count}
+	if burst != "" {
+		limit.Burst, err = strconv.Atoi(burst)
+		if err != nil || limit.Burst < 1 {
+			return Limit{}, fmt.Errorf("invalid limit %q: burst must be a positive number", spec)
+		}
+	}
+	return limit, nil
+}
+
+//ParseLimits reads comma separated "route=limit" pairs, such as "search=10/s:20,update=2/h"
+func ParseLimits(spec string) (map[string]Limit, error) {
+	limits := map[string]Limit{}
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid route limit %q: expected route=limit", pair)
+		}
+		limit, err := ParseLimit(kv[1])
+		if err != nil {
+			return nil, err
+		}
+		limits[strings.TrimSpace(kv[0])] = limit
+	}
+	return limits, nil
+}
+
+//Result is the outcome of taking a token
+type Result struct {
+	Allowed bool
+	//Remaining is how many more requests the bucket allows right now
+	Remaining int
+	//RetryAfter is how long a rejected request has to wait for a token
+	RetryAfter time.Duration
+	//Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+//result describes a bucket holding tokens after a take
+func result(limit Limit, tokens float64, allowed bool) Result {
+	res := Result{Allowed: allowed, Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset: seconds((float64(limit.Burst) - tokens) / limit.Rate)}
+	if !allowed {
+		res.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
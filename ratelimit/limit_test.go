@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseLimit(t *testing.T) {
+	tt := []struct {
+		spec          string
+		expected      Limit
+		expectedError string
+	}{
+		{"10/s", Limit{Rate: 10, Burst: 10}, ""},
+		{"60/m:5", Limit{Rate: 1, Burst: 5}, ""},
+		{" 2/h ", Limit{Rate: 2.0 / 3600, Burst: 2}, ""},
+		{"10", Limit{}, `invalid limit "10": expected count/period such as 10/s`},
+		{"0/s", Limit{}, `invalid limit "0/s": count must be a positive number`},
+		{"10/d", Limit{}, `invalid limit "10/d": period must be s, m or h`},
+		{"10/s:0", Limit{}, `invalid limit "10/s:0": burst must be a positive number`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.spec, func(t *testing.T) {
+			limit, err := ParseLimit(tc.spec)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, limit)
+		})
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits("search=10/s:20, update=2/h,")
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]Limit{"search": {Rate: 10, Burst: 20}, "update": {Rate: 2.0 / 3600, Burst: 2}}, limits)
+
+	_, err = ParseLimits("search")
+	assert.EqualError(t, err, `invalid route limit "search": expected route=limit`)
+}
+
+func TestLimiterShouldFallBackToDefaultLimit(t *testing.T) {
+	limiter := New(NewMemoryStore(), Limit{Rate: 1, Burst: 1}, map[string]Limit{"search": {Rate: 5, Burst: 5}})
+
+	assert.Equal(t, Limit{Rate: 5, Burst: 5}, limiter.Limit("search"))
+	assert.Equal(t, Limit{Rate: 1, Burst: 1}, limiter.Limit("region"))
+}
@@ -0,0 +1,34 @@
+package ratelimit
+
+//Store holds the token buckets
+type Store interface {
+	Take(key string, limit Limit) (Result, error)
+}
+
+//Limiter limits the requests of every client to each route, with the limit set for the route or the default one
+type Limiter struct {
+	store    Store
+	fallback Limit
+	routes   map[string]Limit
+}
+
+func New(store Store, fallback Limit, routes map[string]Limit) *Limiter {
+	return &Limiter{
+		store:    store,
+		fallback: fallback,
+		routes:   routes,
+	}
+}
+
+//Limit returns the limit applying to route
+func (l *Limiter) Limit(route string) Limit {
+	if limit, ok := l.routes[route]; ok {
+		return limit
+	}
+	return l.fallback
+}
+
+//Allow takes a token from the bucket of client for route
+func (l *Limiter) Allow(route string, client string) (Result, error) {
+	return l.store.Take(route+" "+client, l.Limit(route))
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+//sweepEvery is how many takes the memory store lets pass between removing the buckets that refilled
+const sweepEvery = 1024
+
+type bucket struct {
+	limit   Limit
+	tokens  float64
+	updated time.Time
+}
+
+//MemoryStore keeps the buckets of one replica in memory
+type MemoryStore struct {
+	lock    sync.Mutex
+	buckets map[string]*bucket
+	takes   int
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(key string, limit Limit) (Result, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := s.now()
+	s.takes++
+	if s.takes%sweepEvery == 0 {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.limit = limit
+	b.tokens = refill(limit, b.tokens, now.Sub(b.updated))
+	b.updated = now
+	if b.tokens < 1 {
+		return result(limit, b.tokens, false), nil
+	}
+	b.tokens--
+	return result(limit, b.tokens, true), nil
+}
+
+//sweep forgets the buckets that are full again, as they are no different from new ones
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if refill(b.limit, b.tokens, now.Sub(b.updated)) >= float64(b.limit.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func refill(limit Limit, tokens float64, elapsed time.Duration) float64 {
+	return math.Min(float64(limit.Burst), tokens+elapsed.Seconds()*limit.Rate)
+}
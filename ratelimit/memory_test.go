@@ -0,0 +1,49 @@
+package ratelimit
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Unix(1559215747, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{Rate: 2, Burst: 3}
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		result, err := store.Take("search ip:10.0.0.1", limit)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, remaining, result.Remaining)
+	}
+
+	result, _ := store.Take("search ip:10.0.0.1", limit)
+	assert.Equal(t, Result{Allowed: false, Remaining: 0, RetryAfter: 500 * time.Millisecond,
+		Reset: 1500 * time.Millisecond}, result)
+
+	other, _ := store.Take("search ip:10.0.0.2", limit)
+	assert.True(t, other.Allowed, "clients have their own buckets")
+
+	now = now.Add(500 * time.Millisecond)
+	result, _ = store.Take("search ip:10.0.0.1", limit)
+	assert.True(t, result.Allowed, "a token is back after half a second")
+	assert.Equal(t, 0, result.Remaining)
+}
+
+func TestMemoryStoreShouldSweepFullBuckets(t *testing.T) {
+	now := time.Unix(1559215747, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{Rate: 1, Burst: 1}
+
+	_, _ = store.Take("search ip:10.0.0.1", limit)
+	now = now.Add(time.Second)
+	for i := 1; i < sweepEvery; i++ {
+		_, _ = store.Take("search ip:10.0.0.2", limit)
+	}
+
+	assert.Len(t, store.buckets, 1)
+	assert.Contains(t, store.buckets, "search ip:10.0.0.2")
+}
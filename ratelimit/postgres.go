@@ -0,0 +1,44 @@
+package ratelimit
+
+import (
+	"database/sql"
+)
+
+//PostgresStore keeps the buckets in the rate_limits table so replicas share them. Every take is a single upsert
+//refilling and taking from the bucket in place, with the database clock
+type PostgresStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{
+		db: db,
+	}
+}
+
+//takeQuery refills the bucket for the time elapsed since it was last updated, capped at the burst, then takes a
+//token when there is a whole one. $2 is the burst and $3 the rate per second
+const takeQuery = `insert into rate_limits as bucket (key, tokens, allowed, updated_at)
+	values ($1, $2::float8 - 1, true, now())
+	on conflict (key) do update set
+		tokens = least($2, bucket.tokens + extract(epoch from now() - bucket.updated_at) * $3)
+			- case when least($2, bucket.tokens + extract(epoch from now() - bucket.updated_at) * $3) >= 1
+				then 1 else 0 end,
+		allowed = least($2, bucket.tokens + extract(epoch from now() - bucket.updated_at) * $3) >= 1,
+		updated_at = now()
+	returning tokens, allowed`
+
+func (s *PostgresStore) Take(key string, limit Limit) (Result, error) {
+	var tokens float64
+	var allowed bool
+	if err := s.db.QueryRow(takeQuery, key, limit.Burst, limit.Rate).Scan(&tokens, &allowed); err != nil {
+		return Result{}, err
+	}
+	return result(limit, tokens, allowed), nil
+}
+
+//Sweep deletes the buckets not used for a day, which are full again unless their limit is extremely low
+func (s *PostgresStore) Sweep() error {
+	_, err := s.db.Exec(`delete from rate_limits where updated_at < now() - interval '1 day'`)
+	return err
+}
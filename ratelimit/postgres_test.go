@@ -0,0 +1,29 @@
+package ratelimit
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPostgresStore(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	store := NewPostgresStore(db)
+	limit := Limit{Rate: 2, Burst: 3}
+
+	mock.ExpectQuery("insert into rate_limits").WithArgs("search ip:10.0.0.1", 3, 2.0).
+		WillReturnRows(mock.NewRows([]string{"tokens", "allowed"}).AddRow(1.5, true))
+	mock.ExpectQuery("insert into rate_limits").WithArgs("search ip:10.0.0.1", 3, 2.0).
+		WillReturnRows(mock.NewRows([]string{"tokens", "allowed"}).AddRow(0.5, false))
+
+	allowed, err := store.Take("search ip:10.0.0.1", limit)
+	assert.NoError(t, err)
+	denied, err := store.Take("search ip:10.0.0.1", limit)
+	assert.NoError(t, err)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, Result{Allowed: true, Remaining: 1, Reset: 750 * time.Millisecond}, allowed)
+	assert.Equal(t, Result{Allowed: false, Remaining: 0, RetryAfter: 250 * time.Millisecond,
+		Reset: 1250 * time.Millisecond}, denied)
+}
@@ -0,0 +1,36 @@
+package route
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+//statusRecorder remembers the status a handler answered with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+//AccessLog returns a middleware for Wrap logging every request once answered, with its status and duration. It
+//must come before RequestId in Wrap for the lines to carry the request id
+func AccessLog(logger *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			started := time.Now()
+			recorder := &statusRecorder{ResponseWriter: responseWriter, status: http.StatusOK}
+			next.ServeHTTP(recorder, request)
+			level := slog.LevelInfo
+			if recorder.status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logger.Log(request.Context(), level, "request served", "method", request.Method,
+				"path", request.URL.Path, "status", recorder.status, "duration", time.Since(started))
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package route_test
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"hotels-service-template/logging"
+	"hotels-service-template/route"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	var out bytes.Buffer
+	logger, _ := logging.New(&out, "text", "info")
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	req := httptest.NewRequest("GET", "/regions/11", nil)
+	req.Header.Set("X-Request-Id", "req-1")
+
+	route.RequestId(route.AccessLog(logger)(next)).ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Regexp(t, `level=INFO msg="request served" method=GET path=/regions/11 status=404 duration=\S+ `+
+		`request_id=req-1\n$`, out.String())
+}
@@ -0,0 +1,68 @@
+package route
+
+import (
+	"github.com/gorilla/mux"
+	"hotels-service-template/auth"
+	"hotels-service-template/hotel"
+	"hotels-service-template/hotel_handler"
+	"log/slog"
+	"net/http"
+)
+
+//roles is the role each route needs, by route name. Routes not listed, such as the index page, are public
+var roles = map[string]auth.Role{
+	"search":            auth.RoleSearch,
+	"autocomplete":      auth.RoleSearch,
+	"region":            auth.RoleSearch,
+	"ancestors":         auth.RoleSearch,
+	"descendants":       auth.RoleSearch,
+	"hierarchy":         auth.RoleSearch,
+	"property_regions":  auth.RoleSearch,
+	"region_properties": auth.RoleSearch,
+	"property":          auth.RoleSearch,
+	"availability":      auth.RoleSearch,
+	"book":              auth.RoleBook,
+	"booking":           auth.RoleBook,
+	"booking_cancel":    auth.RoleBook,
+	"update":            auth.RoleAdmin,
+	"sync":              auth.RoleAdmin,
+	"property_sync":     auth.RoleAdmin,
+	"sync_status":       auth.RoleAdmin,
+}
+
+//Authenticate returns a middleware for Wrap that lets a request through only when it is made with a key having
+//the role its route needs, storing the key in the request context. With anonymousSearch, routes needing the search
+//role are public
+func (r *Router) Authenticate(keys auth.KeyServiceInt, anonymousSearch bool,
+	logger *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			role := roles[r.routeName(request)]
+			if role == "" || role == auth.RoleSearch && anonymousSearch {
+				next.ServeHTTP(responseWriter, request)
+				return
+			}
+			key, err := keys.Authenticate(request)
+			if err == nil {
+				err = auth.Authorize(key, role)
+			}
+			if err != nil {
+				if hotel.AsError(err).Kind == hotel.KindUnauthenticated {
+					responseWriter.Header().Set("WWW-Authenticate", "ApiKey, Signature")
+				}
+				hotel_handler.WriteError(logger, responseWriter, request, err)
+				return
+			}
+			next.ServeHTTP(responseWriter, request.WithContext(auth.WithKey(request.Context(), key)))
+		})
+	}
+}
+
+//routeName is the name of the route matching request, empty when no route matches it
+func (r *Router) routeName(request *http.Request) string {
+	var match mux.RouteMatch
+	if !r.Match(request, &match) || match.Route == nil {
+		return ""
+	}
+	return match.Route.GetName()
+}
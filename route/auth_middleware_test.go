@@ -0,0 +1,80 @@
+package route
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"hotels-service-template/auth"
+	"hotels-service-template/logging"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	search := auth.Key{Id: 1, Role: auth.RoleSearch}
+	admin := auth.Key{Id: 2, Role: auth.RoleAdmin}
+	book := auth.Key{Id: 3, Role: auth.RoleBook}
+
+	tt := []struct {
+		testDescription string
+		httpMethod      string
+		targetEndpoint  string
+		anonymousSearch bool
+		key             auth.Key
+		authError       error
+		authenticates   bool
+		expectedStatus  int
+	}{
+		{"AdminShouldSync", "POST", "/update", false, admin, nil, true, 200},
+		{"AdminShouldSearch", "GET", "/search", false, admin, nil, true, 200},
+		{"SearchKeyShouldSearch", "GET", "/regions/11", false, search, nil, true, 200},
+		{"SearchKeyShouldNotSync", "POST", "/sync", false, search, nil, true, 403},
+		{"SearchKeyShouldNotSyncProperties", "POST", "/properties/sync", false, search, nil, true, 403},
+		{"SearchKeyShouldReadProperties", "GET", "/properties/12345", false, search, nil, true, 200},
+		{"SearchKeyShouldShop", "GET", "/availability", false, search, nil, true, 200},
+		{"SearchKeyShouldListRegionProperties", "GET", "/regions/11/properties", false, search, nil, true, 200},
+		{"SearchKeyShouldNotBook", "POST", "/bookings", false, search, nil, true, 403},
+		{"BookKeyShouldBook", "POST", "/bookings", false, book, nil, true, 200},
+		{"BookKeyShouldCancel", "DELETE", "/bookings/1", false, book, nil, true, 200},
+		{"BookKeyShouldSearch", "GET", "/availability", false, book, nil, true, 200},
+		{"BookKeyShouldNotSync", "POST", "/sync", false, book, nil, true, 403},
+		{"AdminShouldReadBookings", "GET", "/bookings/1", false, admin, nil, true, 200},
+		{"AnonymousSearchShouldStillGuardBookings", "POST", "/bookings", true, auth.Key{},
+			auth.ErrMissingCredentials, true, 401},
+		{"MissingKeyShouldNotSearch", "GET", "/search", false, auth.Key{}, auth.ErrMissingCredentials, true, 401},
+		{"AnonymousSearchShouldSkipAuthentication", "GET", "/search", true, auth.Key{}, nil, false, 200},
+		{"AnonymousSearchShouldStillGuardSync", "GET", "/sync/7", true, auth.Key{}, auth.ErrInvalidKey, true, 401},
+		{"IndexShouldBePublic", "GET", "/", false, auth.Key{}, nil, false, 200},
+		{"ProbesShouldBePublic", "GET", "/readyz", false, auth.Key{}, nil, false, 200},
+		{"UnknownRouteShouldBePublic", "GET", "/nowhere", false, auth.Key{}, nil, false, 200},
+	}
+	for _, tc := range tt {
+		t.Run(tc.testDescription, func(t *testing.T) {
+			keys := &MockKeyService{}
+			if tc.authenticates {
+				keys.On("Authenticate", "ApiKey test").Return(tc.key, tc.authError)
+			}
+			router := New(mux.NewRouter())
+			router.Configure(&MockRegionHandler{}, &MockPropertyHandler{}, &MockAvailabilityHandler{},
+				&MockBookingHandler{}, &MockSyncHandler{}, &MockHealthHandler{})
+			var obtained auth.Key
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				obtained, _ = auth.KeyFrom(r.Context())
+			})
+			req := httptest.NewRequest(tc.httpMethod, tc.targetEndpoint, nil)
+			req.Header.Set("Authorization", "ApiKey test")
+			rr := httptest.NewRecorder()
+
+			router.Authenticate(keys, tc.anonymousSearch, logging.Discard())(next).ServeHTTP(rr, req)
+
+			keys.AssertExpectations(t)
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedStatus == 200 {
+				assert.Equal(t, tc.key, obtained)
+			}
+			if tc.expectedStatus == 401 {
+				assert.Equal(t, "ApiKey, Signature", rr.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package route
+
+import (
+	"github.com/stretchr/testify/mock"
+	"net/http"
+)
+
+type MockAvailabilityHandler struct {
+	mock.Mock
+}
+
+func (m *MockAvailabilityHandler) Availability(w http.ResponseWriter, r *http.Request) {
+	m.Called(w, r)
+}
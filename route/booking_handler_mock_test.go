@@ -0,0 +1,22 @@
+package route
+
+import (
+	"github.com/stretchr/testify/mock"
+	"net/http"
+)
+
+type MockBookingHandler struct {
+	mock.Mock
+}
+
+func (m *MockBookingHandler) Book(w http.ResponseWriter, r *http.Request) {
+	m.Called(w, r)
+}
+
+func (m *MockBookingHandler) Itinerary(w http.ResponseWriter, r *http.Request) {
+	m.Called(w, r)
+}
+
+func (m *MockBookingHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	m.Called(w, r)
+}
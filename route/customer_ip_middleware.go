@@ -0,0 +1,17 @@
+package route
+
+import (
+	"hotels-service-template/hotel"
+	"net/http"
+)
+
+//CustomerIp returns a middleware for Wrap storing the ip a request came from in its context, where the EAN client
+//picks it up to send as Customer-Ip. The ip is found as by RateLimit
+func CustomerIp(trustForwardedFor bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			ctx := hotel.WithCustomerIp(request.Context(), ip(request, trustForwardedFor))
+			next.ServeHTTP(responseWriter, request.WithContext(ctx))
+		})
+	}
+}
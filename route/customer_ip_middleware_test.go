@@ -0,0 +1,25 @@
+package route
+
+import (
+	"github.com/stretchr/testify/assert"
+	"hotels-service-template/hotel"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCustomerIp(t *testing.T) {
+	var seen string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = hotel.CustomerIp(r.Context())
+	})
+	req := httptest.NewRequest("POST", "/bookings", nil)
+	req.RemoteAddr = "10.0.0.1:4000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+
+	CustomerIp(false)(next).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "10.0.0.1", seen)
+
+	CustomerIp(true)(next).ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "203.0.113.9", seen)
+}
@@ -1,7 +1,6 @@
 package route
 
 import (
-	"fmt"
 	"net/http"
 )
 
@@ -11,5 +10,4 @@ type MockHandler struct {
 
 func (m *MockHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	m.Request = r
-	fmt.Println("Mock Handler Func called")
 }
\ No newline at end of file
@@ -0,0 +1,18 @@
+package route
+
+import (
+	"github.com/stretchr/testify/mock"
+	"net/http"
+)
+
+type MockHealthHandler struct {
+	mock.Mock
+}
+
+func (m *MockHealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	m.Called(w, r)
+}
+
+func (m *MockHealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	m.Called(w, r)
+}
@@ -0,0 +1,30 @@
+package route
+
+import (
+	"github.com/stretchr/testify/mock"
+	"hotels-service-template/auth"
+	"net/http"
+)
+
+type MockKeyService struct {
+	mock.Mock
+}
+
+func (m *MockKeyService) Issue(name string, role auth.Role) (auth.Key, string, error) {
+	args := m.Called(name, role)
+	return args[0].(auth.Key), args.String(1), args.Error(2)
+}
+
+func (m *MockKeyService) Revoke(prefix string) error {
+	return m.Called(prefix).Error(0)
+}
+
+func (m *MockKeyService) List() ([]auth.Key, error) {
+	args := m.Called()
+	return args[0].([]auth.Key), args.Error(1)
+}
+
+func (m *MockKeyService) Authenticate(r *http.Request) (auth.Key, error) {
+	args := m.Called(r.Header.Get("Authorization"))
+	return args[0].(auth.Key), args.Error(1)
+}
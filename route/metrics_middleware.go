@@ -0,0 +1,44 @@
+package route
+
+import (
+	"hotels-service-template/metrics"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	httpRequests = metrics.Default.Counter("http_requests_total",
+		"HTTP requests answered, by route name, method and status.", "route", "method", "status")
+	httpDuration = metrics.Default.Histogram("http_request_duration_seconds",
+		"Time taken to answer HTTP requests, by route name and method.", metrics.DefaultBuckets, "route", "method")
+)
+
+//methods are the methods counted under their own name. Others, which any client can make up, are counted as "other"
+var methods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true, http.MethodPatch: true,
+	http.MethodDelete: true, http.MethodConnect: true, http.MethodOptions: true, http.MethodTrace: true,
+}
+
+//Metrics returns a middleware for Wrap counting and timing requests by route. Requests matching no route are put
+//together under "unmatched" and unknown methods under "other", so scanners cannot create a series per path or
+//method
+func (r *Router) Metrics() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			started := time.Now()
+			route := r.routeName(request)
+			if route == "" {
+				route = "unmatched"
+			}
+			method := request.Method
+			if !methods[method] {
+				method = "other"
+			}
+			recorder := &statusRecorder{ResponseWriter: responseWriter, status: http.StatusOK}
+			next.ServeHTTP(recorder, request)
+			httpRequests.Inc(route, method, strconv.Itoa(recorder.status))
+			httpDuration.Observe(time.Since(started).Seconds(), route, method)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package route
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	router := rateLimitedRouter()
+	handler := router.Metrics()(router)
+	before := httpRequests.Value("unmatched", "GET", "404")
+	beforeMetrics := httpDuration.Count("metrics", "GET")
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/nowhere", nil))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, before+1, httpRequests.Value("unmatched", "GET", "404"))
+	assert.Equal(t, beforeMetrics+1, httpDuration.Count("metrics", "GET"))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, strings.Contains(rr.Body.String(), `http_requests_total{route="unmatched",method="GET",status="404"}`))
+}
+
+func TestMetricsShouldCountUnknownMethodsAsOther(t *testing.T) {
+	router := rateLimitedRouter()
+	handler := router.Metrics()(router)
+	before := httpRequests.Value("unmatched", "other", "404")
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PROPFIND", "/nowhere", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("X-SCAN-1", "/nowhere", nil))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, before+2, httpRequests.Value("unmatched", "other", "404"))
+	assert.NotContains(t, rr.Body.String(), "PROPFIND")
+	assert.NotContains(t, rr.Body.String(), "X-SCAN-1")
+}
@@ -0,0 +1,18 @@
+package route
+
+import (
+	"github.com/stretchr/testify/mock"
+	"net/http"
+)
+
+type MockPropertyHandler struct {
+	mock.Mock
+}
+
+func (m *MockPropertyHandler) Property(w http.ResponseWriter, r *http.Request) {
+	m.Called(w, r)
+}
+
+func (m *MockPropertyHandler) RegionProperties(w http.ResponseWriter, r *http.Request) {
+	m.Called(w, r)
+}
@@ -0,0 +1,95 @@
+package route
+
+import (
+	"hotels-service-template/auth"
+	"hotels-service-template/hotel_handler"
+	"hotels-service-template/ratelimit"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//unlimited are the routes of the orchestrator probes, which must be answered however busy the prober's ip is
+var unlimited = map[string]bool{"healthz": true, "readyz": true}
+
+//RateLimit returns a middleware for Wrap limiting the requests of each client to each route. Clients are told
+//apart by api key when the request was authenticated, so it must come before Authenticate in Wrap, and by ip
+//otherwise. With trustForwardedFor the ip is the last one in X-Forwarded-For, as added by a proxy in front of the
+//service. When the store fails requests are let through
+func (r *Router) RateLimit(limiter *ratelimit.Limiter, trustForwardedFor bool,
+	logger *slog.Logger) func(next http.Handler) http.Handler {
+	return r.limit(limiter, logger, func(request *http.Request, route string) (string, string) {
+		return route, client(request, trustForwardedFor)
+	})
+}
+
+//LimitIP returns a middleware for Wrap limiting the requests of each ip to the whole service, whatever key they
+//are made with. It must come after Authenticate in Wrap, so that requests Authenticate rejects, such as those
+//guessing keys, are limited too. The ip is found as by RateLimit
+func (r *Router) LimitIP(limiter *ratelimit.Limiter, trustForwardedFor bool,
+	logger *slog.Logger) func(next http.Handler) http.Handler {
+	return r.limit(limiter, logger, func(request *http.Request, route string) (string, string) {
+		return "*", "ip:" + ip(request, trustForwardedFor)
+	})
+}
+
+//limit returns a middleware taking a token from the bucket bucket names for each request, by limiter route and
+//client, and rejecting the request when there is none left
+func (r *Router) limit(limiter *ratelimit.Limiter, logger *slog.Logger,
+	bucket func(request *http.Request, route string) (string, string)) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			route := r.routeName(request)
+			if unlimited[route] {
+				next.ServeHTTP(responseWriter, request)
+				return
+			}
+			route, client := bucket(request, route)
+			result, err := limiter.Allow(route, client)
+			if err != nil {
+				logger.WarnContext(request.Context(), "rate limit store failed, letting the request through",
+					"error", err)
+				next.ServeHTTP(responseWriter, request)
+				return
+			}
+			header := responseWriter.Header()
+			header.Set("X-RateLimit-Limit", strconv.Itoa(limiter.Limit(route).Burst))
+			header.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset.Seconds())))
+			if !result.Allowed {
+				header.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter.Seconds())))
+				hotel_handler.TooManyRequests(responseWriter, request)
+				return
+			}
+			next.ServeHTTP(responseWriter, request)
+		})
+	}
+}
+
+//client identifies who made request, by api key or by ip
+func client(request *http.Request, trustForwardedFor bool) string {
+	if key, ok := auth.KeyFrom(request.Context()); ok {
+		return "key:" + key.Prefix
+	}
+	return "ip:" + ip(request, trustForwardedFor)
+}
+
+//ip is the address request came from, the last one in X-Forwarded-For with trustForwardedFor
+func ip(request *http.Request, trustForwardedFor bool) string {
+	if forwarded := request.Header.Get("X-Forwarded-For"); trustForwardedFor && forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		return strings.TrimSpace(hops[len(hops)-1])
+	}
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		host = request.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(seconds float64) int {
+	return int(math.Ceil(seconds))
+}
@@ -0,0 +1,114 @@
+package route
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"hotels-service-template/auth"
+	"hotels-service-template/logging"
+	"hotels-service-template/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type failingStore struct{}
+
+func (failingStore) Take(key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+func rateLimitedRouter() *Router {
+	router := New(mux.NewRouter())
+	router.Configure(&MockRegionHandler{}, &MockPropertyHandler{}, &MockAvailabilityHandler{},
+		&MockBookingHandler{}, &MockSyncHandler{}, &MockHealthHandler{})
+	return router
+}
+
+func TestRateLimit(t *testing.T) {
+	router := rateLimitedRouter()
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), ratelimit.Limit{Rate: 1, Burst: 10},
+		map[string]ratelimit.Limit{"search": {Rate: 0.5, Burst: 1}})
+	handler := router.RateLimit(limiter, false, logging.Discard())(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(target string, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := serve("/search?destination=paris", "10.0.0.1:4000")
+	assert.Equal(t, 200, first.Code)
+	assert.Equal(t, "1", first.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", first.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "2", first.Header().Get("X-RateLimit-Reset"))
+
+	second := serve("/search?destination=paris", "10.0.0.1:4001")
+	assert.Equal(t, 429, second.Code)
+	assert.Equal(t, "2", second.Header().Get("Retry-After"))
+	assert.Equal(t, "application/problem+json", second.Header().Get("Content-Type"))
+
+	assert.Equal(t, 200, serve("/search?destination=paris", "10.0.0.2:4000").Code, "other clients are not limited")
+	other := serve("/regions/11", "10.0.0.1:4000")
+	assert.Equal(t, 200, other.Code, "other routes have their own limits")
+	assert.Equal(t, "10", other.Header().Get("X-RateLimit-Limit"))
+}
+
+func TestRateLimitShouldNotLimitProbes(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), ratelimit.Limit{Rate: 1, Burst: 1}, nil)
+	handler := rateLimitedRouter().RateLimit(limiter, false, logging.Discard())(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+		assert.Equal(t, 200, rr.Code)
+		assert.Empty(t, rr.Header().Get("X-RateLimit-Limit"))
+	}
+}
+
+func TestRateLimitShouldLetRequestsThroughWhenStoreFails(t *testing.T) {
+	limiter := ratelimit.New(failingStore{}, ratelimit.Limit{Rate: 1, Burst: 1}, nil)
+	handler := rateLimitedRouter().RateLimit(limiter, false, logging.Discard())(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/search", nil))
+
+	assert.Equal(t, 200, rr.Code)
+	assert.Empty(t, rr.Header().Get("X-RateLimit-Limit"))
+}
+
+func TestLimitIPShouldCountEveryKeyAndRoute(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.NewMemoryStore(), ratelimit.Limit{Rate: 0.5, Burst: 2}, nil)
+	handler := rateLimitedRouter().LimitIP(limiter, false, logging.Discard())(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(target string, header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req.RemoteAddr = "10.0.0.1:4000"
+		req.Header.Set("Authorization", header)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, 200, serve("/search?destination=paris", "ApiKey hst_0a1b2c3d_0000").Code)
+	assert.Equal(t, 200, serve("/regions/11", "ApiKey hst_0a1b2c3e_0000").Code)
+	limited := serve("/sync", "ApiKey hst_0a1b2c3f_0000")
+	assert.Equal(t, 429, limited.Code)
+	assert.Equal(t, "2", limited.Header().Get("Retry-After"))
+	assert.Equal(t, 200, serve("/readyz", "").Code)
+}
+
+func TestClient(t *testing.T) {
+	req := httptest.NewRequest("GET", "/search", nil)
+	req.RemoteAddr = "10.0.0.1:4000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.7")
+
+	assert.Equal(t, "ip:10.0.0.1", client(req, false))
+	assert.Equal(t, "ip:198.51.100.7", client(req, true))
+	keyed := req.WithContext(auth.WithKey(req.Context(), auth.Key{Prefix: "0a1b2c3d"}))
+	assert.Equal(t, "key:0a1b2c3d", client(keyed, true))
+}
@@ -1,7 +1,6 @@
 package route
 
 import (
-	"fmt"
 	"github.com/stretchr/testify/mock"
 	"net/http"
 )
@@ -11,12 +10,30 @@ type MockRegionHandler struct {
 }
 
 func (m *MockRegionHandler) Search(w http.ResponseWriter, r *http.Request){
-	fmt.Println("mockRegionHandler search method called")
 	m.Called(w, r)
 }
 
-func (m *MockRegionHandler) Update(w http.ResponseWriter, r *http.Request){
-	fmt.Println("mockRegionHandler update method called")
+
+func (m *MockRegionHandler) Autocomplete(w http.ResponseWriter, r *http.Request){
+	m.Called(w, r)
+}
+
+func (m *MockRegionHandler) Region(w http.ResponseWriter, r *http.Request){
 	m.Called(w, r)
 }
 
+func (m *MockRegionHandler) Ancestors(w http.ResponseWriter, r *http.Request){
+	m.Called(w, r)
+}
+
+func (m *MockRegionHandler) Descendants(w http.ResponseWriter, r *http.Request){
+	m.Called(w, r)
+}
+
+func (m *MockRegionHandler) Hierarchy(w http.ResponseWriter, r *http.Request){
+	m.Called(w, r)
+}
+
+func (m *MockRegionHandler) PropertyRegions(w http.ResponseWriter, r *http.Request){
+	m.Called(w, r)
+}
@@ -0,0 +1,33 @@
+package route
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"hotels-service-template/hotel_handler"
+	"hotels-service-template/logging"
+	"net/http"
+	"regexp"
+)
+
+//validRequestId accepts the ids proxies and clients commonly send, and nothing that could garble logs
+var validRequestId = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+//RequestId gives every request an id, taken from the X-Request-Id header when it is sensible or generated
+//otherwise. The id is stored in the request context, where error responses and log lines pick it up, and echoed in
+//the response header
+func RequestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		id := request.Header.Get(hotel_handler.RequestIdHeader)
+		if !validRequestId.MatchString(id) {
+			id = newRequestId()
+		}
+		responseWriter.Header().Set(hotel_handler.RequestIdHeader, id)
+		next.ServeHTTP(responseWriter, request.WithContext(logging.WithRequestId(request.Context(), id)))
+	})
+}
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,42 @@
+package route_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"hotels-service-template/logging"
+	"hotels-service-template/route"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestId(t *testing.T) {
+	tt := []struct {
+		testDescription string
+		header          string
+		kept            bool
+	}{
+		{"ShouldKeepIncomingId", "abc-123", true},
+		{"ShouldGenerateMissingId", "", false},
+		{"ShouldReplaceUnsafeId", "abc\n123", false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.testDescription, func(t *testing.T) {
+			var seen string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = logging.RequestId(r.Context())
+			})
+			req := httptest.NewRequest("GET", "/search", nil)
+			req.Header.Set("X-Request-Id", tc.header)
+			rr := httptest.NewRecorder()
+
+			route.RequestId(next).ServeHTTP(rr, req)
+
+			assert.Equal(t, seen, rr.Header().Get("X-Request-Id"))
+			if tc.kept {
+				assert.Equal(t, tc.header, seen)
+			} else {
+				assert.Regexp(t, "^[0-9a-f]{32}$", seen)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package route
 import (
 	"github.com/gorilla/mux"
 	"hotels-service-template/hotel_handler"
+	"hotels-service-template/metrics"
 	"net/http"
 )
 
@@ -14,10 +15,34 @@ func New(router *mux.Router) *Router {
 	return &Router{router}
 }
 
-func (r Router) Configure(handler hotel_handler.RegionHandlerInt) {
-	r.Handle("/", http.FileServer(http.Dir(".")))
-	r.HandleFunc("/search", handler.Search)
-	r.HandleFunc("/update", handler.Update)
+//Configure registers the routes. Their names identify them in the rate limits
+func (r Router) Configure(handler hotel_handler.RegionHandlerInt, propertyHandler hotel_handler.PropertyHandlerInt,
+	availabilityHandler hotel_handler.AvailabilityHandlerInt, bookingHandler hotel_handler.BookingHandlerInt,
+	syncHandler hotel_handler.SyncHandlerInt, healthHandler hotel_handler.HealthHandlerInt) {
+	r.Handle("/", http.FileServer(http.Dir("."))).Methods("GET", "HEAD").Name("index")
+	r.HandleFunc("/search", handler.Search).Methods("GET").Name("search")
+	r.HandleFunc("/update", syncHandler.Update).Methods("POST").Name("update")
+	r.HandleFunc("/sync", syncHandler.Start).Methods("POST").Name("sync")
+	r.HandleFunc("/sync/{id:[0-9]+}", syncHandler.Status).Methods("GET").Name("sync_status")
+	r.HandleFunc("/autocomplete", handler.Autocomplete).Methods("GET").Name("autocomplete")
+	r.HandleFunc("/regions/{id:[0-9]+}", handler.Region).Methods("GET").Name("region")
+	r.HandleFunc("/regions/{id:[0-9]+}/ancestors", handler.Ancestors).Methods("GET").Name("ancestors")
+	r.HandleFunc("/regions/{id:[0-9]+}/descendants", handler.Descendants).Methods("GET").Name("descendants")
+	r.HandleFunc("/regions/{id:[0-9]+}/hierarchy", handler.Hierarchy).Methods("GET").Name("hierarchy")
+	r.HandleFunc("/regions/{id:[0-9]+}/properties", propertyHandler.RegionProperties).Methods("GET").
+		Name("region_properties")
+	r.HandleFunc("/properties/{id:[0-9]+}/regions", handler.PropertyRegions).Methods("GET").Name("property_regions")
+	r.HandleFunc("/properties/{id:[0-9]+}", propertyHandler.Property).Methods("GET").Name("property")
+	r.HandleFunc("/properties/sync", syncHandler.StartProperties).Methods("POST").Name("property_sync")
+	r.HandleFunc("/availability", availabilityHandler.Availability).Methods("GET").Name("availability")
+	r.HandleFunc("/bookings", bookingHandler.Book).Methods("POST").Name("book")
+	r.HandleFunc("/bookings/{id:[0-9]+}", bookingHandler.Itinerary).Methods("GET").Name("booking")
+	r.HandleFunc("/bookings/{id:[0-9]+}", bookingHandler.Cancel).Methods("DELETE").Name("booking_cancel")
+	r.HandleFunc("/healthz", healthHandler.Live).Methods("GET", "HEAD").Name("healthz")
+	r.HandleFunc("/readyz", healthHandler.Ready).Methods("GET", "HEAD").Name("readyz")
+	r.Handle("/metrics", metrics.Default).Methods("GET").Name("metrics")
+	r.NotFoundHandler = http.HandlerFunc(hotel_handler.NotFound)
+	r.MethodNotAllowedHandler = http.HandlerFunc(hotel_handler.MethodNotAllowed)
 }
 
 func (r *Router) Wrap(middlewares ...func(next http.Handler) http.Handler) http.Handler {
@@ -12,13 +12,23 @@ import (
 
 type RouteTestSuite struct {
 	suite.Suite
-	mockHandler *MockRegionHandler
-	router      *Router
-	rr          *httptest.ResponseRecorder
+	mockHandler             *MockRegionHandler
+	mockPropertyHandler     *MockPropertyHandler
+	mockAvailabilityHandler *MockAvailabilityHandler
+	mockBookingHandler      *MockBookingHandler
+	mockSyncHandler         *MockSyncHandler
+	mockHealthHandler       *MockHealthHandler
+	router                  *Router
+	rr                      *httptest.ResponseRecorder
 }
 
 func (s *RouteTestSuite) SetupSuite() {
 	s.mockHandler = &MockRegionHandler{}
+	s.mockPropertyHandler = &MockPropertyHandler{}
+	s.mockAvailabilityHandler = &MockAvailabilityHandler{}
+	s.mockBookingHandler = &MockBookingHandler{}
+	s.mockSyncHandler = &MockSyncHandler{}
+	s.mockHealthHandler = &MockHealthHandler{}
 }
 
 func (s *RouteTestSuite) SetupTest() {
@@ -31,39 +41,105 @@ func TestRouteTestSuite(t *testing.T) {
 }
 
 func (s *RouteTestSuite) TestRouting() {
-	s.router.Configure(s.mockHandler)
+	s.router.Configure(s.mockHandler, s.mockPropertyHandler, s.mockAvailabilityHandler, s.mockBookingHandler,
+		s.mockSyncHandler, s.mockHealthHandler)
 
 	tt := []struct {
-		httpMethod        string
-		handlerMethodName string
-		targetEndpoint    string
-		body              io.Reader
+		httpMethod          string
+		handlerMethodName   string
+		targetEndpoint      string
+		body                io.Reader
+		propertyHandler     bool
+		availabilityHandler bool
+		bookingHandler      bool
+		syncHandler         bool
+		healthHandler       bool
 	}{
-		{httpMethod: "GET", handlerMethodName: "Update", targetEndpoint: "/update"},
+		{httpMethod: "POST", handlerMethodName: "Update", targetEndpoint: "/update", syncHandler: true},
 		{httpMethod: "GET", handlerMethodName: "Search", targetEndpoint: "/search"},
+		{httpMethod: "GET", handlerMethodName: "Autocomplete", targetEndpoint: "/autocomplete"},
+		{httpMethod: "GET", handlerMethodName: "Region", targetEndpoint: "/regions/2734"},
+		{httpMethod: "GET", handlerMethodName: "Ancestors", targetEndpoint: "/regions/2734/ancestors"},
+		{httpMethod: "GET", handlerMethodName: "Descendants", targetEndpoint: "/regions/2734/descendants"},
+		{httpMethod: "GET", handlerMethodName: "Hierarchy", targetEndpoint: "/regions/2734/hierarchy"},
+		{httpMethod: "GET", handlerMethodName: "PropertyRegions", targetEndpoint: "/properties/12345/regions"},
+		{httpMethod: "GET", handlerMethodName: "RegionProperties", targetEndpoint: "/regions/2734/properties",
+			propertyHandler: true},
+		{httpMethod: "GET", handlerMethodName: "Property", targetEndpoint: "/properties/12345", propertyHandler: true},
+		{httpMethod: "GET", handlerMethodName: "Availability", targetEndpoint: "/availability",
+			availabilityHandler: true},
+		{httpMethod: "POST", handlerMethodName: "Book", targetEndpoint: "/bookings", bookingHandler: true},
+		{httpMethod: "GET", handlerMethodName: "Itinerary", targetEndpoint: "/bookings/1", bookingHandler: true},
+		{httpMethod: "DELETE", handlerMethodName: "Cancel", targetEndpoint: "/bookings/1", bookingHandler: true},
+		{httpMethod: "POST", handlerMethodName: "Start", targetEndpoint: "/sync", syncHandler: true},
+		{httpMethod: "POST", handlerMethodName: "StartProperties", targetEndpoint: "/properties/sync",
+			syncHandler: true},
+		{httpMethod: "GET", handlerMethodName: "Status", targetEndpoint: "/sync/7", syncHandler: true},
+		{httpMethod: "GET", handlerMethodName: "Live", targetEndpoint: "/healthz", healthHandler: true},
+		{httpMethod: "GET", handlerMethodName: "Ready", targetEndpoint: "/readyz", healthHandler: true},
 	}
 
 	for _, tc := range tt {
+		handler := &s.mockHandler.Mock
+		if tc.propertyHandler {
+			handler = &s.mockPropertyHandler.Mock
+		}
+		if tc.availabilityHandler {
+			handler = &s.mockAvailabilityHandler.Mock
+		}
+		if tc.bookingHandler {
+			handler = &s.mockBookingHandler.Mock
+		}
+		if tc.syncHandler {
+			handler = &s.mockSyncHandler.Mock
+		}
+		if tc.healthHandler {
+			handler = &s.mockHealthHandler.Mock
+		}
 		req := httptest.NewRequest(tc.httpMethod, tc.targetEndpoint, tc.body)
-		s.mockHandler.On(tc.handlerMethodName, s.rr, mock.AnythingOfType("*http.Request")).Return()
+		handler.On(tc.handlerMethodName, s.rr, mock.AnythingOfType("*http.Request")).Return()
 		s.router.ServeHTTP(s.rr, req)
-		s.mockHandler.AssertExpectations(s.T())
+		handler.AssertExpectations(s.T())
+	}
+}
+
+func (s *RouteTestSuite) TestRoutingShouldRejectOtherMethods() {
+	s.router.Configure(&MockRegionHandler{}, &MockPropertyHandler{}, &MockAvailabilityHandler{},
+		&MockBookingHandler{}, &MockSyncHandler{}, &MockHealthHandler{})
+
+	tt := []struct {
+		httpMethod     string
+		targetEndpoint string
+	}{
+		{httpMethod: "GET", targetEndpoint: "/update"},
+		{httpMethod: "POST", targetEndpoint: "/search"},
+		{httpMethod: "DELETE", targetEndpoint: "/regions/2734"},
+		{httpMethod: "GET", targetEndpoint: "/sync"},
+		{httpMethod: "PUT", targetEndpoint: "/bookings/1"},
+	}
+
+	for _, tc := range tt {
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, httptest.NewRequest(tc.httpMethod, tc.targetEndpoint, nil))
+		s.Equal(405, rr.Code, tc.httpMethod+" "+tc.targetEndpoint)
+		s.Equal("application/problem+json", rr.Header().Get("Content-Type"))
 	}
 }
 
 func (s *RouteTestSuite) TestWrap() {
-	s.router.Configure(s.mockHandler)
-	req := httptest.NewRequest("GET", "/update", nil)
+	s.router.Configure(s.mockHandler, s.mockPropertyHandler, s.mockAvailabilityHandler, s.mockBookingHandler,
+		s.mockSyncHandler, s.mockHealthHandler)
+	req := httptest.NewRequest("POST", "/update", nil)
 	mw1 := &MockMiddleware{}
 	mw2 := &MockMiddleware{}
-	s.mockHandler.On("Update", s.rr, mock.AnythingOfType("*http.Request")).Return()
+	s.mockSyncHandler.On("Update", s.rr, mock.AnythingOfType("*http.Request")).Return()
 	mw2.On("Do", mock.AnythingOfType("*route.Router")).Once().Return()
 	mw1.On("Do", mock.AnythingOfType("http.HandlerFunc")).Once().Return()
 
 	h := s.router.Wrap(mw2.Do, mw1.Do)
 	h.ServeHTTP(s.rr, req)
 
-	s.mockHandler.AssertExpectations(s.T())
+	s.mockSyncHandler.AssertExpectations(s.T())
 	mw1.AssertExpectations(s.T())
 	mw2.AssertExpectations(s.T())
 
@@ -0,0 +1,26 @@
+package route
+
+import (
+	"github.com/stretchr/testify/mock"
+	"net/http"
+)
+
+type MockSyncHandler struct {
+	mock.Mock
+}
+
+func (m *MockSyncHandler) Update(w http.ResponseWriter, r *http.Request){
+	m.Called(w, r)
+}
+
+func (m *MockSyncHandler) Start(w http.ResponseWriter, r *http.Request){
+	m.Called(w, r)
+}
+
+func (m *MockSyncHandler) Status(w http.ResponseWriter, r *http.Request){
+	m.Called(w, r)
+}
+
+func (m *MockSyncHandler) StartProperties(w http.ResponseWriter, r *http.Request){
+	m.Called(w, r)
+}
@@ -0,0 +1,43 @@
+package route
+
+import (
+	"hotels-service-template/logging"
+	"hotels-service-template/tracing"
+	"net/http"
+)
+
+//Trace returns a middleware for Wrap running every request in a server span named after its method and route,
+//continuing the trace of the caller when it sent a traceparent header. It must come before AccessLog in Wrap for
+//the access log lines to carry the trace id, and after RequestId for the span to carry the request id
+func (r *Router) Trace() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			route := r.routeName(request)
+			if route == "" {
+				route = "unmatched"
+			}
+			ctx := tracing.Extract(request.Context(), request.Header)
+			ctx, span := tracing.Start(ctx, tracing.Server, request.Method+" "+route)
+			defer span.End()
+			span.SetAttribute("http.method", request.Method)
+			span.SetAttribute("http.route", route)
+			span.SetAttribute("http.target", request.URL.Path)
+			if id := logging.RequestId(ctx); id != "" {
+				span.SetAttribute("request_id", id)
+			}
+			recorder := &statusRecorder{ResponseWriter: responseWriter, status: http.StatusOK}
+			next.ServeHTTP(recorder, request.WithContext(ctx))
+			span.SetAttribute("http.status_code", recorder.status)
+			if recorder.status >= http.StatusInternalServerError {
+				span.SetError(errorStatus(recorder.status))
+			}
+		})
+	}
+}
+
+//errorStatus is the error of a span whose request was answered with a server error
+type errorStatus int
+
+func (status errorStatus) Error() string {
+	return http.StatusText(int(status))
+}
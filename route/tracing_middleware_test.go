@@ -0,0 +1,37 @@
+package route
+
+import (
+	"github.com/stretchr/testify/assert"
+	"hotels-service-template/tracing"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTraceShouldContinueTheTraceOfTheCaller(t *testing.T) {
+	exporter := tracing.NewMemoryExporter()
+	tracing.Default = tracing.New(exporter, 1)
+	defer func() {
+		tracing.Default = tracing.New(nil, 0)
+	}()
+	router := rateLimitedRouter()
+	var handled tracing.SpanContext
+	handler := router.Trace()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handled, _ = tracing.SpanContextFrom(r.Context())
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	req := httptest.NewRequest("GET", "/regions/11", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.Spans()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "GET region", spans[0].Name)
+	assert.Equal(t, tracing.Server, spans[0].Kind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].TraceId.String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].ParentId.String())
+	assert.Equal(t, spans[0].SpanId, handled.SpanId)
+	assert.Equal(t, http.StatusBadGateway, spans[0].Attributes["http.status_code"])
+	assert.Equal(t, "Bad Gateway", spans[0].Error)
+}
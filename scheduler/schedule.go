@@ -0,0 +1,151 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Schedule gives the next time a job should run after a given time
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+//Parse reads an interval such as "@every 6h", one of "@hourly", "@daily" and "@weekly", or a five field cron
+//expression "minute hour day-of-month month day-of-week" supporting *, lists, ranges and steps. As in cron, both
+//0 and 7 are Sunday
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	switch {
+	case strings.HasPrefix(spec, "@every "):
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %v", spec, err)
+		}
+		if interval < time.Minute {
+			return nil, fmt.Errorf("invalid schedule %q: interval must be at least a minute", spec)
+		}
+		return Every(interval), nil
+	case spec == "@hourly":
+		return Parse("0 * * * *")
+	case spec == "@daily":
+		return Parse("0 0 * * *")
+	case spec == "@weekly":
+		return Parse("0 0 * * 0")
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields, got %d", spec, len(fields))
+	}
+	var cron cronSchedule
+	var err error
+	bounds := []struct {
+		set      *[]bool
+		min, max int
+	}{
+		{&cron.minute, 0, 59},
+		{&cron.hour, 0, 23},
+		{&cron.dayOfMonth, 1, 31},
+		{&cron.month, 1, 12},
+		{&cron.dayOfWeek, 0, 7},
+	}
+	for i, bound := range bounds {
+		*bound.set, err = parseField(fields[i], bound.min, bound.max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %v", spec, err)
+		}
+	}
+	cron.dayOfWeek[0] = cron.dayOfWeek[0] || cron.dayOfWeek[7]
+	cron.anyDayOfMonth = fields[2] == "*"
+	cron.anyDayOfWeek = fields[4] == "*"
+	return cron, nil
+}
+
+//Every runs a job at a fixed interval
+type Every time.Duration
+
+func (e Every) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(e))
+}
+
+type cronSchedule struct {
+	minute, hour, dayOfMonth, month, dayOfWeek []bool
+	anyDayOfMonth, anyDayOfWeek                bool
+}
+
+//maxSearch bounds how far ahead Next looks, so a schedule like "0 0 31 2 *" that never matches cannot loop forever
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+//Next returns the first whole minute after after matching the expression, in the location of after, or the zero
+//time if there is none. Hours are moved on with time.Date, as truncating to the hour would go by UTC and miss the
+//hours of zones offset by a half or a quarter hour
+func (c cronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	for limit := t.Add(maxSearch); t.Before(limit); {
+		switch {
+		case !c.month[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !c.hour[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !c.minute[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+//matchesDay follows cron: when both day fields are restricted a day matching either of them is enough
+func (c cronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := c.dayOfMonth[t.Day()]
+	dayOfWeek := c.dayOfWeek[int(t.Weekday())]
+	if c.anyDayOfMonth || c.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+func parseField(field string, min, max int) ([]bool, error) {
+	set := make([]bool, max+1)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			part = part[:i]
+		}
+		low, high := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			low, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			high = low
+			if len(bounds) == 2 {
+				high, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid value %q", part)
+				}
+			} else if step > 1 {
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return nil, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for value := low; value <= high; value += step {
+			set[value] = true
+		}
+	}
+	return set, nil
+}
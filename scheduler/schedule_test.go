@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var from = time.Date(2019, time.May, 30, 11, 29, 7, 0, time.UTC)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"@every 6h", from.Add(6 * time.Hour)},
+		{"@hourly", time.Date(2019, time.May, 30, 12, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2019, time.May, 31, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2019, time.June, 2, 0, 0, 0, 0, time.UTC)},
+		{"* * * * *", time.Date(2019, time.May, 30, 11, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2019, time.May, 30, 11, 30, 0, 0, time.UTC)},
+		{"10,40 * * * *", time.Date(2019, time.May, 30, 11, 40, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2019, time.May, 31, 3, 0, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2019, time.May, 30, 13, 0, 0, 0, time.UTC)},
+		{"30 2 1 * *", time.Date(2019, time.June, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 0 * 2 *", time.Date(2020, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 4 * * 1-5", time.Date(2019, time.May, 31, 4, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2019, time.June, 2, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 6-7", time.Date(2019, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		//with both day fields restricted either may match: the 15th or a Monday
+		{"0 0 15 * 1", time.Date(2019, time.June, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		schedule, err := Parse(test.spec)
+		assert.NoError(t, err, test.spec)
+		assert.Equal(t, test.expected, schedule.Next(from), test.spec)
+	}
+}
+
+func TestParseShouldRejectInvalidSpecs(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected string
+	}{
+		{"", `invalid schedule "": expected 5 fields, got 0`},
+		{"* * * *", `invalid schedule "* * * *": expected 5 fields, got 4`},
+		{"60 * * * *", `invalid schedule "60 * * * *": "60" is outside 0-59`},
+		{"* * 0 * *", `invalid schedule "* * 0 * *": "0" is outside 1-31`},
+		{"* * * * 8", `invalid schedule "* * * * 8": "8" is outside 0-7`},
+		{"5-1 * * * *", `invalid schedule "5-1 * * * *": "5-1" is outside 0-59`},
+		{"*/0 * * * *", `invalid schedule "*/0 * * * *": invalid step in "*/0"`},
+		{"a * * * *", `invalid schedule "a * * * *": invalid value "a"`},
+		{"@every soon", `invalid schedule "@every soon": time: invalid duration "soon"`},
+		{"@every 10s", `invalid schedule "@every 10s": interval must be at least a minute`},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.spec)
+		assert.EqualError(t, err, test.expected, test.spec)
+	}
+}
+
+func TestNextShouldGiveUpOnImpossibleSchedules(t *testing.T) {
+	schedule, err := Parse("0 0 31 2 *")
+
+	assert.NoError(t, err)
+	assert.True(t, schedule.Next(from).IsZero())
+}
+
+func TestNextShouldKeepToTheHoursOfTheLocation(t *testing.T) {
+	schedule, err := Parse("0 12 * * *")
+	kolkata := time.FixedZone("IST", 5*60*60+30*60)
+
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2019, time.May, 30, 12, 0, 0, 0, kolkata),
+		schedule.Next(time.Date(2019, time.May, 30, 10, 10, 0, 0, kolkata)))
+}
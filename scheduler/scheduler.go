@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+//Scheduler runs a job on a schedule until stopped. Runs never overlap: the next run is only planned once the
+//current one finished, so a run that overruns its slot makes the scheduler skip the slots it missed
+type Scheduler struct {
+	name     string
+	schedule Schedule
+	jitter   time.Duration
+	job      func() error
+	logger   *slog.Logger
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+//New creates a scheduler for job. Every run is delayed by a random duration up to jitter, so replicas sharing a
+//schedule do not all start at the same instant
+func New(name string, schedule Schedule, jitter time.Duration, job func() error, logger *slog.Logger) *Scheduler {
+	return &Scheduler{
+		name:     name,
+		schedule: schedule,
+		jitter:   jitter,
+		job:      job,
+		logger:   logger.With("scheduler", name),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (s *Scheduler) Start() {
+	go s.loop()
+}
+
+//Stop prevents further runs and waits for a run in progress to finish
+func (s *Scheduler) Stop() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+}
+
+func (s *Scheduler) loop() {
+	defer close(s.done)
+	for {
+		next := s.schedule.Next(time.Now())
+		if next.IsZero() {
+			s.logger.Warn("schedule has no next run, stopping")
+			return
+		}
+		if s.jitter > 0 {
+			next = next.Add(time.Duration(rand.Int63n(int64(s.jitter))))
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := s.job(); err != nil {
+			s.logger.Error("scheduled run failed", "error", err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"hotels-service-template/logging"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerShouldRunJobRepeatedly(t *testing.T) {
+	var runs int32
+	s := New("test", Every(5*time.Millisecond), 0, func() error {
+		atomic.AddInt32(&runs, 1)
+		return errors.New("failed runs do not stop the scheduler")
+	}, logging.Discard())
+
+	s.Start()
+	time.Sleep(100 * time.Millisecond)
+	s.Stop()
+
+	assert.True(t, atomic.LoadInt32(&runs) >= 2, "expected at least two runs, got %d", runs)
+}
+
+func TestSchedulerShouldNotOverlapRuns(t *testing.T) {
+	var running, overlaps int32
+	s := New("test", Every(time.Millisecond), time.Millisecond, func() error {
+		if !atomic.CompareAndSwapInt32(&running, 0, 1) {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.StoreInt32(&running, 0)
+		return nil
+	}, logging.Discard())
+
+	s.Start()
+	time.Sleep(60 * time.Millisecond)
+	s.Stop()
+
+	assert.Equal(t, int32(0), overlaps)
+}
+
+func TestStopShouldWaitForRunInProgress(t *testing.T) {
+	started := make(chan struct{})
+	var finished int32
+	s := New("test", Every(time.Millisecond), 0, func() error {
+		select {
+		case <-started:
+		default:
+			close(started)
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.StoreInt32(&finished, 1)
+		return nil
+	}, logging.Discard())
+
+	s.Start()
+	<-started
+	s.Stop()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&finished))
+}
+
+func TestStopShouldNotRunJob(t *testing.T) {
+	var runs int32
+	s := New("test", Every(time.Hour), 0, func() error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}, logging.Discard())
+
+	s.Start()
+	s.Stop()
+	s.Stop()
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&runs))
+}
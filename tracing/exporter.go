@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+//MemoryExporter keeps the spans in memory, for tests
+type MemoryExporter struct {
+	lock  sync.Mutex
+	spans []SpanData
+}
+
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+func (e *MemoryExporter) Export(span SpanData) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+func (e *MemoryExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+//Spans returns the spans exported so far, in the order they ended
+func (e *MemoryExporter) Spans() []SpanData {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
+
+//WriterExporter writes each span as a line of json, to look at traces locally without a collector
+type WriterExporter struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{w: w}
+}
+
+type writtenSpan struct {
+	Name       string                 `json:"name"`
+	Kind       string                 `json:"kind"`
+	TraceId    string                 `json:"trace_id"`
+	SpanId     string                 `json:"span_id"`
+	ParentId   string                 `json:"parent_id,omitempty"`
+	Start      time.Time              `json:"start"`
+	DurationMs float64                `json:"duration_ms"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+func (e *WriterExporter) Export(span SpanData) {
+	written := writtenSpan{
+		Name:       span.Name,
+		Kind:       span.Kind.String(),
+		TraceId:    span.TraceId.String(),
+		SpanId:     span.SpanId.String(),
+		Start:      span.Start,
+		DurationMs: float64(span.End.Sub(span.Start).Microseconds()) / 1000,
+		Attributes: span.Attributes,
+		Error:      span.Error,
+	}
+	if span.ParentId.IsValid() {
+		written.ParentId = span.ParentId.String()
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	_ = json.NewEncoder(e.w).Encode(written)
+}
+
+func (e *WriterExporter) Shutdown(context.Context) error {
+	return nil
+}
@@ -0,0 +1,218 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	otlpBatchSize = 512
+	//otlpQueueSize spans can wait to be sent, those ending while the queue is full are dropped
+	otlpQueueSize = 4 * otlpBatchSize
+	otlpInterval  = 5 * time.Second
+)
+
+//OTLPExporter sends the spans in batches to an OpenTelemetry collector, with OTLP over HTTP in its json encoding.
+//A batch is sent once full or every few seconds, from a goroutine Shutdown stops
+type OTLPExporter struct {
+	url     string
+	service string
+	client  *http.Client
+	queue   chan SpanData
+	dropped int64
+	stop    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+	logger  *slog.Logger
+}
+
+//NewOTLPExporter creates an exporter sending to the collector at endpoint, such as http://localhost:4318, the
+//spans of service
+func NewOTLPExporter(endpoint string, service string, logger *slog.Logger) *OTLPExporter {
+	e := &OTLPExporter{
+		url:     strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		service: service,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		queue:   make(chan SpanData, otlpQueueSize),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		logger:  logger,
+	}
+	go e.run()
+	return e
+}
+
+func (e *OTLPExporter) Export(span SpanData) {
+	select {
+	case e.queue <- span:
+	default:
+		atomic.AddInt64(&e.dropped, 1)
+	}
+}
+
+//Shutdown sends the queued spans and stops sending, waiting until done or ctx is
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.once.Do(func() {
+		close(e.stop)
+	})
+	select {
+	case <-e.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *OTLPExporter) run() {
+	defer close(e.stopped)
+	ticker := time.NewTicker(otlpInterval)
+	defer ticker.Stop()
+	batch := make([]SpanData, 0, otlpBatchSize)
+	flush := func() {
+		if dropped := atomic.SwapInt64(&e.dropped, 0); dropped > 0 {
+			e.logger.Warn("trace queue full, spans dropped", "dropped", dropped)
+		}
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil {
+			e.logger.Warn("spans not exported", "spans", len(batch), "error", err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= otlpBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.stop:
+			for {
+				select {
+				case span := <-e.queue:
+					batch = append(batch, span)
+					if len(batch) >= otlpBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *OTLPExporter) send(batch []SpanData) error {
+	body, err := json.Marshal(e.payload(batch))
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector answered %s", resp.Status)
+	}
+	return nil
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceId           string         `json:"traceId"`
+	SpanId            string         `json:"spanId"`
+	ParentSpanId      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	//Code is 0 for unset and 2 for error
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+//payload builds an ExportTraceServiceRequest. In the json encoding of OTLP ids are hex and 64 bit integers strings
+func (e *OTLPExporter) payload(batch []SpanData) map[string]interface{} {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, span := range batch {
+		exported := otlpSpan{
+			TraceId:           span.TraceId.String(),
+			SpanId:            span.SpanId.String(),
+			Name:              span.Name,
+			Kind:              int(span.Kind),
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+		}
+		if span.ParentId.IsValid() {
+			exported.ParentSpanId = span.ParentId.String()
+		}
+		if span.Error != "" {
+			exported.Status = otlpStatus{Code: 2, Message: span.Error}
+		}
+		spans = append(spans, exported)
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]interface{}{"service.name": e.service}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": e.service},
+				"spans": spans,
+			}},
+		}},
+	}
+}
+
+func otlpAttributes(attributes map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	kvs := make([]otlpKeyValue, 0, len(keys))
+	for _, key := range keys {
+		var value map[string]interface{}
+		switch v := attributes[key].(type) {
+		case string:
+			value = map[string]interface{}{"stringValue": v}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		kvs = append(kvs, otlpKeyValue{Key: key, Value: value})
+	}
+	return kvs
+}
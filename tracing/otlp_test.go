@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOTLPExporterShouldSendQueuedSpansOnShutdown(t *testing.T) {
+	var payloads []map[string]interface{}
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		var payload map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		payloads = append(payloads, payload)
+	}))
+	defer collector.Close()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	exporter := NewOTLPExporter(collector.URL, "hotels-service-template", logger)
+	start := time.Unix(1559215747, 0)
+	span := SpanData{Name: "GET search", Kind: Server, TraceId: TraceId{1}, SpanId: SpanId{2}, Start: start,
+		End: start.Add(time.Second), Attributes: map[string]interface{}{"http.status_code": 500}, Error: "db error"}
+
+	exporter.Export(span)
+	assert.NoError(t, exporter.Shutdown(context.Background()))
+
+	assert.Len(t, payloads, 1)
+	resourceSpans := payloads[0]["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	exported := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"traceId":           "01000000000000000000000000000000",
+		"spanId":            "0200000000000000",
+		"name":              "GET search",
+		"kind":              float64(2),
+		"startTimeUnixNano": "1559215747000000000",
+		"endTimeUnixNano":   "1559215748000000000",
+		"attributes": []interface{}{map[string]interface{}{"key": "http.status_code",
+			"value": map[string]interface{}{"intValue": "500"}}},
+		"status": map[string]interface{}{"code": float64(2), "message": "db error"},
+	}, exported[0])
+}
@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+//TraceparentHeader carries the span context between services, as specified by W3C Trace Context
+const TraceparentHeader = "traceparent"
+
+//sampledFlag is the trace flag telling the caller recorded its span
+const sampledFlag = 0x01
+
+//Traceparent formats sc as a version 00 traceparent header value
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceId.String() + "-" + sc.SpanId.String() + "-" + flags
+}
+
+//ParseTraceparent reads a traceparent header value. Versions after 00 are read as far as 00 defines them, as the
+//specification asks
+func ParseTraceparent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	var version [1]byte
+	if len(parts) < 4 || !decodeHex(parts[0], version[:]) || version[0] == 0xff ||
+		(version[0] == 0 && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	var sc SpanContext
+	if !decodeHex(parts[1], sc.TraceId[:]) || !decodeHex(parts[2], sc.SpanId[:]) || !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	var flags [1]byte
+	if !decodeHex(parts[3], flags[:]) {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&sampledFlag != 0
+	return sc, true
+}
+
+//decodeHex decodes lowercase hex of exactly len(dst) bytes into dst
+func decodeHex(value string, dst []byte) bool {
+	if len(value) != 2*len(dst) || strings.ToLower(value) != value {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(value))
+	return err == nil
+}
+
+//Extract stores the span context of a valid traceparent header of an incoming request in ctx, so the span started
+//for the request continues the trace of the caller
+func Extract(ctx context.Context, header http.Header) context.Context {
+	if sc, ok := ParseTraceparent(header.Get(TraceparentHeader)); ok {
+		return WithRemote(ctx, sc)
+	}
+	return ctx
+}
+
+//Inject sets the traceparent header of an outgoing request to the span context in ctx, if there is one
+func Inject(ctx context.Context, header http.Header) {
+	if sc, ok := SpanContextFrom(ctx); ok && sc.IsValid() {
+		header.Set(TraceparentHeader, sc.Traceparent())
+	}
+}
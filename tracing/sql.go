@@ -0,0 +1,114 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+//DB runs statements on a database pool, each in a client span named after its operation, such as select, with
+//the statement text but not its arguments, which may be personal data
+type DB struct {
+	*sql.DB
+}
+
+func WrapDB(db *sql.DB) DB {
+	return DB{db}
+}
+
+//Tx is a transaction whose statements are traced like those of DB
+type Tx struct {
+	*sql.Tx
+}
+
+func (db DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return queryContext(ctx, db.DB.QueryContext, query, args...)
+}
+
+func (db DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return queryRowContext(ctx, db.DB.QueryRowContext, query, args...)
+}
+
+func (db DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return execContext(ctx, db.DB.ExecContext, query, args...)
+}
+
+func (db DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+	_, span := startStatement(ctx, "begin")
+	defer span.End()
+	tx, err := db.DB.BeginTx(ctx, opts)
+	span.SetError(err)
+	return Tx{tx}, err
+}
+
+func (tx Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return queryContext(ctx, tx.Tx.QueryContext, query, args...)
+}
+
+func (tx Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return queryRowContext(ctx, tx.Tx.QueryRowContext, query, args...)
+}
+
+func (tx Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return execContext(ctx, tx.Tx.ExecContext, query, args...)
+}
+
+//CommitContext commits the transaction in a span. The context is only used for the span, sql.Tx cannot be
+//committed with one
+func (tx Tx) CommitContext(ctx context.Context) error {
+	_, span := startStatement(ctx, "commit")
+	defer span.End()
+	err := tx.Tx.Commit()
+	span.SetError(err)
+	return err
+}
+
+func queryContext(ctx context.Context, query func(context.Context, string, ...interface{}) (*sql.Rows, error),
+	statement string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startStatement(ctx, statement)
+	defer span.End()
+	rows, err := query(ctx, statement, args...)
+	span.SetError(err)
+	return rows, err
+}
+
+func queryRowContext(ctx context.Context, queryRow func(context.Context, string, ...interface{}) *sql.Row,
+	statement string, args ...interface{}) *sql.Row {
+	ctx, span := startStatement(ctx, statement)
+	defer span.End()
+	row := queryRow(ctx, statement, args...)
+	span.SetError(row.Err())
+	return row
+}
+
+func execContext(ctx context.Context, exec func(context.Context, string, ...interface{}) (sql.Result, error),
+	statement string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startStatement(ctx, statement)
+	defer span.End()
+	result, err := exec(ctx, statement, args...)
+	span.SetError(err)
+	return result, err
+}
+
+//maxStatementLength is as much of a statement as a span keeps, bulk inserts running to megabytes
+const maxStatementLength = 2048
+
+func startStatement(ctx context.Context, statement string) (context.Context, *Span) {
+	operation := strings.TrimSpace(statement)
+	if end := strings.IndexAny(operation, " \t\n"); end >= 0 {
+		operation = operation[:end]
+	}
+	operation = strings.ToLower(operation)
+	ctx, span := Start(ctx, Client, operation)
+	if !span.IsRecording() {
+		return ctx, span
+	}
+	span.SetAttribute("db.system", "postgresql")
+	if operation != statement {
+		if len(statement) > maxStatementLength {
+			statement = statement[:maxStatementLength] + "..."
+		}
+		span.SetAttribute("db.statement", strings.Join(strings.Fields(statement), " "))
+	}
+	return ctx, span
+}
@@ -0,0 +1,38 @@
+package tracing
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDBShouldTraceStatementsWithoutArguments(t *testing.T) {
+	exporter := NewMemoryExporter()
+	Default = New(exporter, 1)
+	defer func() {
+		Default = New(nil, 0)
+	}()
+	sqlDB, mock, _ := sqlmock.New()
+	db := WrapDB(sqlDB)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("update regions").WithArgs("secret").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	ctx, parent := Start(context.Background(), Server, "POST update")
+	tx, err := db.BeginTx(ctx, nil)
+	assert.NoError(t, err)
+	_, err = tx.ExecContext(ctx, `update regions
+		set name = $1`, "secret")
+	assert.NoError(t, err)
+	assert.NoError(t, tx.CommitContext(ctx))
+
+	spans := exporter.Spans()
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Len(t, spans, 3)
+	assert.Equal(t, []string{"begin", "update", "commit"}, []string{spans[0].Name, spans[1].Name, spans[2].Name})
+	assert.Equal(t, map[string]interface{}{"db.system": "postgresql", "db.statement": "update regions set name = $1"},
+		spans[1].Attributes)
+	assert.Equal(t, parent.Context().SpanId, spans[1].ParentId)
+	assert.Equal(t, Client, spans[1].Kind)
+}
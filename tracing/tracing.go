@@ -0,0 +1,258 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+//TraceId identifies a trace, the spans of one request across services
+type TraceId [16]byte
+
+//SpanId identifies a span within a trace
+type SpanId [8]byte
+
+func (id TraceId) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id TraceId) IsValid() bool {
+	return id != TraceId{}
+}
+
+func (id SpanId) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id SpanId) IsValid() bool {
+	return id != SpanId{}
+}
+
+//SpanContext is what is propagated of a span to its children, in this process or in another through traceparent
+type SpanContext struct {
+	TraceId TraceId
+	SpanId  SpanId
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceId.IsValid() && sc.SpanId.IsValid()
+}
+
+//Kind tells what a span stands for, in the OpenTelemetry sense
+type Kind int
+
+const (
+	Internal Kind = iota + 1
+	Server
+	Client
+)
+
+func (kind Kind) String() string {
+	switch kind {
+	case Server:
+		return "server"
+	case Client:
+		return "client"
+	}
+	return "internal"
+}
+
+//SpanData is a finished span, as handed to exporters
+type SpanData struct {
+	Name       string
+	Kind       Kind
+	TraceId    TraceId
+	SpanId     SpanId
+	ParentId   SpanId
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+	//Error is why the operation failed, empty when it succeeded
+	Error string
+}
+
+//Span is an operation being timed. Spans that are not sampled are not recorded but still carry their context to
+//their children, so a sampling decision holds for the whole trace
+type Span struct {
+	tracer *Tracer
+	lock   sync.Mutex
+	data   SpanData
+	ended  bool
+	//sampled is the decision passed on to the children, including remote ones
+	sampled bool
+	//recording is false for spans only propagating their context: those not sampled, or all with no exporter
+	recording bool
+}
+
+//Context returns what the children of the span inherit
+func (s *Span) Context() SpanContext {
+	return SpanContext{TraceId: s.data.TraceId, SpanId: s.data.SpanId, Sampled: s.sampled}
+}
+
+//IsRecording tells whether the span is recorded, so attributes costly to compute are worth setting
+func (s *Span) IsRecording() bool {
+	return s.recording
+}
+
+//SetAttribute records a property of the operation, such as the HTTP status. Values should be strings, bools,
+//integers or floats
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if !s.recording {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data.Attributes[key] = value
+}
+
+//SetError marks the operation failed with err. A nil err changes nothing
+func (s *Span) SetError(err error) {
+	if err == nil || !s.recording {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data.Error = err.Error()
+}
+
+//End finishes the span and hands it to the exporter. Only the first call counts
+func (s *Span) End() {
+	if !s.recording {
+		return
+	}
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = s.tracer.now()
+	data := s.data
+	s.lock.Unlock()
+	s.tracer.exporter.Export(data)
+}
+
+//Finish records the error err points to, if any, and ends the span. It suits functions with a named error result:
+//defer span.Finish(&err)
+func (s *Span) Finish(err *error) {
+	s.SetError(*err)
+	s.End()
+}
+
+//Exporter sends finished spans somewhere they can be looked at. Export must not block for long
+type Exporter interface {
+	Export(span SpanData)
+	//Shutdown sends the spans still buffered, giving up when ctx is done
+	Shutdown(ctx context.Context) error
+}
+
+//Tracer starts spans, sampling a ratio of the traces started here and following the decision of the caller for
+//traces started elsewhere
+type Tracer struct {
+	exporter    Exporter
+	sampleRatio float64
+	now         func() time.Time
+	random      func() float64
+}
+
+//New creates a tracer exporting the sampled spans to exporter. With a nil exporter nothing is sampled, but trace
+//contexts are still propagated
+func New(exporter Exporter, sampleRatio float64) *Tracer {
+	if exporter == nil {
+		exporter, sampleRatio = noopExporter{}, 0
+	}
+	return &Tracer{exporter: exporter, sampleRatio: sampleRatio, now: time.Now, random: randomRatio}
+}
+
+//Default is the tracer the packages of the service start their spans with, replaced at startup by the configured
+//one
+var Default = New(nil, 0)
+
+//Start starts a span with Default, see Tracer.Start
+func Start(ctx context.Context, kind Kind, name string) (context.Context, *Span) {
+	return Default.Start(ctx, kind, name)
+}
+
+//Start starts a span named name as a child of the span in ctx, or of the remote span stored by Extract, and
+//returns it with a context carrying it
+func (t *Tracer) Start(ctx context.Context, kind Kind, name string) (context.Context, *Span) {
+	parent, hasParent := SpanContextFrom(ctx)
+	span := &Span{tracer: t, data: SpanData{Name: name, Kind: kind, SpanId: newSpanId()}}
+	if hasParent {
+		span.data.TraceId, span.data.ParentId, span.sampled = parent.TraceId, parent.SpanId, parent.Sampled
+	} else {
+		span.data.TraceId = newTraceId()
+		span.sampled = t.sampleRatio > 0 && t.random() < t.sampleRatio
+	}
+	//without exporter nothing is recorded here, yet the decision of the caller is passed on to the services called
+	_, noop := t.exporter.(noopExporter)
+	span.recording = span.sampled && !noop
+	if span.recording {
+		span.data.Start = t.now()
+		span.data.Attributes = map[string]interface{}{}
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+//Shutdown flushes the spans the exporter still holds
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	return t.exporter.Shutdown(ctx)
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+//SpanFrom returns the span stored in ctx by Start, or nil
+func SpanFrom(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+//SpanContextFrom returns the context of the span in ctx, or else of the remote parent stored by Extract
+func SpanContextFrom(ctx context.Context) (SpanContext, bool) {
+	if span := SpanFrom(ctx); span != nil {
+		return span.Context(), true
+	}
+	sc, ok := ctx.Value(remoteKey{}).(SpanContext)
+	return sc, ok
+}
+
+//WithRemote stores the context of a span of another process in ctx, making it the parent of the next span started
+func WithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+func newTraceId() TraceId {
+	var id TraceId
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanId() SpanId {
+	var id SpanId
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+func randomRatio() float64 {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return float64(binary.BigEndian.Uint64(b[:])>>11) / (1 << 53)
+}
+
+type noopExporter struct{}
+
+func (noopExporter) Export(SpanData) {}
+
+func (noopExporter) Shutdown(context.Context) error {
+	return nil
+}
@@ -0,0 +1,110 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestStartShouldNestSpansOfOneTrace(t *testing.T) {
+	exporter := NewMemoryExporter()
+	tracer := New(exporter, 1)
+
+	ctx, parent := tracer.Start(context.Background(), Server, "GET search")
+	_, child := tracer.Start(ctx, Internal, "regionService.Search")
+	child.SetAttribute("limit", 10)
+	child.SetError(errors.New("db error"))
+	child.End()
+	child.End()
+	parent.End()
+
+	spans := exporter.Spans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "regionService.Search", spans[0].Name)
+	assert.Equal(t, parent.Context().TraceId, spans[0].TraceId)
+	assert.Equal(t, parent.Context().SpanId, spans[0].ParentId)
+	assert.Equal(t, map[string]interface{}{"limit": 10}, spans[0].Attributes)
+	assert.Equal(t, "db error", spans[0].Error)
+	assert.False(t, spans[1].ParentId.IsValid())
+	assert.Empty(t, spans[1].Error)
+}
+
+func TestStartShouldFollowTheSamplingDecisionOfTheCaller(t *testing.T) {
+	exporter := NewMemoryExporter()
+	tracer := New(exporter, 0)
+
+	_, unsampled := tracer.Start(context.Background(), Server, "GET search")
+	unsampled.End()
+	sc, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, sampled := tracer.Start(WithRemote(context.Background(), sc), Server, "GET search")
+	sampled.End()
+
+	spans := exporter.Spans()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, sc.TraceId, spans[0].TraceId)
+	assert.Equal(t, sc.SpanId, spans[0].ParentId)
+	assert.True(t, unsampled.Context().IsValid(), "unsampled spans still propagate their context")
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tt := []struct {
+		testDescription string
+		value           string
+		expectedOk      bool
+		expectedSampled bool
+	}{
+		{"Sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"NotSampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"LaterVersionWithMoreFields", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what", true, true},
+		{"InvalidVersion", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"Version00WithMoreFields", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what", false, false},
+		{"ZeroTraceId", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"UppercaseHex", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"ShortSpanId", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902-01", false, false},
+		{"Empty", "", false, false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.testDescription, func(t *testing.T) {
+			sc, ok := ParseTraceparent(tc.value)
+
+			assert.Equal(t, tc.expectedOk, ok)
+			assert.Equal(t, tc.expectedSampled, sc.Sampled)
+		})
+	}
+}
+
+func TestExtractAndInjectShouldPassTheTraceOn(t *testing.T) {
+	tracer := New(NewMemoryExporter(), 1)
+	incoming := http.Header{}
+	incoming.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx, span := tracer.Start(Extract(context.Background(), incoming), Client, "GET regions")
+	outgoing := http.Header{}
+	Inject(ctx, outgoing)
+
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+span.Context().SpanId.String()+"-01",
+		outgoing.Get(TraceparentHeader))
+}
+
+func TestExtractAndInjectShouldKeepTheSamplingDecisionWithoutExporter(t *testing.T) {
+	incoming := http.Header{}
+	incoming.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx, span := New(nil, 0).Start(Extract(context.Background(), incoming), Client, "GET regions")
+	outgoing := http.Header{}
+	Inject(ctx, outgoing)
+
+	assert.False(t, span.IsRecording())
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+span.Context().SpanId.String()+"-01",
+		outgoing.Get(TraceparentHeader))
+}
+
+func TestInjectShouldNotSetHeaderWithoutTrace(t *testing.T) {
+	outgoing := http.Header{}
+
+	Inject(context.Background(), outgoing)
+
+	assert.Empty(t, outgoing.Get(TraceparentHeader))
+}